@@ -0,0 +1,139 @@
+package apperrors
+
+import (
+	"errors"
+	"gin-fleamarket/constants"
+	"net/http"
+)
+
+// Error クライアントに返すエラー。Codeはメッセージを変更しても変えない、機械で判別するための識別子
+// サービスは下のErrXxxを返し（原因を残す場合はWrapする）、middlewares.ErrorHandlerがapplication/problem+jsonに変換する
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	// Fields 入力の検証に失敗した場合のフィールドごとの詳細
+	Fields []FieldError
+	cause  error
+}
+
+// FieldError 検証に失敗したフィールド。FieldはJSONのキー名（クエリパラメーターの場合はその名前）
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func New(status int, code string, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Detail + ": " + e.cause.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is Codeが同じなら同じエラーとみなし、Wrapしたエラーもerrors.Is(err, apperrors.ErrXxx)で判定できるようにする
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap 原因のエラーを付けたコピーを返す。原因はログとスパンにだけ出力し、クライアントには返さない
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+// WithFields フィールドごとの詳細を付けたコピーを返す
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	withFields := *e
+	withFields.Fields = fields
+	return &withFields
+}
+
+// From errに含まれる*Errorを返す。含まれない（想定外の）エラーは500として扱う
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// 共通
+var (
+	ErrInternal     = New(http.StatusInternalServerError, "internal_error", constants.ErrUnexpected)
+	ErrUnavailable  = New(http.StatusServiceUnavailable, "service_unavailable", constants.ErrUnavailable)
+	ErrNotFound     = New(http.StatusNotFound, "not_found", constants.ErrNotFound)
+	ErrInvalidID    = New(http.StatusBadRequest, "invalid_id", constants.ErrInvalidID)
+	ErrInvalidInput = New(http.StatusBadRequest, "invalid_input", constants.ErrInvalidInput)
+	// ErrMalformedBody JSONとして読めないリクエストボディ
+	ErrMalformedBody = New(http.StatusBadRequest, "malformed_body", constants.ErrMalformedBody)
+	ErrForbidden     = New(http.StatusForbidden, "forbidden", constants.ErrForbidden)
+)
+
+// 認証
+var (
+	ErrUnauthorized          = New(http.StatusUnauthorized, "unauthorized", constants.ErrUnauthorized)
+	ErrInvalidToken          = New(http.StatusUnauthorized, "invalid_token", constants.ErrInvalidToken)
+	ErrInvalidCredentials    = New(http.StatusUnauthorized, "invalid_credentials", constants.ErrInvalidCredentials)
+	ErrWrongPassword         = New(http.StatusUnauthorized, "wrong_password", constants.ErrWrongPassword)
+	ErrEmailAlreadyExists    = New(http.StatusConflict, "email_already_exists", constants.ErrEmailAlreadyExists)
+	ErrInvalidCSRFToken      = New(http.StatusForbidden, "invalid_csrf_token", constants.ErrInvalidCSRFToken)
+	ErrSessionNotFound       = New(http.StatusNotFound, "session_not_found", constants.ErrSessionNotFound)
+	ErrSessionRevoked        = New(http.StatusUnauthorized, "session_revoked", constants.ErrSessionRevoked)
+	ErrRefreshTokenReused    = New(http.StatusUnauthorized, "refresh_token_reused", constants.ErrRefreshTokenReused)
+	ErrAPIKeyNotFound        = New(http.StatusNotFound, "api_key_not_found", constants.ErrAPIKeyNotFound)
+	ErrAPIKeyInvalidScope    = New(http.StatusBadRequest, "invalid_api_key_scope", constants.ErrAPIKeyInvalidScope)
+	ErrInsufficientScope     = New(http.StatusForbidden, "insufficient_scope", constants.ErrInsufficientScope)
+	ErrAdminAlreadyExists    = New(http.StatusConflict, "admin_already_exists", constants.ErrAdminAlreadyExists)
+	ErrBootstrapDisabled     = New(http.StatusNotFound, "bootstrap_disabled", constants.ErrBootstrapDisabled)
+	ErrInvalidBootstrapToken = New(http.StatusUnauthorized, "invalid_bootstrap_token", constants.ErrInvalidBootstrapToken)
+	ErrOIDCProviderNotFound  = New(http.StatusNotFound, "oidc_provider_not_found", constants.ErrOIDCProviderNotFound)
+	ErrOIDCInvalidState      = New(http.StatusUnauthorized, "oidc_invalid_state", constants.ErrOIDCInvalidState)
+	ErrOIDCEmailNotVerified  = New(http.StatusUnauthorized, "oidc_email_not_verified", constants.ErrOIDCEmailNotVerified)
+	ErrOIDCFailed            = New(http.StatusUnauthorized, "oidc_failed", constants.ErrOIDCFailed)
+	ErrOIDCUnavailable       = New(http.StatusBadGateway, "oidc_unavailable", constants.ErrOIDCUnavailable)
+)
+
+// ユーザー
+var (
+	ErrUserNotFound            = New(http.StatusNotFound, "user_not_found", constants.ErrUserNotFound)
+	ErrUserSuspended           = New(http.StatusForbidden, "user_suspended", constants.ErrUserSuspended)
+	ErrUserBanned              = New(http.StatusForbidden, "user_banned", constants.ErrUserBanned)
+	ErrLastAdmin               = New(http.StatusConflict, "last_admin", constants.ErrLastAdmin)
+	ErrCannotManageSelf        = New(http.StatusForbidden, "cannot_manage_self", constants.ErrCannotManageSelf)
+	ErrInsufficientPrivilege   = New(http.StatusForbidden, "insufficient_privilege", constants.ErrInsufficientPrivilege)
+	ErrInvalidPrefecture       = New(http.StatusBadRequest, "invalid_prefecture", constants.ErrInvalidPrefecture)
+	ErrInvalidAvatar           = New(http.StatusBadRequest, "invalid_avatar", constants.ErrInvalidAvatar)
+	ErrAvatarTooLarge          = New(http.StatusRequestEntityTooLarge, "avatar_too_large", constants.ErrAvatarTooLarge)
+	ErrImpersonationNotAllowed = New(http.StatusForbidden, "impersonation_not_allowed", constants.ErrImpersonationNotAllowed)
+	ErrImpersonationReadOnly   = New(http.StatusForbidden, "impersonation_read_only", constants.ErrImpersonationReadOnly)
+)
+
+// 商品
+var (
+	ErrItemNotFound     = New(http.StatusNotFound, "item_not_found", constants.ErrItemNotFound)
+	ErrNoFieldsToUpdate = New(http.StatusBadRequest, "no_fields_to_update", constants.ErrNoFieldsToUpdate)
+)
+
+// 管理
+var (
+	ErrRoleNotFound       = New(http.StatusNotFound, "role_not_found", constants.ErrRoleNotFound)
+	ErrUnknownRole        = New(http.StatusBadRequest, "unknown_role", constants.ErrRoleNotFound)
+	ErrRoleAlreadyExists  = New(http.StatusConflict, "role_already_exists", constants.ErrRoleAlreadyExists)
+	ErrRoleInUse          = New(http.StatusConflict, "role_in_use", constants.ErrRoleInUse)
+	ErrRoleBuiltin        = New(http.StatusForbidden, "role_builtin", constants.ErrRoleBuiltin)
+	ErrPermissionNotFound = New(http.StatusBadRequest, "permission_not_found", constants.ErrPermissionNotFound)
+	ErrJobNotFound        = New(http.StatusNotFound, "job_not_found", constants.ErrJobNotFound)
+	ErrJobBusy            = New(http.StatusConflict, "job_busy", constants.ErrJobBusy)
+	ErrInvalidLogLevel    = New(http.StatusBadRequest, "invalid_log_level", constants.ErrInvalidLogLevel)
+)
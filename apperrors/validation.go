@@ -0,0 +1,92 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// InvalidInput ShouldBindJSONやShouldBindQueryのエラーを、フィールドごとの詳細を付けた400に変換する
+func InvalidInput(err error) *Error {
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrors):
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			fields = append(fields, FieldError{
+				Field:   fieldError.Field(),
+				Code:    fieldError.Tag(),
+				Message: validationMessage(fieldError),
+			})
+		}
+		return ErrInvalidInput.Wrap(err).WithFields(fields...)
+	case errors.As(err, &typeError):
+		return ErrInvalidInput.Wrap(err).WithFields(FieldError{
+			Field:   typeError.Field,
+			Code:    "type",
+			Message: "must be " + typeName(typeError.Type),
+		})
+	case errors.As(err, &syntaxError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrMalformedBody.Wrap(err)
+	}
+	return ErrInvalidInput.Wrap(err)
+}
+
+// Required bindingタグで検証できない必須のフィールド（クエリパラメーターやCookieとの選択など）が指定されていない場合に使う
+func Required(field string) FieldError {
+	return FieldError{Field: field, Code: "required", Message: "is required"}
+}
+
+// validationMessage DTOのbindingタグで使っている検証ルールのメッセージ。それ以外は汎用のメッセージにする
+func validationMessage(fieldError validator.FieldError) string {
+	unit := ""
+	switch fieldError.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fieldError.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fieldError.Param(), unit)
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fieldError.Param()), ", ")
+	}
+	return "is invalid"
+}
+
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "a valid value"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return "a valid value"
+}
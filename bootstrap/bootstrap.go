@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"gin-fleamarket/config"
+	"gin-fleamarket/infra"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/services"
+	"log"
+	"os"
+)
+
+// 最初の管理者アカウントを作成する
+//
+//	BOOTSTRAP_ADMIN_PASSWORD=... go run ./bootstrap -email admin@example.com
+//
+// パスワードはシェルの履歴に残らないよう環境変数からも渡せる
+func main() {
+	email := flag.String("email", os.Getenv("BOOTSTRAP_ADMIN_EMAIL"), "email address of the initial admin")
+	password := flag.String("password", os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"), "password of the initial admin (min 8 characters)")
+	flag.Parse()
+
+	if *email == "" || len(*password) < 8 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := infra.OpenDB(config.MustLoad())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	bootstrapService := services.NewBootstrapService(repositories.NewAuthRepository(db), "")
+	if err := bootstrapService.CreateAdmin(*email, *password); err != nil {
+		log.Fatalf("Failed to create initial admin: %v", err)
+	}
+}
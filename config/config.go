@@ -0,0 +1,514 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvProd 本番環境を表すENVの値
+const EnvProd = "prod"
+
+// Config アプリケーション全体の設定
+// 優先順位は 既定値 < CONFIG_FILEのYAML < 環境変数（.envを含む）
+type Config struct {
+	Env         string `yaml:"env" json:"env"`
+	Port        string `yaml:"port" json:"port"`
+	AutoMigrate bool   `yaml:"auto_migrate" json:"auto_migrate"`
+	// SecretKey 移行期間中（Auth.LegacyHS256Untilまで）に旧HS256トークンを検証するための共有鍵
+	SecretKey string `yaml:"secret_key" json:"secret_key"`
+	// Lambda AWS Lambda Web Adapter上で動作しているか（AWS_LAMBDA_RUNTIME_APIの有無で判定し、YAMLでは指定しない）
+	Lambda bool `yaml:"-" json:"lambda"`
+
+	DB         DBConfig         `yaml:"db" json:"db"`
+	Auth       AuthConfig       `yaml:"auth" json:"auth"`
+	Cookie     CookieConfig     `yaml:"cookie" json:"cookie"`
+	Upload     UploadConfig     `yaml:"upload" json:"upload"`
+	Revocation RevocationConfig `yaml:"revocation" json:"revocation"`
+	Jobs       JobsConfig       `yaml:"jobs" json:"jobs"`
+	Log        LogConfig        `yaml:"log" json:"log"`
+	Metrics    MetricsConfig    `yaml:"metrics" json:"metrics"`
+	Tracing    TracingConfig    `yaml:"tracing" json:"tracing"`
+	OIDC       []OIDCProvider   `yaml:"oidc" json:"oidc"`
+}
+
+// DBConfig Driverを省略した場合は、Nameが設定されていればPostgreSQL、なければSQLiteを使う
+type DBConfig struct {
+	// Driver postgres / sqlite
+	Driver   string `yaml:"driver" json:"driver"`
+	Host     string `yaml:"host" json:"host"`
+	User     string `yaml:"user" json:"user"`
+	Password string `yaml:"password" json:"password"`
+	Name     string `yaml:"name" json:"name"`
+	Port     string `yaml:"port" json:"port"`
+	// SSLMode 省略時は本番環境ではrequire、それ以外はdisable
+	SSLMode string `yaml:"sslmode" json:"sslmode"`
+	// CreateDatabase Nameのデータベースがなければ、postgresデータベースに接続して作成する
+	CreateDatabase bool `yaml:"create_database" json:"create_database"`
+	// Path SQLiteのファイル。空の場合はインメモリ（テスト用）
+	Path string `yaml:"path" json:"path"`
+
+	MaxOpenConns     int           `yaml:"max_open_conns" json:"max_open_conns"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" json:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" json:"conn_max_lifetime"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" json:"conn_max_idle_time"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" json:"statement_timeout"`
+	// ConnectAttempts 起動時にDBへ接続できるまで試行する回数（間隔は指数的に延ばす）
+	ConnectAttempts int `yaml:"connect_attempts" json:"connect_attempts"`
+}
+
+type AuthConfig struct {
+	// JWTSigningAlg 新しく生成する署名鍵のアルゴリズム（RS256またはEdDSA）
+	JWTSigningAlg string `yaml:"jwt_signing_alg" json:"jwt_signing_alg"`
+	// AdminBootstrapToken 設定した場合のみPOST /auth/bootstrapが有効になる
+	AdminBootstrapToken string `yaml:"admin_bootstrap_token" json:"admin_bootstrap_token"`
+	// LegacyHS256Until SECRET_KEYで署名された旧HS256トークンを受け付ける期限。未設定の場合は受け付けない
+	// 署名鍵の導入時刻にリフレッシュトークンの有効期限（7日間）を足した時刻を指定する
+	LegacyHS256Until time.Time `yaml:"legacy_hs256_until" json:"legacy_hs256_until"`
+}
+
+type CookieConfig struct {
+	// Secure ローカル開発でhttpを使う場合のみfalseにする
+	Secure bool   `yaml:"secure" json:"secure"`
+	Domain string `yaml:"domain" json:"domain"`
+}
+
+type UploadConfig struct {
+	Dir string `yaml:"dir" json:"dir"`
+}
+
+type RevocationConfig struct {
+	// Store sql / redis / memory
+	Store    string `yaml:"store" json:"store"`
+	RedisURL string `yaml:"redis_url" json:"redis_url"`
+}
+
+type JobsConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// LogConfig Levelは起動時の値で、PUT /admin/log-levelで実行中に変更できる
+type LogConfig struct {
+	// Level debug / info / warn / error
+	Level string `yaml:"level" json:"level"`
+	// Format json / text（textはローカル開発向け）
+	Format string `yaml:"format" json:"format"`
+}
+
+type MetricsConfig struct {
+	// Token 設定した場合、/metricsにAuthorization: Bearer <token>を要求する
+	Token string `yaml:"token" json:"token"`
+}
+
+// TracingConfig 環境変数はOpenTelemetryの標準の名前（OTEL_*）に合わせる
+type TracingConfig struct {
+	// Exporter none / stdout（ローカル開発向け） / otlp
+	Exporter string `yaml:"exporter" json:"exporter"`
+	// Endpoint OTLP/HTTPのCollectorのベースURL（/v1/tracesは自動で付ける）
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+	// Headers Collectorに送るヘッダー（"key1=value1,key2=value2"）。認証情報を含むことがある
+	Headers     string `yaml:"headers" json:"headers"`
+	ServiceName string `yaml:"service_name" json:"service_name"`
+	// SampleRatio 親のないリクエストをサンプリングする割合（0〜1）。親がある場合は親に従う
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+}
+
+// OIDCProvider OpenID Connectプロバイダ1つ分の設定
+type OIDCProvider struct {
+	Name         string   `yaml:"name" json:"name"`
+	Issuer       string   `yaml:"issuer" json:"issuer"`
+	ClientID     string   `yaml:"client_id" json:"client_id"`
+	ClientSecret string   `yaml:"client_secret" json:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url" json:"redirect_url"`
+	Scopes       []string `yaml:"scopes" json:"scopes"`
+}
+
+// Default 何も設定しなかった場合の値
+func Default() *Config {
+	return &Config{
+		Port: "8080",
+		DB: DBConfig{
+			MaxOpenConns:     10,
+			MaxIdleConns:     5,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+			StatementTimeout: 30 * time.Second,
+			ConnectAttempts:  5,
+		},
+		Cookie:     CookieConfig{Secure: true},
+		Upload:     UploadConfig{Dir: "uploads"},
+		Revocation: RevocationConfig{Store: "sql"},
+		Jobs:       JobsConfig{Enabled: true},
+		Log:        LogConfig{Level: "info", Format: "json"},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "gin-fleamarket",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load .envとCONFIG_FILE（YAML）、環境変数から設定を読み込んで検証する
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found; using environment variables")
+	}
+
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// MustLoad コマンドの起動時に使い、設定に誤りがあれば起動を中止する
+func MustLoad() *Config {
+	cfg, err := Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	return cfg
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// 設定項目の綴り間違いに気付けるよう、未知のキーはエラーにする
+	decoder.KnownFields(true)
+	// 空のファイルは既定値のままにする
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	lookupString(&c.Env, "ENV")
+	lookupString(&c.Port, "PORT", "AWS_LWA_PORT")
+	lookupString(&c.SecretKey, "SECRET_KEY")
+	c.Lambda = os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+
+	lookupString(&c.DB.Driver, "DB_DRIVER")
+	lookupString(&c.DB.Host, "DB_HOST")
+	lookupString(&c.DB.User, "DB_USER")
+	lookupString(&c.DB.Password, "DB_PASSWORD")
+	lookupString(&c.DB.Name, "DB_NAME")
+	lookupString(&c.DB.Port, "DB_PORT")
+	lookupString(&c.DB.SSLMode, "DB_SSLMODE")
+	lookupString(&c.DB.Path, "DB_PATH")
+
+	lookupString(&c.Auth.JWTSigningAlg, "JWT_SIGNING_ALG")
+	lookupString(&c.Auth.AdminBootstrapToken, "ADMIN_BOOTSTRAP_TOKEN")
+	lookupString(&c.Cookie.Domain, "COOKIE_DOMAIN")
+	lookupString(&c.Upload.Dir, "UPLOAD_DIR")
+	lookupString(&c.Revocation.Store, "TOKEN_REVOCATION_STORE")
+	lookupString(&c.Revocation.RedisURL, "REDIS_URL")
+	lookupString(&c.Log.Level, "LOG_LEVEL")
+	lookupString(&c.Log.Format, "LOG_FORMAT")
+	lookupString(&c.Metrics.Token, "METRICS_TOKEN")
+	lookupString(&c.Tracing.Exporter, "OTEL_TRACES_EXPORTER")
+	lookupString(&c.Tracing.Endpoint, "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT")
+	lookupString(&c.Tracing.Headers, "OTEL_EXPORTER_OTLP_TRACES_HEADERS", "OTEL_EXPORTER_OTLP_HEADERS")
+	lookupString(&c.Tracing.ServiceName, "OTEL_SERVICE_NAME")
+	if err := lookupFloat(&c.Tracing.SampleRatio, "OTEL_TRACES_SAMPLER_ARG"); err != nil {
+		return err
+	}
+
+	for name, target := range map[string]*bool{
+		"AUTO_MIGRATE":       &c.AutoMigrate,
+		"DB_CREATE_DATABASE": &c.DB.CreateDatabase,
+		"COOKIE_SECURE":      &c.Cookie.Secure,
+		"JOBS_ENABLED":       &c.Jobs.Enabled,
+	} {
+		if err := lookupBool(target, name); err != nil {
+			return err
+		}
+	}
+	for name, target := range map[string]*int{
+		"DB_MAX_OPEN_CONNS":   &c.DB.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":   &c.DB.MaxIdleConns,
+		"DB_CONNECT_ATTEMPTS": &c.DB.ConnectAttempts,
+	} {
+		if err := lookupInt(target, name); err != nil {
+			return err
+		}
+	}
+	for name, target := range map[string]*time.Duration{
+		"DB_CONN_MAX_LIFETIME":  &c.DB.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME": &c.DB.ConnMaxIdleTime,
+		"DB_STATEMENT_TIMEOUT":  &c.DB.StatementTimeout,
+	} {
+		if err := lookupDuration(target, name); err != nil {
+			return err
+		}
+	}
+	if err := lookupTime(&c.Auth.LegacyHS256Until, "LEGACY_HS256_UNTIL"); err != nil {
+		return err
+	}
+	// DB_DRIVERを省略した場合は、従来どおりDB_NAMEの有無で判断する
+	if c.DB.Driver == "" {
+		c.DB.Driver = "sqlite"
+		if c.DB.Name != "" {
+			c.DB.Driver = "postgres"
+		}
+	}
+
+	c.loadOIDCEnv()
+	return nil
+}
+
+// loadOIDCEnv OIDC_PROVIDERS（カンマ区切り）に列挙されたプロバイダの設定を読み込む
+// 例: OIDC_PROVIDERS=google の場合は OIDC_GOOGLE_ISSUER, OIDC_GOOGLE_CLIENT_ID,
+// OIDC_GOOGLE_CLIENT_SECRET, OIDC_GOOGLE_REDIRECT_URL, OIDC_GOOGLE_SCOPES を参照する
+// YAMLにも同じ名前のプロバイダがある場合は、環境変数で設定された項目だけを上書きする
+func (c *Config) loadOIDCEnv() {
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		provider := c.oidcProvider(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		lookupString(&provider.Issuer, prefix+"ISSUER")
+		lookupString(&provider.ClientID, prefix+"CLIENT_ID")
+		lookupString(&provider.ClientSecret, prefix+"CLIENT_SECRET")
+		lookupString(&provider.RedirectURL, prefix+"REDIRECT_URL")
+		if s := os.Getenv(prefix + "SCOPES"); s != "" {
+			provider.Scopes = strings.Fields(strings.ReplaceAll(s, ",", " "))
+		}
+	}
+	for i := range c.OIDC {
+		if len(c.OIDC[i].Scopes) == 0 {
+			c.OIDC[i].Scopes = []string{"openid", "email", "profile"}
+		}
+	}
+}
+
+func (c *Config) oidcProvider(name string) *OIDCProvider {
+	for i := range c.OIDC {
+		if c.OIDC[i].Name == name {
+			return &c.OIDC[i]
+		}
+	}
+	c.OIDC = append(c.OIDC, OIDCProvider{Name: name})
+	return &c.OIDC[len(c.OIDC)-1]
+}
+
+// lookupString 最初に設定されている環境変数の値で上書きする
+func lookupString(target *string, names ...string) {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+			return
+		}
+	}
+}
+
+func lookupBool(target *bool, name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s must be true or false: %q", name, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupInt(target *int, name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a number: %q", name, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupFloat(target *float64, name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number: %q", name, value)
+	}
+	*target = parsed
+	return nil
+}
+
+// lookupDuration "30s"や"5m"のような形式で指定する
+func lookupDuration(target *time.Duration, name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a duration such as 30s: %q", name, value)
+	}
+	*target = parsed
+	return nil
+}
+
+// lookupTime "2026-11-01T00:00:00+09:00"のようなRFC 3339形式で指定する
+func lookupTime(target *time.Time, name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("%s must be an RFC 3339 time such as 2026-11-01T00:00:00+09:00: %q", name, value)
+	}
+	*target = parsed
+	return nil
+}
+
+// Validate 起動後に気付くと困る設定の誤りをまとめて返す
+func (c *Config) Validate() error {
+	var errs []error
+	if c.SecretKey == "" && time.Now().Before(c.Auth.LegacyHS256Until) {
+		errs = append(errs, errors.New("SECRET_KEY is required until LEGACY_HS256_UNTIL"))
+	}
+	if _, err := strconv.Atoi(c.Port); err != nil {
+		errs = append(errs, fmt.Errorf("PORT must be a number: %q", c.Port))
+	}
+	switch c.DB.Driver {
+	case "postgres":
+		if c.DB.Host == "" || c.DB.User == "" || c.DB.Name == "" {
+			errs = append(errs, errors.New("DB_HOST, DB_USER and DB_NAME are required for postgres"))
+		}
+		if _, err := strconv.Atoi(c.DB.Port); c.DB.Port != "" && err != nil {
+			errs = append(errs, fmt.Errorf("DB_PORT must be a number: %q", c.DB.Port))
+		}
+		switch c.DB.SSLMode {
+		case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			errs = append(errs, fmt.Errorf("DB_SSLMODE is not a valid sslmode: %q", c.DB.SSLMode))
+		}
+	case "sqlite":
+	default:
+		errs = append(errs, fmt.Errorf("DB_DRIVER must be postgres or sqlite: %q", c.DB.Driver))
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 || c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 || c.DB.StatementTimeout < 0 {
+		errs = append(errs, errors.New("DB pool limits and timeouts must not be negative"))
+	}
+	if c.DB.ConnectAttempts < 1 {
+		errs = append(errs, errors.New("DB_CONNECT_ATTEMPTS must be at least 1"))
+	}
+	switch c.Auth.JWTSigningAlg {
+	case "", "RS256", "EdDSA":
+	default:
+		errs = append(errs, fmt.Errorf("JWT_SIGNING_ALG must be RS256 or EdDSA: %q", c.Auth.JWTSigningAlg))
+	}
+	switch c.Revocation.Store {
+	case "sql", "memory":
+	case "redis":
+		if c.Revocation.RedisURL == "" {
+			errs = append(errs, errors.New("REDIS_URL is required when TOKEN_REVOCATION_STORE is redis"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("TOKEN_REVOCATION_STORE must be sql, redis or memory: %q", c.Revocation.Store))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error: %q", c.Log.Level))
+	}
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be json or text: %q", c.Log.Format))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT must be an http(s) URL: %q", c.Tracing.Endpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_EXPORTER must be none, stdout or otlp: %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1: %v", c.Tracing.SampleRatio))
+	}
+	if c.Upload.Dir == "" {
+		errs = append(errs, errors.New("UPLOAD_DIR must not be empty"))
+	}
+	for _, provider := range c.OIDC {
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			errs = append(errs, fmt.Errorf("OIDC provider %s requires issuer, client_id and redirect_url", provider.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) IsProd() bool {
+	return c.Env == EnvProd
+}
+
+// redactedValue 秘密情報は設定されているかどうかだけが分かるようにする
+const redactedValue = "[REDACTED]"
+
+// Redacted 管理者向けに出力するため、秘密情報を伏せたコピーを返す
+func (c *Config) Redacted() Config {
+	redacted := *c
+	redacted.SecretKey = redact(c.SecretKey)
+	redacted.DB.Password = redact(c.DB.Password)
+	redacted.Auth.AdminBootstrapToken = redact(c.Auth.AdminBootstrapToken)
+	redacted.Revocation.RedisURL = redactURL(c.Revocation.RedisURL)
+	redacted.Metrics.Token = redact(c.Metrics.Token)
+	redacted.Tracing.Headers = redact(c.Tracing.Headers)
+	redacted.OIDC = make([]OIDCProvider, len(c.OIDC))
+	for i, provider := range c.OIDC {
+		provider.ClientSecret = redact(provider.ClientSecret)
+		redacted.OIDC[i] = provider
+	}
+	return redacted
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// redactURL 接続先は確認できるよう、URLに含まれるパスワードだけを伏せる
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return redact(value)
+	}
+	return u.Redacted()
+}
@@ -1,16 +1,161 @@
 package constants
 
-// ユーザーロール
+// ユーザーロール（組み込み）
 const (
-	RoleAdmin = "admin"
-	RoleUser  = "user"
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleUser      = "user"
+)
+
+// アカウントの状態
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+	// UserStatusDeleted 退会済み（個人情報は匿名化されている）
+	UserStatusDeleted = "deleted"
+)
+
+// バックグラウンドジョブの実行状態
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// ヘルスチェックの結果
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+	// HealthStatusDisabled 無効にしている依存先（準備完了の判定には影響しない）
+	HealthStatusDisabled = "disabled"
+)
+
+// 権限（ロールに割り当てる）
+const (
+	PermItemDeleteAny   = "item.delete.any"
+	PermUserBan         = "user.ban"
+	PermUserManage      = "user.manage"
+	PermUserImpersonate = "user.impersonate"
+	PermAuditRead       = "audit.read"
+	PermOrderRefund     = "order.refund"
+	PermCategoryManage  = "category.manage"
+	PermRoleManage      = "role.manage"
+	PermSystemManage    = "system.manage"
+)
+
+// ブラウザ向けのCookie認証で使うCookieとヘッダーの名前
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	// CSRFTokenCookie JavaScriptから読めるようにHttpOnlyにしない（ダブルサブミット用）
+	CSRFTokenCookie = "csrf_token"
+	CSRFTokenHeader = "X-CSRF-Token"
+	// OIDCStateCookie OIDCログインのstateをログインを開始したブラウザに結びつける
+	OIDCStateCookie = "oidc_state"
+)
+
+// RequestIDHeader クライアントやロードバランサーが付けたリクエストIDを引き継ぎ、応答にも付ける
+const RequestIDHeader = "X-Request-ID"
+
+// APIキーのスコープ
+const (
+	ScopeItemsRead  = "items:read"
+	ScopeItemsWrite = "items:write"
+	ScopeOrdersRead = "orders:read"
+)
+
+// 監査ログのアクション
+const (
+	AuditSignup               = "auth.signup"
+	AuditLoginSuccess         = "auth.login.success"
+	AuditLoginFailure         = "auth.login.failure"
+	AuditLogout               = "auth.logout"
+	AuditRefresh              = "auth.refresh"
+	AuditRefreshReuse         = "auth.refresh.reuse"
+	AuditPasswordChange       = "auth.password.change"
+	AuditAccountExport        = "account.export"
+	AuditAccountDelete        = "account.delete"
+	AuditUserRoleChange       = "user.role.change"
+	AuditUserStatusChange     = "user.status.change"
+	AuditItemModerationDelete = "item.delete.moderation"
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonationRequest = "impersonation.request"
 )
 
 // エラーメッセージ
 const (
-	ErrItemNotFound   = "Item not found"
-	ErrUnexpected     = "Unexpected error"
-	ErrInvalidID      = "Invalid id"
-	ErrInvalidInput   = "Invalid input"
-	ErrRecordNotFound = "record not found"
+	ErrItemNotFound  = "Item not found"
+	ErrForbidden     = "Forbidden"
+	ErrUnexpected    = "Unexpected error"
+	ErrInvalidID     = "Invalid id"
+	ErrInvalidInput  = "Invalid input"
+	ErrMalformedBody = "Request body is not valid JSON"
+	ErrNotFound      = "Resource not found"
+	ErrUnavailable   = "Service is temporarily unavailable"
+
+	ErrUnauthorized       = "Authentication required"
+	ErrInvalidToken       = "Invalid or expired token"
+	ErrInvalidCredentials = "Invalid email or password"
+	ErrEmailAlreadyExists = "Email already exists"
+	ErrInsufficientScope  = "API key does not have the required scope"
+
+	ErrUserNotFound  = "User not found"
+	ErrUserSuspended = "User account is suspended"
+	ErrUserBanned    = "User account is banned"
+	ErrLastAdmin     = "Cannot remove the last remaining admin"
+	ErrWrongPassword = "Current password is incorrect"
+
+	ErrCannotManageSelf      = "Cannot change your own role or status"
+	ErrInsufficientPrivilege = "Cannot manage users or roles with permissions you do not have"
+
+	ErrInvalidPrefecture = "Invalid prefecture"
+	ErrInvalidAvatar     = "Avatar must be a JPEG, PNG or WebP image"
+	ErrAvatarTooLarge    = "Avatar image is too large"
+
+	ErrNoFieldsToUpdate = "No fields to update"
+
+	ErrImpersonationNotAllowed = "This user cannot be impersonated"
+	ErrImpersonationReadOnly   = "Impersonated session is read-only"
+
+	ErrAdminAlreadyExists    = "Admin account already exists"
+	ErrBootstrapDisabled     = "Admin bootstrap is disabled"
+	ErrInvalidBootstrapToken = "Invalid bootstrap token"
+
+	ErrInvalidCSRFToken = "Invalid CSRF token"
+
+	ErrSessionNotFound    = "Session not found"
+	ErrSessionRevoked     = "Session has been revoked"
+	ErrRefreshTokenReused = "Refresh token reuse detected"
+
+	ErrAPIKeyNotFound     = "API key not found"
+	ErrAPIKeyInvalidScope = "Invalid API key scope"
+
+	ErrRoleNotFound       = "Role not found"
+	ErrRoleAlreadyExists  = "Role already exists"
+	ErrRoleInUse          = "Role is assigned to users"
+	ErrRoleBuiltin        = "Built-in admin role cannot be modified"
+	ErrPermissionNotFound = "Permission not found"
+
+	ErrJobNotFound = "Job not found"
+	ErrJobBusy     = "Job is already running"
+
+	ErrInvalidLogLevel = "Log level must be debug, info, warn or error"
+
+	ErrOIDCProviderNotFound = "OIDC provider not found"
+	ErrOIDCInvalidState     = "Invalid OIDC state"
+	ErrOIDCEmailNotVerified = "Email is not verified by the identity provider"
+	ErrOIDCFailed           = "OIDC authentication failed"
+	ErrOIDCUnavailable      = "Failed to start OIDC login"
 )
+
+// Prefectures プロフィールに設定できる都道府県
+var Prefectures = []string{
+	"北海道", "青森県", "岩手県", "宮城県", "秋田県", "山形県", "福島県",
+	"茨城県", "栃木県", "群馬県", "埼玉県", "千葉県", "東京都", "神奈川県",
+	"新潟県", "富山県", "石川県", "福井県", "山梨県", "長野県", "岐阜県",
+	"静岡県", "愛知県", "三重県", "滋賀県", "京都府", "大阪府", "兵庫県",
+	"奈良県", "和歌山県", "鳥取県", "島根県", "岡山県", "広島県", "山口県",
+	"徳島県", "香川県", "愛媛県", "高知県", "福岡県", "佐賀県", "長崎県",
+	"熊本県", "大分県", "宮崎県", "鹿児島県", "沖縄県",
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"fmt"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IAccountController interface {
+	Export(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+type AccountController struct {
+	service services.IAccountService
+}
+
+func NewAccountController(service services.IAccountService) IAccountController {
+	return &AccountController{service: service}
+}
+
+// Export 保持している個人データをJSONファイルとしてダウンロードさせる
+func (c *AccountController) Export(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	userID := user.(*models.User).ID
+	export, err := c.service.Export(userID, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%d-export.json"`, userID))
+	ctx.JSON(http.StatusOK, gin.H{"data": export})
+}
+
+// Delete 退会する。成功後は既存のトークンとAPIキーが使えなくなる
+func (c *AccountController) Delete(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	if err := c.service.Delete(user.(*models.User).ID, clientInfo(ctx)); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IAPIKeyController interface {
+	FindAll(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+type APIKeyController struct {
+	service services.IAPIKeyService
+}
+
+func NewAPIKeyController(service services.IAPIKeyService) IAPIKeyController {
+	return &APIKeyController{service: service}
+}
+
+func (c *APIKeyController) FindAll(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+
+	userID := user.(*models.User).ID
+
+	apiKeys, err := c.service.FindAll(userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": apiKeys})
+}
+
+func (c *APIKeyController) Create(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	userID := user.(*models.User).ID
+
+	var input dto.CreateAPIKeyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	apiKey, err := c.service.Create(userID, input)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"data": apiKey})
+}
+
+func (c *APIKeyController) Delete(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	userID := user.(*models.User).ID
+
+	apiKeyID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperrors.ErrInvalidID)
+		return
+	}
+
+	err = c.service.Delete(uint(apiKeyID), userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
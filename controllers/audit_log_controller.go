@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IAuditLogController interface {
+	FindAll(ctx *gin.Context)
+	Verify(ctx *gin.Context)
+}
+
+type AuditLogController struct {
+	service services.IAuditService
+}
+
+func NewAuditLogController(service services.IAuditService) IAuditLogController {
+	return &AuditLogController{service: service}
+}
+
+func (c *AuditLogController) FindAll(ctx *gin.Context) {
+	var query dto.AuditLogQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	entries, meta, err := c.service.Search(query)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": entries, "meta": meta})
+}
+
+// Verify ハッシュチェーンを検証する。改ざんを検出した場合もレスポンスは200で、validがfalseになる
+func (c *AuditLogController) Verify(ctx *gin.Context) {
+	result, err := c.service.Verify()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": result})
+}
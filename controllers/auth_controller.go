@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
 	"gin-fleamarket/dto"
+	"gin-fleamarket/logging"
+	"gin-fleamarket/models"
 	"gin-fleamarket/services"
-	"log"
 	"net/http"
 	"strings"
 
@@ -15,98 +18,152 @@ type IAuthController interface {
 	Login(ctx *gin.Context)
 	RefreshToken(ctx *gin.Context)
 	Logout(ctx *gin.Context)
+	ChangePassword(ctx *gin.Context)
 }
 
 type AuthController struct {
 	service services.IAuthService
+	cookies CookieOptions
 }
 
-func NewAuthController(service services.IAuthService) IAuthController {
-	return &AuthController{service: service}
+func NewAuthController(service services.IAuthService, cookies CookieOptions) IAuthController {
+	return &AuthController{service: service, cookies: cookies}
 }
 
 func (c *AuthController) Signup(ctx *gin.Context) {
 	var input dto.SignupInput
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	err := c.service.Signup(input.Email, input.Password)
+	err := c.service.Signup(input.Email, input.Password, clientInfo(ctx))
 	if err != nil {
-		log.Printf("Signup error: %v", err)
-		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "UNIQUE constraint") {
-			ctx.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected error"})
+		ctx.Error(err)
 		return
 	}
 	ctx.Status(http.StatusCreated)
 }
 
+// Login ?mode=cookie の場合はトークンをレスポンスボディではなくHttpOnlyのCookieで返す（ブラウザ向け）
 func (c *AuthController) Login(ctx *gin.Context) {
 	var input dto.LoginInput
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	tokenPair, err := c.service.Login(input.Email, input.Password)
+	tokenPair, err := c.service.Login(input.Email, input.Password, clientInfo(ctx))
 	if err != nil {
-		if err.Error() == "User not found" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected error"})
+		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, dto.LoginResponse{
-		AccessToken:  tokenPair.AccessToken,
-		RefreshToken: tokenPair.RefreshToken,
-	})
+	c.respondTokenPair(ctx, tokenPair, ctx.Query("mode") == "cookie", false)
 }
 
+// RefreshToken ボディにリフレッシュトークンがなければrefresh_token Cookieを使い、新しいトークンもCookieで返す
 func (c *AuthController) RefreshToken(ctx *gin.Context) {
 	var input dto.RefreshTokenInput
-	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			ctx.Error(apperrors.InvalidInput(err))
+			return
+		}
+	}
+	fromCookie := false
+	if input.RefreshToken == "" {
+		input.RefreshToken, _ = ctx.Cookie(constants.RefreshTokenCookie)
+		fromCookie = true
+	}
+	if input.RefreshToken == "" {
+		ctx.Error(apperrors.ErrInvalidInput.WithFields(apperrors.Required("refreshToken")))
 		return
 	}
 
-	tokenPair, err := c.service.RefreshToken(input.RefreshToken)
+	tokenPair, err := c.service.RefreshToken(input.RefreshToken, clientInfo(ctx))
 	if err != nil {
-		if err.Error() == "token is expired" || err.Error() == "invalid refresh token" || err.Error() == "invalid token type" || err.Error() == "refresh token is blacklisted" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
+	}
+	c.respondTokenPair(ctx, tokenPair, fromCookie, true)
+}
+
+// Logout Authorizationヘッダーがなければaccess_token Cookieのトークンを失効させ、Cookieを削除する
+func (c *AuthController) Logout(ctx *gin.Context) {
+	var tokenString string
+	if header := ctx.GetHeader("Authorization"); header != "" {
+		if !strings.HasPrefix(header, "Bearer ") {
+			ctx.Error(apperrors.ErrInvalidToken)
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected error"})
+		tokenString = strings.TrimPrefix(header, "Bearer ")
+	} else if cookie, err := ctx.Cookie(constants.AccessTokenCookie); err == nil && cookie != "" {
+		tokenString = cookie
+		c.cookies.clearSessionCookies(ctx.Writer)
+	} else {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+
+	err := c.service.Logout(tokenString, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, dto.LoginResponse{
-		AccessToken:  tokenPair.AccessToken,
-		RefreshToken: tokenPair.RefreshToken,
-	})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
 }
 
-func (c *AuthController) Logout(ctx *gin.Context) {
-	header := ctx.GetHeader("Authorization")
-	if header == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+// ChangePassword 変更後はすべてのセッションが失効するため、再ログインが必要
+func (c *AuthController) ChangePassword(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	var input dto.ChangePasswordInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	if !strings.HasPrefix(header, "Bearer ") {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+	err := c.service.ChangePassword(user.(*models.User), input.CurrentPassword, input.NewPassword, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	tokenString := strings.TrimPrefix(header, "Bearer ")
-	err := c.service.Logout(tokenString)
+	ctx.Status(http.StatusNoContent)
+}
+
+// respondTokenPair Cookie認証モードではトークンをCookieに設定し、ボディにはCSRFトークンだけを返す
+// keepCSRFTokenはリフレッシュの時だけtrueにする。ログインでは第三者に仕込まれたCookieを引き継がないよう、必ず作り直す
+func (c *AuthController) respondTokenPair(ctx *gin.Context, tokenPair *services.TokenPair, useCookies bool, keepCSRFToken bool) {
+	if !useCookies {
+		ctx.JSON(http.StatusOK, dto.LoginResponse{
+			AccessToken:  tokenPair.AccessToken,
+			RefreshToken: tokenPair.RefreshToken,
+		})
+		return
+	}
+
+	csrfToken := ""
+	if keepCSRFToken {
+		csrfToken, _ = ctx.Cookie(constants.CSRFTokenCookie)
+	}
+	csrfToken, err := c.cookies.setSessionCookies(ctx.Writer, tokenPair, csrfToken)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		ctx.Error(err)
 		return
 	}
+	ctx.JSON(http.StatusOK, dto.CookieLoginResponse{CSRFToken: csrfToken})
+}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
+// clientInfo セッションに記録するリクエスト元の情報を取り出す
+func clientInfo(ctx *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		IPAddress: ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		RequestID: logging.RequestID(ctx.Request.Context()),
+	}
 }
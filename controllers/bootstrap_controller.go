@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IBootstrapController interface {
+	CreateAdmin(ctx *gin.Context)
+}
+
+type BootstrapController struct {
+	service services.IBootstrapService
+}
+
+func NewBootstrapController(service services.IBootstrapService) IBootstrapController {
+	return &BootstrapController{service: service}
+}
+
+// CreateAdmin Authorization: Bootstrap <token> で最初の管理者を作成する
+func (c *BootstrapController) CreateAdmin(ctx *gin.Context) {
+	header := ctx.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bootstrap ") {
+		ctx.Error(apperrors.ErrInvalidBootstrapToken)
+		return
+	}
+
+	var input dto.SignupInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	err := c.service.CreateAdminWithToken(strings.TrimPrefix(header, "Bootstrap "), input.Email, input.Password)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.Status(http.StatusCreated)
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"gin-fleamarket/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IConfigController interface {
+	Show(ctx *gin.Context)
+}
+
+type ConfigController struct {
+	config *config.Config
+}
+
+func NewConfigController(cfg *config.Config) IConfigController {
+	return &ConfigController{config: cfg}
+}
+
+// Show 起動時に読み込んだ設定を返す（パスワードやトークンなどの秘密情報は伏せる）
+func (c *ConfigController) Show(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, gin.H{"data": c.config.Redacted()})
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"gin-fleamarket/constants"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IHealthController interface {
+	Live(ctx *gin.Context)
+	Ready(ctx *gin.Context)
+	Detail(ctx *gin.Context)
+}
+
+type HealthController struct {
+	service services.IHealthService
+}
+
+func NewHealthController(service services.IHealthService) IHealthController {
+	return &HealthController{service: service}
+}
+
+// Live プロセスが応答できるか。依存先は確認しない（依存先の障害で再起動させないため）
+func (c *HealthController) Live(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, gin.H{"status": constants.HealthStatusOK})
+}
+
+// Ready リクエストを受け付けられるか。依存先のどれかが使えない場合は503を返す
+func (c *HealthController) Ready(ctx *gin.Context) {
+	response := c.service.Ready(ctx.Request.Context())
+	code := http.StatusOK
+	if response.Status != constants.HealthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(code, response)
+}
+
+func (c *HealthController) Detail(ctx *gin.Context) {
+	detail, err := c.service.Detail(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, gin.H{"data": detail})
+}
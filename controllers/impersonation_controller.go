@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IImpersonationController interface {
+	Start(ctx *gin.Context)
+}
+
+type ImpersonationController struct {
+	service services.IImpersonationService
+}
+
+func NewImpersonationController(service services.IImpersonationService) IImpersonationController {
+	return &ImpersonationController{service: service}
+}
+
+func (c *ImpersonationController) Start(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+
+	targetID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	var input dto.ImpersonateInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	token, err := c.service.Start(user.(*models.User), targetID, input, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"data": token})
+}
@@ -1,11 +1,11 @@
 package controllers
 
 import (
+	"gin-fleamarket/apperrors"
 	"gin-fleamarket/constants"
 	"gin-fleamarket/dto"
 	"gin-fleamarket/models"
 	"gin-fleamarket/services"
-	"log"
 	"net/http"
 	"strconv"
 
@@ -28,10 +28,25 @@ func NewItemController(service services.IItemService) IItemController {
 	return &ItemController{service: service}
 }
 
+// actingUser 商品の権限判定に使うユーザーを取り出す（未ログインの場合はnil）
+// なりすまし中は対象ユーザーのロールによるモデレーション権限を使わせず、本人としての操作のみ許可する
+func actingUser(ctx *gin.Context) *models.User {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil
+	}
+	if _, impersonated := ctx.Get("impersonation"); impersonated {
+		restricted := *user.(*models.User)
+		restricted.Role = constants.RoleUser
+		return &restricted
+	}
+	return user.(*models.User)
+}
+
 func (c *ItemController) FindAll(ctx *gin.Context) {
-	items, err := c.service.FindAll()
+	items, err := c.service.FindAll(ctx.Request.Context(), actingUser(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": constants.ErrUnexpected})
+		ctx.Error(err)
 		return
 	}
 
@@ -39,27 +54,15 @@ func (c *ItemController) FindAll(ctx *gin.Context) {
 }
 
 func (c *ItemController) FindById(ctx *gin.Context) {
-	user, exists := ctx.Get("user")
-	if !exists {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
-
-	userID := user.(*models.User).ID
-
 	itemID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": constants.ErrInvalidID})
+		ctx.Error(apperrors.ErrInvalidID)
 		return
 	}
 
-	item, err := c.service.FindById(uint(itemID), userID)
+	item, err := c.service.FindById(ctx.Request.Context(), uint(itemID), actingUser(ctx))
 	if err != nil {
-		if err.Error() == constants.ErrItemNotFound {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": constants.ErrItemNotFound})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": constants.ErrUnexpected})
+		ctx.Error(err)
 		return
 	}
 
@@ -69,7 +72,7 @@ func (c *ItemController) FindById(ctx *gin.Context) {
 func (c *ItemController) Create(ctx *gin.Context) {
 	user, exists := ctx.Get("user")
 	if !exists {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		ctx.Error(apperrors.ErrUnauthorized)
 		return
 	}
 
@@ -77,14 +80,13 @@ func (c *ItemController) Create(ctx *gin.Context) {
 
 	var input dto.CreateItemInput
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": constants.ErrInvalidInput})
+		ctx.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	newItem, err := c.service.Create(input, userID)
+	newItem, err := c.service.Create(ctx.Request.Context(), input, userID)
 	if err != nil {
-		log.Printf("Create item error: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": constants.ErrUnexpected})
+		ctx.Error(err)
 		return
 	}
 
@@ -92,32 +94,25 @@ func (c *ItemController) Create(ctx *gin.Context) {
 }
 
 func (c *ItemController) Update(ctx *gin.Context) {
-	user, exists := ctx.Get("user")
-	if !exists {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+	if _, exists := ctx.Get("user"); !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
 		return
 	}
 
-	userID := user.(*models.User).ID
-
 	itemID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": constants.ErrInvalidID})
+		ctx.Error(apperrors.ErrInvalidID)
 		return
 	}
 	var input dto.UpdateItemInput
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": constants.ErrInvalidInput})
+		ctx.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	updatedItem, err := c.service.Update(uint(itemID), userID, input)
+	updatedItem, err := c.service.Update(ctx.Request.Context(), uint(itemID), actingUser(ctx), input)
 	if err != nil {
-		if err.Error() == constants.ErrItemNotFound {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": constants.ErrItemNotFound})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": constants.ErrUnexpected})
+		ctx.Error(err)
 		return
 	}
 
@@ -125,20 +120,21 @@ func (c *ItemController) Update(ctx *gin.Context) {
 }
 
 func (c *ItemController) Delete(ctx *gin.Context) {
+	if _, exists := ctx.Get("user"); !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+
 	itemID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": constants.ErrInvalidID})
+		ctx.Error(apperrors.ErrInvalidID)
 		return
 	}
 
-	err = c.service.Delete(uint(itemID))
+	err = c.service.Delete(ctx.Request.Context(), uint(itemID), actingUser(ctx), clientInfo(ctx))
 
 	if err != nil {
-		if err.Error() == constants.ErrItemNotFound {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": constants.ErrItemNotFound})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": constants.ErrUnexpected})
+		ctx.Error(err)
 		return
 	}
 
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IJobController interface {
+	FindAll(ctx *gin.Context)
+	FindRuns(ctx *gin.Context)
+	Run(ctx *gin.Context)
+}
+
+type JobController struct {
+	service services.IJobService
+}
+
+func NewJobController(service services.IJobService) IJobController {
+	return &JobController{service: service}
+}
+
+func (c *JobController) FindAll(ctx *gin.Context) {
+	jobs, err := c.service.FindAll()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// FindRuns 新しい順の実行履歴
+func (c *JobController) FindRuns(ctx *gin.Context) {
+	var query dto.JobRunQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	runs, meta, err := c.service.FindRuns(ctx.Param("name"), query)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": runs, "meta": meta})
+}
+
+// Run ジョブをすぐに実行する。クライアントが切断してもジョブは中断しない（ジョブごとのタイムアウトまで実行する）
+func (c *JobController) Run(ctx *gin.Context) {
+	run, err := c.service.Run(context.WithoutCancel(ctx.Request.Context()), ctx.Param("name"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": run})
+}
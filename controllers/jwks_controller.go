@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IJWKSController interface {
+	PublicKeys(ctx *gin.Context)
+	Rotate(ctx *gin.Context)
+}
+
+type JWKSController struct {
+	keyRing services.IKeyRing
+}
+
+func NewJWKSController(keyRing services.IKeyRing) IJWKSController {
+	return &JWKSController{keyRing: keyRing}
+}
+
+// PublicKeys 他サービスがトークンを検証するための公開鍵セットを返す
+func (c *JWKSController) PublicKeys(ctx *gin.Context) {
+	keySet, err := c.keyRing.PublicKeys()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, keySet)
+}
+
+// Rotate 署名鍵をローテーションする（旧鍵は発行済みトークンの有効期限まで検証に使われる）
+func (c *JWKSController) Rotate(ctx *gin.Context) {
+	if err := c.keyRing.Rotate(); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/logging"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ILogLevelController interface {
+	Show(ctx *gin.Context)
+	Update(ctx *gin.Context)
+}
+
+type LogLevelController struct{}
+
+func NewLogLevelController() ILogLevelController {
+	return &LogLevelController{}
+}
+
+func (c *LogLevelController) Show(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"data": logLevelResponse()})
+}
+
+// Update 再起動せずにログレベルを変更する。変更はこのインスタンスのみに反映され、再起動するとLOG_LEVELに戻る
+func (c *LogLevelController) Update(ctx *gin.Context) {
+	var input dto.UpdateLogLevelInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	previous := logging.Level()
+	if err := logging.SetLevel(input.Level); err != nil {
+		ctx.Error(apperrors.ErrInvalidLogLevel)
+		return
+	}
+	slog.WarnContext(ctx.Request.Context(), "Changed log level",
+		"user_id", actorID(ctx), "from", previous.String(), "to", logging.Level().String())
+
+	ctx.JSON(http.StatusOK, gin.H{"data": logLevelResponse()})
+}
+
+func logLevelResponse() dto.LogLevelResponse {
+	return dto.LogLevelResponse{Level: strings.ToLower(logging.Level().String())}
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/metrics"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IMetricsController interface {
+	Export(ctx *gin.Context)
+}
+
+type MetricsController struct {
+	// token 設定した場合はAuthorization: Bearer <token>を要求する（API Gatewayなどで公開される環境向け）
+	token string
+}
+
+func NewMetricsController(token string) IMetricsController {
+	return &MetricsController{token: token}
+}
+
+// Export Prometheusのテキスト形式で出力する
+func (c *MetricsController) Export(ctx *gin.Context) {
+	if c.token != "" {
+		provided := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(c.token)) != 1 {
+			ctx.Error(apperrors.ErrUnauthorized)
+			return
+		}
+	}
+
+	ctx.Header("Content-Type", metrics.ContentType)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(http.StatusOK)
+	if err := metrics.Default.WriteText(ctx.Writer); err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "Failed to write metrics", "error", err)
+	}
+}
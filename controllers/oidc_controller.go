@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IOIDCController interface {
+	Login(ctx *gin.Context)
+	Callback(ctx *gin.Context)
+}
+
+// oidcStateCookiePath stateのCookieはコールバックの時だけ送信されるようにする
+const oidcStateCookiePath = "/auth/oidc"
+
+type OIDCController struct {
+	service services.IOIDCService
+	cookies CookieOptions
+}
+
+func NewOIDCController(service services.IOIDCService, cookies CookieOptions) IOIDCController {
+	return &OIDCController{service: service, cookies: cookies}
+}
+
+// Login プロバイダの認可エンドポイントへリダイレクトする
+// stateをHttpOnlyのCookieにも設定し、他人が開始したログインのコールバックを踏ませるログインCSRFを防ぐ
+func (c *OIDCController) Login(ctx *gin.Context) {
+	authURL, state, err := c.service.AuthorizationURL(ctx.Request.Context(), ctx.Param("provider"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	http.SetCookie(ctx.Writer, c.cookies.cookie(constants.OIDCStateCookie, state, oidcStateCookiePath, int(services.OIDCLoginStateTTL.Seconds()), true))
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 認可コードを検証し、自サービスのトークンペアを返す
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	if providerError := ctx.Query("error"); providerError != "" {
+		ctx.Error(apperrors.ErrOIDCFailed.Wrap(fmt.Errorf("provider returned error: %s", providerError)))
+		return
+	}
+
+	code := ctx.Query("code")
+	state := ctx.Query("state")
+	var fields []apperrors.FieldError
+	if code == "" {
+		fields = append(fields, apperrors.Required("code"))
+	}
+	if state == "" {
+		fields = append(fields, apperrors.Required("state"))
+	}
+	if len(fields) > 0 {
+		ctx.Error(apperrors.ErrInvalidInput.WithFields(fields...))
+		return
+	}
+
+	// ログインを開始したブラウザ以外からのコールバックは受け付けない
+	browserState, _ := ctx.Cookie(constants.OIDCStateCookie)
+	http.SetCookie(ctx.Writer, c.cookies.cookie(constants.OIDCStateCookie, "", oidcStateCookiePath, -1, true))
+	if subtle.ConstantTimeCompare([]byte(browserState), []byte(state)) != 1 {
+		ctx.Error(apperrors.ErrOIDCInvalidState)
+		return
+	}
+
+	tokenPair, err := c.service.HandleCallback(ctx.Request.Context(), ctx.Param("provider"), code, state, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, dto.LoginResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+	})
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"encoding/json"
+	"gin-fleamarket/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IOpenAPIController interface {
+	Spec(ctx *gin.Context)
+	Docs(ctx *gin.Context)
+}
+
+type OpenAPIController struct {
+	// spec ルートの一覧は起動後に変わらないため、起動時に1度だけJSONにする
+	spec []byte
+}
+
+func NewOpenAPIController(doc *openapi.Document) IOpenAPIController {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		panic("Failed to encode OpenAPI document: " + err.Error())
+	}
+	return &OpenAPIController{spec: spec}
+}
+
+// Spec OpenAPI 3.1のドキュメントを返す
+func (c *OpenAPIController) Spec(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", c.spec)
+}
+
+// Docs /openapi.jsonを表示するSwagger UI
+func (c *OpenAPIController) Docs(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", openapi.SwaggerUI)
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IProfileController interface {
+	FindMine(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	UpdateAvatar(ctx *gin.Context)
+	FindPublic(ctx *gin.Context)
+}
+
+type ProfileController struct {
+	service services.IProfileService
+}
+
+func NewProfileController(service services.IProfileService) IProfileController {
+	return &ProfileController{service: service}
+}
+
+func (c *ProfileController) FindMine(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+
+	profile, err := c.service.FindMine(user.(*models.User).ID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+func (c *ProfileController) Update(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	var input dto.UpdateProfileInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	profile, err := c.service.Update(user.(*models.User).ID, input)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+// UpdateAvatar multipart/form-dataの"avatar"フィールドで画像を受け取る
+func (c *ProfileController) UpdateAvatar(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	// 大きすぎるリクエストはフォームの解析前に打ち切る（multipartのヘッダー分の余裕を持たせる）
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, services.MaxAvatarSize+64<<10)
+	fileHeader, err := ctx.FormFile("avatar")
+	if err != nil {
+		if _, tooLarge := err.(*http.MaxBytesError); tooLarge {
+			ctx.Error(apperrors.ErrAvatarTooLarge)
+			return
+		}
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+	defer file.Close()
+
+	profile, err := c.service.UpdateAvatar(user.(*models.User).ID, file)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+// FindPublic 出品者ページ用の公開プロフィールと出品中の商品を返す（認証不要）
+func (c *ProfileController) FindPublic(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	profile, err := c.service.FindPublic(userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": profile})
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IRoleController interface {
+	FindAll(ctx *gin.Context)
+	FindAllPermissions(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+type RoleController struct {
+	service services.IRoleService
+}
+
+func NewRoleController(service services.IRoleService) IRoleController {
+	return &RoleController{service: service}
+}
+
+func (c *RoleController) FindAll(ctx *gin.Context) {
+	roles, err := c.service.FindAll()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+func (c *RoleController) FindAllPermissions(ctx *gin.Context) {
+	permissions, err := c.service.FindAllPermissions()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := make([]gin.H, 0, len(*permissions))
+	for _, permission := range *permissions {
+		response = append(response, gin.H{"name": permission.Name, "description": permission.Description})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": response})
+}
+
+func (c *RoleController) Create(ctx *gin.Context) {
+	var input dto.CreateRoleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	role, err := c.service.Create(input)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"data": role})
+}
+
+func (c *RoleController) Update(ctx *gin.Context) {
+	var input dto.UpdateRoleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	role, err := c.service.Update(ctx.Param("name"), input)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": role})
+}
+
+func (c *RoleController) Delete(ctx *gin.Context) {
+	if err := c.service.Delete(ctx.Param("name")); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ISessionController interface {
+	FindAll(ctx *gin.Context)
+	Revoke(ctx *gin.Context)
+}
+
+type SessionController struct {
+	service services.ISessionService
+}
+
+func NewSessionController(service services.ISessionService) ISessionController {
+	return &SessionController{service: service}
+}
+
+func (c *SessionController) FindAll(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+
+	userID := user.(*models.User).ID
+
+	sessions, err := c.service.FindActive(userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+func (c *SessionController) Revoke(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.Error(apperrors.ErrUnauthorized)
+		return
+	}
+	userID := user.(*models.User).ID
+
+	sessionID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperrors.ErrInvalidID)
+		return
+	}
+
+	err = c.service.Revoke(uint(sessionID), userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
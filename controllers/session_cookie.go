@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/services"
+	"net/http"
+)
+
+// CookieOptions Cookie認証モードで発行するCookieの属性
+type CookieOptions struct {
+	// Secure ローカル開発（http）以外では必ずtrueにする
+	Secure bool
+	Domain string
+}
+
+// refreshTokenCookiePath リフレッシュトークンはトークンの更新とログアウトの時だけ送信されるようにする
+const refreshTokenCookiePath = "/auth"
+
+// setSessionCookies アクセストークンとリフレッシュトークンをHttpOnlyのCookieに設定し、CSRFトークンを返す
+// csrfTokenを渡すとそれを引き継ぎ（リフレッシュ中に並行しているリクエストを失敗させないため）、空なら新しく生成する
+func (o CookieOptions) setSessionCookies(w http.ResponseWriter, tokenPair *services.TokenPair, csrfToken string) (string, error) {
+	if csrfToken == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		csrfToken = hex.EncodeToString(b)
+	}
+
+	refreshMaxAge := int(services.RefreshTokenTTL.Seconds())
+	http.SetCookie(w, o.cookie(constants.AccessTokenCookie, tokenPair.AccessToken, "/", int(services.AccessTokenTTL.Seconds()), true))
+	http.SetCookie(w, o.cookie(constants.RefreshTokenCookie, tokenPair.RefreshToken, refreshTokenCookiePath, refreshMaxAge, true))
+	http.SetCookie(w, o.cookie(constants.CSRFTokenCookie, csrfToken, "/", refreshMaxAge, false))
+	return csrfToken, nil
+}
+
+func (o CookieOptions) clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, o.cookie(constants.AccessTokenCookie, "", "/", -1, true))
+	http.SetCookie(w, o.cookie(constants.RefreshTokenCookie, "", refreshTokenCookiePath, -1, true))
+	http.SetCookie(w, o.cookie(constants.CSRFTokenCookie, "", "/", -1, false))
+}
+
+func (o CookieOptions) cookie(name string, value string, path string, maxAge int, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   o.Domain,
+		MaxAge:   maxAge,
+		Secure:   o.Secure,
+		HttpOnly: httpOnly,
+		// Laxでは他サイトからのPOSTなどにCookieが送られないため、CSRFトークンと合わせて二重に防御する
+		SameSite: http.SameSiteLaxMode,
+	}
+}
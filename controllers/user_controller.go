@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IUserController interface {
+	FindAll(ctx *gin.Context)
+	FindById(ctx *gin.Context)
+	FindItems(ctx *gin.Context)
+	ChangeRole(ctx *gin.Context)
+	Suspend(ctx *gin.Context)
+	Ban(ctx *gin.Context)
+	Reinstate(ctx *gin.Context)
+}
+
+type UserController struct {
+	service services.IUserService
+}
+
+func NewUserController(service services.IUserService) IUserController {
+	return &UserController{service: service}
+}
+
+func (c *UserController) FindAll(ctx *gin.Context) {
+	var query dto.UserSearchQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	users, meta, err := c.service.Search(query)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": users, "meta": meta})
+}
+
+func (c *UserController) FindById(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := c.service.FindByID(userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (c *UserController) FindItems(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	items, err := c.service.FindItems(userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+func (c *UserController) ChangeRole(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	var input dto.UpdateUserRoleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	user, err := c.service.ChangeRole(actorID(ctx), userID, input.Role, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (c *UserController) Suspend(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.Suspend)
+}
+
+func (c *UserController) Ban(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.Ban)
+}
+
+func (c *UserController) Reinstate(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := c.service.Reinstate(actorID(ctx), userID, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (c *UserController) changeStatus(ctx *gin.Context, change func(uint, uint, dto.UpdateUserStatusInput, services.ClientInfo) (*dto.UserResponse, error)) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	// 理由や期間を省略した場合は空のボディも受け付ける
+	var input dto.UpdateUserStatusInput
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			ctx.Error(apperrors.InvalidInput(err))
+			return
+		}
+	}
+
+	user, err := change(actorID(ctx), userID, input, clientInfo(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// actorID 管理操作を行っているユーザーのID（RequirePermissionの後で呼ばれるため必ず設定されている）
+func actorID(ctx *gin.Context) uint {
+	return ctx.MustGet("user").(*models.User).ID
+}
+
+func userIDParam(ctx *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperrors.ErrInvalidID)
+		return 0, false
+	}
+	return uint(userID), true
+}
@@ -0,0 +1,29 @@
+package dto
+
+import "gin-fleamarket/models"
+
+// AccountExport 本人がダウンロードできる、サービスが保持している個人データの一式
+type AccountExport struct {
+	ExportedAt int64              `json:"exported_at"`
+	Profile    ProfileResponse    `json:"profile"`
+	Account    AccountExportUser  `json:"account"`
+	Items      []models.Item      `json:"items"`
+	Sessions   []SessionResponse  `json:"sessions"`
+	APIKeys    []APIKeyResponse   `json:"api_keys"`
+	Identities []AccountIdentity  `json:"identities"`
+	AuditLogs  []AuditLogResponse `json:"audit_logs"`
+}
+
+type AccountExportUser struct {
+	Role      string `json:"role"`
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// AccountIdentity 連携しているOIDCプロバイダのアカウント
+type AccountIdentity struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email"`
+	LinkedAt int64  `json:"linked_at"`
+}
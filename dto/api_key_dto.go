@@ -0,0 +1,23 @@
+package dto
+
+type CreateAPIKeyInput struct {
+	Name          string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays *int     `json:"expires_in_days" binding:"omitnil,min=1,max=365"`
+}
+
+type APIKeyResponse struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"created_at"`
+	ExpiresAt  int64    `json:"expires_at,omitempty"`
+	LastUsedAt int64    `json:"last_used_at,omitempty"`
+}
+
+// CreateAPIKeyResponse 平文のキーを含むのはこのレスポンスのみ
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
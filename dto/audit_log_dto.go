@@ -0,0 +1,32 @@
+package dto
+
+type AuditLogQuery struct {
+	Action   string `form:"action"`
+	ActorID  uint   `form:"actor_id"`
+	TargetID uint   `form:"target_id"`
+	IP       string `form:"ip"`
+	// From, To UNIX秒での期間指定
+	From    int64 `form:"from"`
+	To      int64 `form:"to"`
+	Page    int   `form:"page" binding:"omitempty,min=1"`
+	PerPage int   `form:"per_page" binding:"omitempty,min=1,max=200"`
+}
+
+type AuditLogResponse struct {
+	ID        uint                   `json:"id"`
+	CreatedAt int64                  `json:"created_at"`
+	Action    string                 `json:"action"`
+	ActorID   uint                   `json:"actor_id,omitempty"`
+	TargetID  uint                   `json:"target_id,omitempty"`
+	IPAddress string                 `json:"ip_address"`
+	UserAgent string                 `json:"user_agent"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+	Hash      string                 `json:"hash"`
+}
+
+type AuditChainVerification struct {
+	Valid   bool  `json:"valid"`
+	Checked int64 `json:"checked"`
+	// BrokenAt 検証に失敗した最初の行のID
+	BrokenAt uint `json:"broken_at,omitempty"`
+}
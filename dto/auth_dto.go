@@ -15,6 +15,17 @@ type LoginResponse struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
+// CookieLoginResponse Cookie認証モードのレスポンス。トークンはHttpOnlyのCookieでのみ返す
+type CookieLoginResponse struct {
+	CSRFToken string `json:"csrfToken"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+// RefreshTokenInput Cookie認証モードではボディを省略し、refresh_token Cookieを使う
 type RefreshTokenInput struct {
-	RefreshToken string `json:"refreshToken" binding:"required"`
+	RefreshToken string `json:"refreshToken"`
 }
@@ -0,0 +1,48 @@
+package dto
+
+// HealthResponse /readyzの応答。すべての依存先がokかdisabledの場合だけStatusがokになる
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	// Error 内部の接続先などを含むため、管理者向けの/debug/healthでのみ返す
+	Error string `json:"error,omitempty"`
+}
+
+// HealthDetailResponse 管理者向けの詳細（/debug/health）
+type HealthDetailResponse struct {
+	HealthResponse
+	StartedAt     int64                 `json:"started_at"`
+	UptimeSeconds int64                 `json:"uptime_seconds"`
+	GoVersion     string                `json:"go_version"`
+	Goroutines    int                   `json:"goroutines"`
+	DBPool        DBPoolStats           `json:"db_pool"`
+	Migrations    HealthMigrationDetail `json:"migrations"`
+	Jobs          HealthJobsDetail      `json:"jobs"`
+}
+
+type DBPoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+}
+
+type HealthMigrationDetail struct {
+	Applied int `json:"applied"`
+	// Pending 未適用のマイグレーション（<バージョン>_<名前>）
+	Pending []string `json:"pending"`
+}
+
+type HealthJobsDetail struct {
+	Enabled bool `json:"enabled"`
+	Leader  bool `json:"leader"`
+	// LastHeartbeatAt スケジューラーが最後にリースを確認した時刻（UNIX秒）。未起動の場合は0
+	LastHeartbeatAt int64 `json:"last_heartbeat_at"`
+}
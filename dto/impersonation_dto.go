@@ -0,0 +1,13 @@
+package dto
+
+type ImpersonateInput struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+	// AllowWrite 省略時は閲覧のみ（GET以外のリクエストは拒否される）
+	AllowWrite bool `json:"allow_write"`
+}
+
+type ImpersonationResponse struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   int64  `json:"expiresAt"`
+	ReadOnly    bool   `json:"readOnly"`
+}
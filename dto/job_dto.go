@@ -0,0 +1,25 @@
+package dto
+
+type JobRunQuery struct {
+	Page    int `form:"page" binding:"omitempty,min=1"`
+	PerPage int `form:"per_page" binding:"omitempty,min=1,max=100"`
+}
+
+type JobResponse struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	// NextRunAt 前回の実行から計算した次回の実行予定（UNIX秒）
+	NextRunAt   int64           `json:"next_run_at"`
+	LastRun     *JobRunResponse `json:"last_run"`
+	LastFailure *JobRunResponse `json:"last_failure"`
+}
+
+type JobRunResponse struct {
+	ID         uint   `json:"id"`
+	Holder     string `json:"holder"`
+	Status     string `json:"status"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,10 @@
+package dto
+
+type UpdateLogLevelInput struct {
+	// Level debug / info / warn / error
+	Level string `json:"level" binding:"required"`
+}
+
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+import "gin-fleamarket/apperrors"
+
+// Problem RFC 7807（application/problem+json）のエラーレスポンス
+// code・request_id・errorsは拡張メンバー。errorは従来の{"error": "..."}を読むクライアントのためにdetailと同じ値を返す
+type Problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Errors    []apperrors.FieldError `json:"errors,omitempty"`
+	Error     string                 `json:"error"`
+}
@@ -0,0 +1,31 @@
+package dto
+
+import "gin-fleamarket/models"
+
+type UpdateProfileInput struct {
+	DisplayName *string `json:"display_name" binding:"omitnil,max=50"`
+	Bio         *string `json:"bio" binding:"omitnil,max=1000"`
+	// Prefecture 空文字で未設定に戻す
+	Prefecture *string `json:"prefecture"`
+}
+
+// ProfileResponse 本人向けのプロフィール（メールアドレスを含む）
+type ProfileResponse struct {
+	ID          uint   `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Bio         string `json:"bio"`
+	Prefecture  string `json:"prefecture"`
+}
+
+// PublicUserResponse 出品者ページ用。メールアドレスなど公開しない情報は含めない
+type PublicUserResponse struct {
+	ID          uint          `json:"id"`
+	DisplayName string        `json:"display_name"`
+	AvatarURL   string        `json:"avatar_url"`
+	Bio         string        `json:"bio"`
+	Prefecture  string        `json:"prefecture"`
+	MemberSince int64         `json:"member_since"`
+	Items       []models.Item `json:"items"`
+}
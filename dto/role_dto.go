@@ -0,0 +1,19 @@
+package dto
+
+type CreateRoleInput struct {
+	Name        string   `json:"name" binding:"required,min=2,max=50"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleInput struct {
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions"`
+}
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions"`
+}
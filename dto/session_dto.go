@@ -0,0 +1,10 @@
+package dto
+
+type SessionResponse struct {
+	ID         uint   `json:"id"`
+	Device     string `json:"device"`
+	IPAddress  string `json:"ip_address"`
+	CreatedAt  int64  `json:"created_at"`
+	LastUsedAt int64  `json:"last_used_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
@@ -0,0 +1,35 @@
+package dto
+
+type UserSearchQuery struct {
+	Query   string `form:"q"`
+	Role    string `form:"role"`
+	Status  string `form:"status" binding:"omitempty,oneof=active suspended banned"`
+	Page    int    `form:"page" binding:"omitempty,min=1"`
+	PerPage int    `form:"per_page" binding:"omitempty,min=1,max=100"`
+}
+
+type UserResponse struct {
+	ID             uint   `json:"id"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	Status         string `json:"status"`
+	StatusReason   string `json:"status_reason,omitempty"`
+	SuspendedUntil int64  `json:"suspended_until,omitempty"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+type PageMeta struct {
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+	Total   int64 `json:"total"`
+}
+
+type UpdateUserRoleInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// UpdateUserStatusInput DurationDaysは一時停止の場合のみ使用し、省略時は無期限
+type UpdateUserStatusInput struct {
+	Reason       string `json:"reason" binding:"max=500"`
+	DurationDays *int   `json:"duration_days" binding:"omitnil,min=1,max=3650"`
+}
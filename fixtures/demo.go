@@ -0,0 +1,184 @@
+package fixtures
+
+import (
+	"fmt"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/models"
+	"math/rand"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// DemoPassword デモ用の出品者は全員このパスワードでログインできる
+const DemoPassword = "password123"
+
+// DemoOptions 同じSeedなら同じデータが生成される
+type DemoOptions struct {
+	Items   int
+	Sellers int
+	Seed    int64
+}
+
+// DefaultDemoOptions 一覧のページングや検索の負荷試験に十分な件数
+func DefaultDemoOptions() DemoOptions {
+	return DemoOptions{Items: 5000, Sellers: 50, Seed: 1}
+}
+
+// demoBatchSize 1回のINSERTで作成する商品の数
+const demoBatchSize = 500
+
+type demoProduct struct {
+	name     string
+	category string
+	minPrice uint
+	maxPrice uint
+}
+
+var (
+	demoProducts = []demoProduct{
+		{"iPhone 13 128GB", "スマートフォン", 35000, 70000},
+		{"iPhone SE 第3世代 64GB", "スマートフォン", 20000, 40000},
+		{"Galaxy S22", "スマートフォン", 25000, 50000},
+		{"iPad Air 第5世代", "タブレット", 50000, 80000},
+		{"Nintendo Switch 有機ELモデル", "ゲーム", 25000, 35000},
+		{"PlayStation 5 本体", "ゲーム", 45000, 65000},
+		{"ポケモンカード 拡張パック 未開封", "トレーディングカード", 300, 3000},
+		{"AirPods Pro 第2世代", "オーディオ", 18000, 30000},
+		{"SONY WH-1000XM4 ワイヤレスヘッドホン", "オーディオ", 15000, 28000},
+		{"ルンバ i3", "家電", 20000, 40000},
+		{"ダイソン V8 コードレスクリーナー", "家電", 15000, 30000},
+		{"バルミューダ トースター", "家電", 12000, 22000},
+		{"ユニクロ ウルトラライトダウン", "メンズ", 1500, 4000},
+		{"ザ・ノース・フェイス マウンテンパーカー", "メンズ", 12000, 30000},
+		{"無印良品 リネンシャツ", "レディース", 1000, 3000},
+		{"コーチ ショルダーバッグ", "レディース", 8000, 25000},
+		{"ナイキ エアマックス 27cm", "シューズ", 6000, 15000},
+		{"ニューバランス 996 24cm", "シューズ", 5000, 12000},
+		{"ワンピース 1〜105巻 全巻セット", "本・漫画", 15000, 30000},
+		{"呪術廻戦 全巻セット", "本・漫画", 5000, 12000},
+		{"TOEIC 公式問題集", "本・漫画", 1000, 2500},
+		{"レゴ クラシック アイデアボックス", "おもちゃ", 2000, 5000},
+		{"シルバニアファミリー 赤い屋根の大きなお家", "おもちゃ", 4000, 9000},
+		{"ストウブ ピコ・ココット ラウンド 20cm", "キッチン", 12000, 22000},
+		{"ル・クルーゼ マグカップ 2個セット", "キッチン", 3000, 6000},
+		{"ベビーカー コンビ スゴカル", "ベビー", 8000, 25000},
+		{"キャンプ用 ワンポールテント", "アウトドア", 8000, 20000},
+		{"コールマン ツーバーナー", "アウトドア", 7000, 15000},
+		{"ロードバイク ジャイアント CONTEND", "自転車", 40000, 90000},
+		{"資生堂 アネッサ 日焼け止め 新品", "コスメ", 1500, 3000},
+	}
+	demoConditions = []string{"新品、未使用", "未使用に近い", "目立った傷や汚れなし", "やや傷や汚れあり", "傷や汚れあり"}
+	demoPrefixes   = []string{"", "", "【美品】", "【送料無料】", "【値下げしました】", "【即購入OK】"}
+	demoShipping   = []string{"ゆうパケット", "クリックポスト", "宅急便コンパクト", "ゆうパック"}
+	demoDays       = []string{"1〜2日", "2〜3日", "4〜7日"}
+	demoFamily     = []string{"佐藤", "鈴木", "高橋", "田中", "伊藤", "渡辺", "山本", "中村", "小林", "加藤", "吉田", "山田", "佐々木", "松本", "井上"}
+	demoGiven      = []string{"太郎", "花子", "健太", "美咲", "翔", "さくら", "大輔", "陽菜", "蓮", "結衣", "拓也", "七海"}
+)
+
+func demoSellerEmail(index int) string {
+	return fmt.Sprintf("demo-seller-%03d@example.com", index+1)
+}
+
+// Demo 商品は出品者ごとに決まった順番で生成し、各出品者の既存の件数より後の分だけを作成する
+// そのため再実行しても重複せず、Itemsを増やして実行すると差分だけが追加される
+func (s *Seeder) Demo(options DemoOptions) (Result, error) {
+	if options.Items < 0 || options.Sellers < 1 {
+		return Result{}, fmt.Errorf("demo requires at least one seller")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(DemoPassword), s.hashCost)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		rng := rand.New(rand.NewSource(options.Seed))
+
+		sellerIDs := make([]uint, options.Sellers)
+		for i := range sellerIDs {
+			user := models.User{
+				Email:       demoSellerEmail(i),
+				Password:    string(hash),
+				Role:        constants.RoleUser,
+				Status:      constants.UserStatusActive,
+				DisplayName: demoFamily[rng.Intn(len(demoFamily))] + demoGiven[rng.Intn(len(demoGiven))],
+				Prefecture:  constants.Prefectures[rng.Intn(len(constants.Prefectures))],
+			}
+			created := tx.Where("email = ?", user.Email).Attrs(user).FirstOrCreate(&user)
+			if created.Error != nil {
+				return created.Error
+			}
+			if created.RowsAffected > 0 {
+				result.UsersCreated++
+			}
+			sellerIDs[i] = user.ID
+		}
+
+		existing := make(map[uint]int, len(sellerIDs))
+		var counts []struct {
+			UserID uint
+			Count  int
+		}
+		if err := tx.Model(&models.Item{}).Select("user_id, COUNT(*) AS count").Where("user_id IN ?", sellerIDs).Group("user_id").Scan(&counts).Error; err != nil {
+			return err
+		}
+		for _, c := range counts {
+			existing[c.UserID] = c.Count
+		}
+
+		// 乱数の消費順を固定するため、作成しない商品も生成してから飛ばす
+		now := time.Now()
+		generated := make(map[uint]int, len(sellerIDs))
+		batch := make([]models.Item, 0, demoBatchSize)
+		for i := 0; i < options.Items; i++ {
+			sellerID := sellerIDs[i%len(sellerIDs)]
+			item := demoItem(rng, sellerID, now)
+			generated[sellerID]++
+			if generated[sellerID] <= existing[sellerID] {
+				continue
+			}
+			batch = append(batch, item)
+			if len(batch) == demoBatchSize {
+				if err := tx.Create(&batch).Error; err != nil {
+					return err
+				}
+				result.ItemsCreated += len(batch)
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			if err := tx.Create(&batch).Error; err != nil {
+				return err
+			}
+			result.ItemsCreated += len(batch)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// demoItem 出品日は過去90日に散らし、2割程度を売り切れにする
+func demoItem(rng *rand.Rand, sellerID uint, now time.Time) models.Item {
+	product := demoProducts[rng.Intn(len(demoProducts))]
+	condition := demoConditions[rng.Intn(len(demoConditions))]
+	// 価格は100円単位にする
+	price := product.minPrice + uint(rng.Intn(int(product.maxPrice-product.minPrice)/100+1))*100
+	createdAt := now.Add(-time.Duration(rng.Intn(90*24*60)) * time.Minute)
+
+	description := fmt.Sprintf(
+		"カテゴリ: %s\n商品の状態: %s\n\nご覧いただきありがとうございます。%sです。\n%sで%sで発送します。\n気になる点があればコメントでお気軽にどうぞ。",
+		product.category, condition, product.name,
+		demoShipping[rng.Intn(len(demoShipping))], demoDays[rng.Intn(len(demoDays))],
+	)
+	return models.Item{
+		Model:       gorm.Model{CreatedAt: createdAt, UpdatedAt: createdAt},
+		Name:        demoPrefixes[rng.Intn(len(demoPrefixes))] + product.name,
+		Price:       price,
+		Description: description,
+		SoldOut:     rng.Intn(5) == 0,
+		UserID:      sellerID,
+	}
+}
@@ -0,0 +1,210 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/models"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Fixture 投入するデータの宣言。ユーザーはメールアドレス、商品は出品者と商品名で既存の行と照合する
+// カテゴリと注文はまだモデルがないため、ファイルに含めるとエラーになる
+type Fixture struct {
+	Users []UserFixture `yaml:"users" json:"users"`
+	Items []ItemFixture `yaml:"items" json:"items"`
+}
+
+type UserFixture struct {
+	Email string `yaml:"email" json:"email"`
+	// Password 平文で書き、投入時にbcryptでハッシュ化する
+	Password    string `yaml:"password" json:"password"`
+	Role        string `yaml:"role" json:"role"`
+	DisplayName string `yaml:"display_name" json:"display_name"`
+	Bio         string `yaml:"bio" json:"bio"`
+	Prefecture  string `yaml:"prefecture" json:"prefecture"`
+}
+
+type ItemFixture struct {
+	// Seller 出品者のメールアドレス（同じファイルか、既にDBにいるユーザー）
+	Seller      string `yaml:"seller" json:"seller"`
+	Name        string `yaml:"name" json:"name"`
+	Price       uint   `yaml:"price" json:"price"`
+	Description string `yaml:"description" json:"description"`
+	SoldOut     bool   `yaml:"sold_out" json:"sold_out"`
+}
+
+// Result 新しく作成した件数（既存の行と一致して飛ばした分は含まない）
+type Result struct {
+	UsersCreated int
+	ItemsCreated int
+}
+
+// LoadFile 拡張子が.jsonならJSON、それ以外はYAMLとして読み込む
+func LoadFile(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if filepath.Ext(path) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&fixture)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&fixture)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	if err := fixture.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Validate 投入を始める前に、途中で失敗するような誤りを見つける
+func (f *Fixture) Validate() error {
+	var errs []error
+	for i, user := range f.Users {
+		if user.Email == "" || len(user.Password) < 8 {
+			errs = append(errs, fmt.Errorf("users[%d]: email and password (min 8 characters) are required", i))
+		}
+		if user.Role != "" && !slices.Contains([]string{constants.RoleUser, constants.RoleModerator, constants.RoleAdmin}, user.Role) {
+			errs = append(errs, fmt.Errorf("users[%d]: unknown role %q", i, user.Role))
+		}
+		if user.Prefecture != "" && !slices.Contains(constants.Prefectures, user.Prefecture) {
+			errs = append(errs, fmt.Errorf("users[%d]: unknown prefecture %q", i, user.Prefecture))
+		}
+	}
+	for i, item := range f.Items {
+		if item.Seller == "" || item.Name == "" || item.Price == 0 {
+			errs = append(errs, fmt.Errorf("items[%d]: seller, name and price are required", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type ISeeder interface {
+	// Apply 1つのトランザクションで投入する。既にある行は変更しないため、何度実行しても重複しない
+	Apply(fixture *Fixture) (Result, error)
+	// Demo 負荷試験用に、出品者と商品を決まった乱数で生成して投入する
+	Demo(options DemoOptions) (Result, error)
+}
+
+type Seeder struct {
+	db *gorm.DB
+	// hashCost テストでは時間を短縮するためbcrypt.MinCostにできる
+	hashCost int
+}
+
+func NewSeeder(db *gorm.DB, hashCost int) ISeeder {
+	if hashCost == 0 {
+		hashCost = bcrypt.DefaultCost
+	}
+	return &Seeder{db: db, hashCost: hashCost}
+}
+
+func (s *Seeder) Apply(fixture *Fixture) (Result, error) {
+	if err := fixture.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		hashes := make(map[string]string)
+		for _, fixtureUser := range fixture.Users {
+			created, err := s.ensureUser(tx, fixtureUser, hashes)
+			if err != nil {
+				return err
+			}
+			if created {
+				result.UsersCreated++
+			}
+		}
+
+		sellers := make(map[string]uint)
+		for _, fixtureItem := range fixture.Items {
+			sellerID, ok := sellers[fixtureItem.Seller]
+			if !ok {
+				var seller models.User
+				if err := tx.Where("email = ?", fixtureItem.Seller).First(&seller).Error; err != nil {
+					return fmt.Errorf("seller %s of item %s: %w", fixtureItem.Seller, fixtureItem.Name, err)
+				}
+				sellerID = seller.ID
+				sellers[fixtureItem.Seller] = sellerID
+			}
+
+			var count int64
+			if err := tx.Model(&models.Item{}).Where("user_id = ? AND name = ?", sellerID, fixtureItem.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			item := models.Item{
+				Name:        fixtureItem.Name,
+				Price:       fixtureItem.Price,
+				Description: fixtureItem.Description,
+				SoldOut:     fixtureItem.SoldOut,
+				UserID:      sellerID,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			result.ItemsCreated++
+		}
+		return nil
+	})
+	return result, err
+}
+
+// ensureUser 同じパスワードのユーザーが多い場合に備え、ハッシュは同じ実行の中で使い回す
+func (s *Seeder) ensureUser(tx *gorm.DB, fixtureUser UserFixture, hashes map[string]string) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("email = ?", fixtureUser.Email).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	hash, ok := hashes[fixtureUser.Password]
+	if !ok {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(fixtureUser.Password), s.hashCost)
+		if err != nil {
+			return false, err
+		}
+		hash = string(hashed)
+		hashes[fixtureUser.Password] = hash
+	}
+
+	role := fixtureUser.Role
+	if role == "" {
+		role = constants.RoleUser
+	}
+	user := models.User{
+		Email:       fixtureUser.Email,
+		Password:    hash,
+		Role:        role,
+		Status:      constants.UserStatusActive,
+		DisplayName: fixtureUser.DisplayName,
+		Bio:         fixtureUser.Bio,
+		Prefecture:  fixtureUser.Prefecture,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -1,61 +1,197 @@
 package infra
 
 import (
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"gin-fleamarket/config"
+	"gin-fleamarket/metrics"
+	"gin-fleamarket/tracing"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func SetupDB() *gorm.DB {
-	dbName := os.Getenv("DB_NAME")
-	env := os.Getenv("ENV")
+const (
+	// maintenanceDBName データベースを作成する際に接続する、必ず存在するデータベース
+	maintenanceDBName = "postgres"
+	// 接続に失敗した場合の待ち時間（試行ごとに2倍にし、maxConnectBackoffで打ち切る）
+	initialConnectBackoff = 500 * time.Millisecond
+	maxConnectBackoff     = 10 * time.Second
+)
+
+// databaseNamePattern CREATE DATABASEは識別子をプレースホルダで渡せないため、名前を制限する
+var databaseNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
-	// DB_NAMEが設定されている場合はPostgreSQLを使用
-	// DB_NAME="postgres"の場合もPostgreSQL接続を許可（main.goで特別な処理があるため）
-	if dbName != "" {
-		// 本番環境ではsslmode=require、それ以外はsslmode=disable
-		sslmode := "disable"
-		if env == "prod" {
+// OpenDB 設定に応じてPostgreSQLかSQLite（ファイルまたはインメモリ）に接続する
+// DBの起動を待つ場合に備え、接続できるまでDB_CONNECT_ATTEMPTS回まで間隔を延ばしながら再試行する
+func OpenDB(cfg *config.Config) (*gorm.DB, error) {
+	switch cfg.DB.Driver {
+	case "postgres":
+		if cfg.DB.CreateDatabase {
+			if err := ensurePostgresDatabase(cfg); err != nil {
+				return nil, err
+			}
+		}
+		db, err := openWithRetry(cfg, postgres.Open(postgresDSN(cfg, cfg.DB.Name)))
+		if err != nil {
+			return nil, err
+		}
+		if err := configurePool(db, cfg.DB); err != nil {
+			return nil, err
+		}
+		slog.Info("Setup postgres database", "host", cfg.DB.Host, "dbname", cfg.DB.Name)
+		return db, nil
+	case "sqlite":
+		if cfg.DB.Path == "" {
+			db, err := gorm.Open(sqlite.Open(":memory:"), gormConfig())
+			if err != nil {
+				return nil, fmt.Errorf("failed to open in-memory sqlite: %w", err)
+			}
+			// インメモリDBは接続ごとに別のDBになり、接続を閉じると消える
+			// プールが2本目の接続を開くとテーブルのない空のDBに見えるため、接続を1本に固定して閉じないようにする
+			sqlDB, err := db.DB()
+			if err != nil {
+				return nil, err
+			}
+			sqlDB.SetMaxOpenConns(1)
+			sqlDB.SetMaxIdleConns(1)
+			sqlDB.SetConnMaxLifetime(0)
+			sqlDB.SetConnMaxIdleTime(0)
+			slog.Info("Setup sqlite database (in-memory)")
+			return db, usePlugins(db)
+		}
+		db, err := openWithRetry(cfg, sqlite.Open(sqliteDSN(cfg.DB)))
+		if err != nil {
+			return nil, err
+		}
+		if err := configurePool(db, cfg.DB); err != nil {
+			return nil, err
+		}
+		slog.Info("Setup sqlite database", "path", cfg.DB.Path)
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.DB.Driver)
+	}
+}
+
+// gormConfig 一意制約違反などをドライバーによらずgorm.ErrDuplicatedKeyなどで判定できるよう、エラーを変換する
+func gormConfig() *gorm.Config {
+	return &gorm.Config{TranslateError: true}
+}
+
+// postgresDSN SSLModeを省略した場合、本番環境ではsslmode=require、それ以外はsslmode=disable
+// statement_timeoutはpgxがセッションの実行時パラメータとしてサーバーに渡す
+func postgresDSN(cfg *config.Config, dbName string) string {
+	sslmode := cfg.DB.SSLMode
+	if sslmode == "" {
+		sslmode = "disable"
+		if cfg.IsProd() {
 			sslmode = "require"
 		}
+	}
+	dsn := fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=Asia/Tokyo connect_timeout=10",
+		quoteDSNValue(cfg.DB.Host),
+		quoteDSNValue(cfg.DB.User),
+		quoteDSNValue(cfg.DB.Password),
+		quoteDSNValue(dbName),
+		quoteDSNValue(sslmode),
+	)
+	if cfg.DB.Port != "" {
+		dsn += " port=" + quoteDSNValue(cfg.DB.Port)
+	}
+	if cfg.DB.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", cfg.DB.StatementTimeout.Milliseconds())
+	}
+	return dsn
+}
 
-		dsn := fmt.Sprintf(
-			"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Tokyo",
-			os.Getenv("DB_HOST"),
-			os.Getenv("DB_USER"),
-			os.Getenv("DB_PASSWORD"),
-			dbName,
-			os.Getenv("DB_PORT"),
-			sslmode,
-		)
+// quoteDSNValue key=value形式の値を引用符で囲み、空白・'・\を含むパスワードなどでも別の値として解釈されないようにする
+func quoteDSNValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
+}
 
-		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-		if err != nil {
-			panic("Failed to connect to database")
+// sqliteDSN 他の接続が書き込み中の場合は、StatementTimeoutまでロックの解放を待つ
+func sqliteDSN(cfg config.DBConfig) string {
+	return fmt.Sprintf("file:%s?_busy_timeout=%d&_foreign_keys=on", cfg.Path, cfg.StatementTimeout.Milliseconds())
+}
+
+func openWithRetry(cfg *config.Config, dialector gorm.Dialector) (*gorm.DB, error) {
+	backoff := initialConnectBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var db *gorm.DB
+		// gorm.Openは接続後にPingするため、DBが起動していなければここでエラーになる
+		db, err = gorm.Open(dialector, gormConfig())
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= cfg.DB.ConnectAttempts {
+			break
 		}
-		log.Println("Setup postgres database")
-		return db
+		slog.Warn("Failed to connect to database, retrying", "attempt", attempt, "max_attempts", cfg.DB.ConnectAttempts, "backoff", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
+	return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", cfg.DB.ConnectAttempts, err)
+}
+
+// usePlugins クエリの実行時間とエラーを記録するプラグインと、スパンを作るプラグインを登録する
+func usePlugins(db *gorm.DB) error {
+	return errors.Join(db.Use(metrics.GormPlugin{}), db.Use(tracing.GormPlugin{}))
+}
 
-	// デフォルトはSQLiteのインメモリ（テスト用）
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+// configurePool プラグインもここで登録する
+func configurePool(db *gorm.DB, cfg config.DBConfig) error {
+	if err := usePlugins(db); err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		panic("Failed to connect to database")
+		return err
 	}
-	log.Println("Setup sqlite database (in-memory)")
-	return db
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return nil
 }
 
-// SetupTokenDB トークンブラックリスト用のSQLiteデータベース接続を設定
-func SetupTokenDB() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("token_blacklist.db"), &gorm.Config{})
+// ensurePostgresDatabase 初回のデプロイで空のクラスタに接続した場合に、アプリケーション用のデータベースを作成する
+func ensurePostgresDatabase(cfg *config.Config) error {
+	if !databaseNamePattern.MatchString(cfg.DB.Name) {
+		return fmt.Errorf("invalid database name: %q", cfg.DB.Name)
+	}
+	if cfg.DB.Name == maintenanceDBName {
+		return nil
+	}
+
+	db, err := openWithRetry(cfg, postgres.Open(postgresDSN(cfg, maintenanceDBName)))
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		panic("Failed to connect to token blacklist database")
+		return err
+	}
+	defer sqlDB.Close()
+
+	var exists int64
+	if err := db.Raw("SELECT COUNT(*) FROM pg_database WHERE datname = ?", cfg.DB.Name).Scan(&exists).Error; err != nil {
+		return fmt.Errorf("failed to check database %s: %w", cfg.DB.Name, err)
+	}
+	if exists > 0 {
+		return nil
+	}
+	if err := db.Exec(fmt.Sprintf(`CREATE DATABASE "%s"`, cfg.DB.Name)).Error; err != nil {
+		return fmt.Errorf("failed to create database %s: %w", cfg.DB.Name, err)
 	}
-	log.Println("Setup token blacklist SQLite database")
-	return db
+	slog.Info("Created database", "dbname", cfg.DB.Name)
+	return nil
 }
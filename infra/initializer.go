@@ -1,13 +0,0 @@
-package infra
-
-import (
-	"log"
-
-	"github.com/joho/godotenv"
-)
-
-func Initialize() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found; using environment variables")
-	}
-}
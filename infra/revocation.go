@@ -0,0 +1,36 @@
+package infra
+
+import (
+	"fmt"
+	"gin-fleamarket/config"
+	"gin-fleamarket/revocation"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ブルームフィルタに他のインスタンスでの失効を反映する間隔。反映されていないフィルタは最大でこの2倍の時間まで使う
+const revocationSyncInterval = time.Second
+
+// SetupTokenRevocationStore TOKEN_REVOCATION_STOREで失効トークンの保存先を選ぶ（確認の回数はメトリクスに記録する）
+// sql（既定）: メインのDB / redis: REDIS_URLのRedis / memory: プロセス内（単一インスタンス専用）
+func SetupTokenRevocationStore(db *gorm.DB, cfg config.RevocationConfig) (revocation.ITokenRevocationStore, error) {
+	switch cfg.Store {
+	case "", "sql":
+		slog.Info("Setup token revocation store", "store", "sql")
+		return revocation.NewInstrumentedStore(revocation.NewBloomFilteredStore(revocation.NewSQLStore(db), revocationSyncInterval), "sql"), nil
+	case "redis":
+		store, err := revocation.NewRedisStore(cfg.RedisURL, "fleamarket:")
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to redis token revocation store: %w", err)
+		}
+		slog.Info("Setup token revocation store", "store", "redis")
+		return revocation.NewInstrumentedStore(revocation.NewBloomFilteredStore(store, revocationSyncInterval), "redis"), nil
+	case "memory":
+		slog.Warn("Setup token revocation store; revocations are not shared between instances", "store", "memory")
+		return revocation.NewInstrumentedStore(revocation.NewMemoryStore(), "memory"), nil
+	default:
+		return nil, fmt.Errorf("unknown TOKEN_REVOCATION_STORE: %q", cfg.Store)
+	}
+}
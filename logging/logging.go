@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"gin-fleamarket/config"
+	"gin-fleamarket/tracing"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// redacted 伏せた値の代わりに出力する文字列（config.Redactedと同じ）
+const redacted = "[REDACTED]"
+
+// level 実行中にSetLevelで変更できるよう、すべてのハンドラーで共有する
+var level = new(slog.LevelVar)
+
+// sensitiveKeys 値を出力しない属性のキー（小文字で比較する）
+var sensitiveKeys = map[string]bool{
+	"email":         true,
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"api_key":       true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+}
+
+// emailPattern キーで判別できないメッセージやエラーの文字列に含まれるメールアドレスも伏せる
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// Setup 標準出力に出力するロガーを既定にする
+// slog.SetDefaultにより、logパッケージ（log.Printfなど）の出力も同じ形式になる
+func Setup(cfg config.LogConfig) error {
+	if err := SetLevel(cfg.Level); err != nil {
+		return err
+	}
+	slog.SetDefault(New(os.Stdout, cfg.Format))
+	return nil
+}
+
+// New formatがtextの場合はkey=value形式、それ以外はJSONで出力する
+func New(w io.Writer, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Level 現在のログレベル
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel debug / info / warn / error（大文字小文字は区別しない）
+func SetLevel(name string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return errors.New("unknown log level: " + name)
+	}
+	level.Set(parsed)
+	return nil
+}
+
+// redact 機密情報のキーの値と、文字列中のメールアドレスを伏せる
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactString(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, redactString(err.Error()))
+		}
+	}
+	return attr
+}
+
+func redactString(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	return emailPattern.ReplaceAllString(value, redacted)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 以降にこのcontextで出力するログにrequest_idを付ける
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID contextにリクエストIDがなければ空文字列
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// contextHandler slog.InfoContextなどに渡されたcontextからリクエストIDとトレースIDを取り出して付ける
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -2,15 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/config"
 	"gin-fleamarket/constants"
 	"gin-fleamarket/controllers"
 	"gin-fleamarket/infra"
+	"gin-fleamarket/logging"
 	"gin-fleamarket/middlewares"
-	"gin-fleamarket/models"
+	"gin-fleamarket/migrate"
+	"gin-fleamarket/oidc"
+	"gin-fleamarket/openapi"
 	"gin-fleamarket/repositories"
+	"gin-fleamarket/scheduler"
 	"gin-fleamarket/services"
+	"gin-fleamarket/storage"
+	"gin-fleamarket/tracing"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -21,184 +31,333 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func setupRouter(db *gorm.DB) *gin.Engine {
+// setupRouter 署名鍵や失効トークンの保存先を準備できない場合はエラーを返し、起動を中止するかは呼び出し側で決める
+func setupRouter(db *gorm.DB, cfg *config.Config) (*gin.Engine, error) {
+
+	signingKeyRepository := repositories.NewSigningKeyRepository(db)
+	keyRing, err := services.NewKeyRing(signingKeyRepository, cfg.Auth.JWTSigningAlg, cfg.SecretKey, cfg.Auth.LegacyHS256Until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	jwksController := controllers.NewJWKSController(keyRing)
+
+	auditLogRepository := repositories.NewAuditLogRepository(db)
+	auditService := services.NewAuditService(auditLogRepository)
+	auditLogController := controllers.NewAuditLogController(auditService)
+
+	authRepository := repositories.NewAuthRepository(db)
+	revocationStore, err := infra.SetupTokenRevocationStore(db, cfg.Revocation)
+	if err != nil {
+		return nil, err
+	}
+	sessionRepository := repositories.NewSessionRepository(db)
+	authService := services.NewAuthService(authRepository, revocationStore, sessionRepository, keyRing, auditService)
+	// ブラウザ向けのCookie認証モード
+	cookieOptions := controllers.CookieOptions{
+		Secure: cfg.Cookie.Secure,
+		Domain: cfg.Cookie.Domain,
+	}
+	authController := controllers.NewAuthController(authService, cookieOptions)
+
+	bootstrapService := services.NewBootstrapService(authRepository, cfg.Auth.AdminBootstrapToken)
+	bootstrapController := controllers.NewBootstrapController(bootstrapService)
+
+	sessionService := services.NewSessionService(sessionRepository)
+	sessionController := controllers.NewSessionController(sessionService)
+
+	apiKeyRepository := repositories.NewAPIKeyRepository(db)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepository, authRepository)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+
+	roleRepository := repositories.NewRoleRepository(db)
+	roleService := services.NewRoleService(roleRepository)
+	roleController := controllers.NewRoleController(roleService)
+	if err := roleService.EnsureBuiltinRoles(); err != nil {
+		slog.Error("Failed to ensure built-in roles", "error", err)
+	}
+
+	impersonationService := services.NewImpersonationService(authRepository, keyRing, auditService)
+	impersonationController := controllers.NewImpersonationController(impersonationService)
+
+	userRepository := repositories.NewUserRepository(db)
+	userService := services.NewUserService(userRepository, roleRepository, roleService, sessionRepository, auditService)
+	userController := controllers.NewUserController(userService)
 
 	itemRepository := repositories.NewItemRepository(db)
-	itemService := services.NewItemService(itemRepository)
+	itemPolicy := services.NewItemPolicy(roleService)
+	itemService := services.NewItemService(itemRepository, itemPolicy, auditService)
 	itemController := controllers.NewItemController(itemService)
 
-	authRepository := repositories.NewAuthRepository(db)
-	tokenDB := infra.SetupTokenDB()
-	tokenRepository := repositories.NewTokenRepository(tokenDB)
-	authService := services.NewAuthService(authRepository, tokenRepository)
-	authController := controllers.NewAuthController(authService)
-
-	// トークンブラックリスト用のマイグレーション（常に実行）
-	// テスト環境でもテーブルが必要なため、AUTO_MIGRATEの条件を外す
-	if err := tokenDB.AutoMigrate(&models.BlacklistedToken{}); err != nil {
-		log.Printf("Failed to migrate token blacklist database: %v", err)
+	fileStorage := storage.NewLocalStorage(cfg.Upload.Dir, "/uploads")
+	profileService := services.NewProfileService(userRepository, itemPolicy, fileStorage)
+	profileController := controllers.NewProfileController(profileService)
+
+	accountRepository := repositories.NewAccountRepository(db)
+	accountService := services.NewAccountService(accountRepository, userRepository, apiKeyRepository, fileStorage, auditService)
+	accountController := controllers.NewAccountController(accountService)
+
+	var oidcProviders []*oidc.Provider
+	for _, provider := range cfg.OIDC {
+		oidcProviders = append(oidcProviders, oidc.NewProvider(oidc.Config{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		}, &http.Client{Timeout: 10 * time.Second, Transport: tracing.NewTransport(http.DefaultTransport)}))
+	}
+	oidcRepository := repositories.NewOIDCRepository(db)
+	oidcService := services.NewOIDCService(oidcProviders, oidcRepository, authRepository, authService, auditService)
+	oidcController := controllers.NewOIDCController(oidcService, cookieOptions)
+
+	jobRepository := repositories.NewJobRepository(db)
+	jobScheduler := scheduler.NewScheduler(jobRepository)
+	for _, job := range services.MaintenanceJobs(revocationStore, sessionRepository, oidcRepository, jobRepository) {
+		jobScheduler.Register(job)
+	}
+	jobService := services.NewJobService(jobScheduler, jobRepository)
+	jobController := controllers.NewJobController(jobService)
+
+	configController := controllers.NewConfigController(cfg)
+	logLevelController := controllers.NewLogLevelController()
+	metricsController := controllers.NewMetricsController(cfg.Metrics.Token)
+	services.RegisterDBMetrics(repositories.NewMetricsRepository(db))
+
+	// Lambdaでは定期ジョブを実行しないため、ハートビートも確認しない（外部のスケジューラーからPOST /admin/jobs/:name/runで実行する）
+	jobsEnabled := cfg.Jobs.Enabled && !cfg.Lambda
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup migrator: %w", err)
+	}
+	healthRepository := repositories.NewHealthRepository(db)
+	healthService := services.NewHealthService(healthRepository, revocationStore, migrator, jobScheduler, jobsEnabled)
+	healthController := controllers.NewHealthController(healthService)
+
+	// Lambdaではリクエストの処理中以外はプロセスが停止するため、定期ジョブを実行しない
+	if jobsEnabled {
+		backgroundJobs.Add(1)
+		go func() {
+			defer backgroundJobs.Done()
+			jobScheduler.Start(jobsContext)
+		}()
 	}
 
-	r := gin.Default()
+	// エンドポイントを追加した場合はopenapi.Routesにも追加する（TestOpenAPICoversAllRoutesで確認する）
+	openAPIController := controllers.NewOpenAPIController(openapi.Spec())
+
+	r := gin.New()
+	// RequestIDとTracingを最初に登録し、以降のミドルウェアのログにもrequest_idとtrace_idが付くようにする
+	// Recoveryを最後にし、パニックした場合もスパン・メトリクス・アクセスログに500として記録する
+	r.Use(middlewares.RequestID(), middlewares.Tracing(), middlewares.Metrics(), middlewares.AccessLog(), middlewares.Recovery())
 	r.Use(cors.Default())
-	itemRouter := r.Group("/items")
-	itemRouterWithAuth := r.Group("/items", middlewares.AuthMiddleware(authService))
-	itemRouterWithAdminAuth := r.Group("/items", middlewares.AuthMiddleware(authService), middlewares.RoleBasedAccessControl(constants.RoleAdmin))
+	r.Use(middlewares.RecordImpersonatedRequests(auditService))
+	// ErrorHandlerはハンドラの実行後に応答を書き込むため、ステータスを記録するミドルウェアより後に登録する
+	r.Use(middlewares.ErrorHandler())
+	r.NoRoute(func(ctx *gin.Context) {
+		ctx.Error(apperrors.ErrNotFound)
+	})
+	// 商品の閲覧は未ログインでも可能。ログイン中なら出品者本人・モデレーターは非公開の商品も閲覧できる
+	itemRouter := r.Group("/items", middlewares.OptionalAuthMiddleware(authService, apiKeyService))
+	// 商品APIのみAPIキーでの認証を受け付け、ルートごとにスコープを要求する
+	itemRouterWithAuth := r.Group("/items", middlewares.AuthMiddleware(authService, apiKeyService))
 	authRouter := r.Group("/auth")
+	meRouter := r.Group("/me", middlewares.AuthMiddleware(authService, nil))
+	adminRouter := r.Group("/admin", middlewares.AuthMiddleware(authService, nil))
+	debugRouter := r.Group("/debug", middlewares.AuthMiddleware(authService, nil))
+
+	r.GET("/livez", healthController.Live)
+	r.GET("/metrics", metricsController.Export)
+	r.GET("/openapi.json", openAPIController.Spec)
+	r.GET("/docs", openAPIController.Docs)
+	r.GET("/readyz", healthController.Ready)
+	debugRouter.GET("/health", middlewares.RequirePermission(roleService, constants.PermSystemManage), healthController.Detail)
 
 	itemRouter.GET("", itemController.FindAll)
-	itemRouterWithAuth.GET("/:id", itemController.FindById)
-	itemRouterWithAuth.POST("", itemController.Create)
-	itemRouterWithAuth.PUT("/:id", itemController.Update)
-	itemRouterWithAdminAuth.DELETE("/:id", itemController.Delete)
+	itemRouter.GET("/:id", middlewares.RequireScope(constants.ScopeItemsRead), itemController.FindById)
+	itemRouterWithAuth.POST("", middlewares.RequireScope(constants.ScopeItemsWrite), itemController.Create)
+	itemRouterWithAuth.PUT("/:id", middlewares.RequireScope(constants.ScopeItemsWrite), itemController.Update)
+	itemRouterWithAuth.DELETE("/:id", middlewares.RequireScope(constants.ScopeItemsWrite), itemController.Delete)
 
 	authRouter.POST("/signup", authController.Signup)
 	authRouter.POST("/login", authController.Login)
-	authRouter.POST("/refresh", authController.RefreshToken)
-	authRouter.POST("/logout", authController.Logout)
-
-	return r
+	authRouter.POST("/refresh", middlewares.CSRFProtection(), authController.RefreshToken)
+	authRouter.POST("/logout", middlewares.CSRFProtection(), authController.Logout)
+	authRouter.POST("/bootstrap", bootstrapController.CreateAdmin)
+	authRouter.GET("/oidc/:provider/login", oidcController.Login)
+	authRouter.GET("/oidc/:provider/callback", oidcController.Callback)
+
+	// パスワード・認証情報・セッション・個人データを扱う本人専用のルートは、なりすまし中は使えない
+	meRouter.PUT("/password", middlewares.ForbidImpersonation(), authController.ChangePassword)
+	meRouter.GET("/profile", profileController.FindMine)
+	meRouter.PUT("/profile", middlewares.ForbidImpersonation(), profileController.Update)
+	meRouter.PUT("/profile/avatar", middlewares.ForbidImpersonation(), profileController.UpdateAvatar)
+	meRouter.GET("/export", middlewares.ForbidImpersonation(), accountController.Export)
+	meRouter.DELETE("", middlewares.ForbidImpersonation(), accountController.Delete)
+	r.GET("/users/:id", profileController.FindPublic)
+	r.Static("/uploads", cfg.Upload.Dir)
+	meRouter.GET("/sessions", sessionController.FindAll)
+	meRouter.DELETE("/sessions/:id", middlewares.ForbidImpersonation(), sessionController.Revoke)
+	meRouter.GET("/api-keys", apiKeyController.FindAll)
+	meRouter.POST("/api-keys", middlewares.ForbidImpersonation(), apiKeyController.Create)
+	meRouter.DELETE("/api-keys/:id", middlewares.ForbidImpersonation(), apiKeyController.Delete)
+
+	r.GET("/.well-known/jwks.json", jwksController.PublicKeys)
+	adminRouter.POST("/keys/rotate", middlewares.RequirePermission(roleService, constants.PermSystemManage), jwksController.Rotate)
+	adminRouter.GET("/users", middlewares.RequirePermission(roleService, constants.PermUserManage), userController.FindAll)
+	adminRouter.GET("/users/:id", middlewares.RequirePermission(roleService, constants.PermUserManage), userController.FindById)
+	adminRouter.GET("/users/:id/items", middlewares.RequirePermission(roleService, constants.PermUserManage), userController.FindItems)
+	adminRouter.PUT("/users/:id/role", middlewares.RequirePermission(roleService, constants.PermUserManage), userController.ChangeRole)
+	adminRouter.POST("/users/:id/impersonate", middlewares.RequirePermission(roleService, constants.PermUserImpersonate), impersonationController.Start)
+	adminRouter.POST("/users/:id/suspend", middlewares.RequirePermission(roleService, constants.PermUserBan), userController.Suspend)
+	adminRouter.POST("/users/:id/ban", middlewares.RequirePermission(roleService, constants.PermUserBan), userController.Ban)
+	adminRouter.POST("/users/:id/reinstate", middlewares.RequirePermission(roleService, constants.PermUserBan), userController.Reinstate)
+	adminRouter.GET("/audit-logs", middlewares.RequirePermission(roleService, constants.PermAuditRead), auditLogController.FindAll)
+	adminRouter.GET("/audit-logs/verify", middlewares.RequirePermission(roleService, constants.PermAuditRead), auditLogController.Verify)
+	adminRouter.GET("/config", middlewares.RequirePermission(roleService, constants.PermSystemManage), configController.Show)
+	adminRouter.GET("/log-level", middlewares.RequirePermission(roleService, constants.PermSystemManage), logLevelController.Show)
+	adminRouter.PUT("/log-level", middlewares.RequirePermission(roleService, constants.PermSystemManage), logLevelController.Update)
+	adminRouter.GET("/jobs", middlewares.RequirePermission(roleService, constants.PermSystemManage), jobController.FindAll)
+	adminRouter.GET("/jobs/:name/runs", middlewares.RequirePermission(roleService, constants.PermSystemManage), jobController.FindRuns)
+	adminRouter.POST("/jobs/:name/run", middlewares.RequirePermission(roleService, constants.PermSystemManage), jobController.Run)
+	adminRouter.GET("/permissions", middlewares.RequirePermission(roleService, constants.PermRoleManage), roleController.FindAllPermissions)
+	adminRouter.GET("/roles", middlewares.RequirePermission(roleService, constants.PermRoleManage), roleController.FindAll)
+	adminRouter.POST("/roles", middlewares.RequirePermission(roleService, constants.PermRoleManage), roleController.Create)
+	adminRouter.PUT("/roles/:name", middlewares.RequirePermission(roleService, constants.PermRoleManage), roleController.Update)
+	adminRouter.DELETE("/roles/:name", middlewares.RequirePermission(roleService, constants.PermRoleManage), roleController.Delete)
+
+	return r, nil
 }
 
-var (
-	globalDB   *gorm.DB
-	dbReady    = make(chan struct{})
-	dbInitOnce sync.Once
-)
-
-func initDB() *gorm.DB {
-	infra.Initialize()
-
-	db := infra.SetupDB()
+// setupLambdaRouter DBの接続を待たずに起動し、readyが閉じられた後のリクエストをsetupRouterのルーターに転送する
+func setupLambdaRouter(cfg *config.Config, ready <-chan struct{}, db func() *gorm.DB) *gin.Engine {
+	// アクセスログとメトリクス、スパンは転送先のルーターで記録する。ここでリクエストIDを設定し、転送先でも同じIDを使う
+	// ラッパー自身が応答したリクエスト（/livezやDB接続のタイムアウトなど）のメトリクスはここで記録する
+	r := gin.New()
+	r.Use(middlewares.RequestID(), middlewares.Metrics(), middlewares.Recovery(), middlewares.ErrorHandler())
+	r.Use(cors.Default())
 
-	targetDBName := "fleamarket"
-	currentDBName := os.Getenv("DB_NAME")
+	var routerMutex sync.RWMutex
+	var actualRouter *gin.Engine
 
-	if currentDBName == "postgres" {
-		var exists int
-		db.Raw("SELECT 1 FROM pg_database WHERE datname = ?", targetDBName).Scan(&exists)
-		if exists == 0 {
-			if err := db.Exec(fmt.Sprintf("CREATE DATABASE %s", targetDBName)).Error; err != nil {
-				log.Printf("Failed to create database: %v", err)
-			} else {
-				log.Printf("Created database: %s", targetDBName)
-			}
+	// DBの接続を待たずに応答する。/と/healthは従来のヘルスチェックの設定のために残す
+	live := func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{"status": constants.HealthStatusOK})
+	}
+	r.GET("/", live)
+	r.GET("/health", live)
+	r.GET("/livez", live)
+	// メトリクスはプロセス内で共有しているため、DBの接続前から出力できる
+	r.GET("/metrics", controllers.NewMetricsController(cfg.Metrics.Token).Export)
+
+	// 初期化に失敗した場合はプロセスを止めずに503を返し、次のリクエストで再試行する
+	router := func() (*gin.Engine, error) {
+		routerMutex.RLock()
+		if actualRouter != nil {
+			defer routerMutex.RUnlock()
+			return actualRouter, nil
 		}
-
-		dbHost := os.Getenv("DB_HOST")
-		dbUser := os.Getenv("DB_USER")
-		dbPassword := os.Getenv("DB_PASSWORD")
-		dbPort := os.Getenv("DB_PORT")
-
-		log.Printf("Connecting to fleamarket database: host=%s, user=%s, dbname=%s, port=%s",
-			dbHost, dbUser, targetDBName, dbPort)
-
-		// 本番環境ではsslmode=require、それ以外はsslmode=disable
-		env := os.Getenv("ENV")
-		sslmode := "disable"
-		if env == "prod" {
-			sslmode = "require"
+		routerMutex.RUnlock()
+		routerMutex.Lock()
+		defer routerMutex.Unlock()
+		if actualRouter == nil {
+			engine, err := setupRouter(db(), cfg)
+			if err != nil {
+				return nil, err
+			}
+			actualRouter = engine
+			slog.Info("Router initialized with database connection")
 		}
-
-		dsn := fmt.Sprintf(
-			"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Tokyo connect_timeout=10",
-			dbHost,
-			dbUser,
-			dbPassword,
-			targetDBName,
-			dbPort,
-			sslmode,
-		)
-
-		var err error
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		return actualRouter, nil
+	}
+	forward := func(c *gin.Context) {
+		engine, err := router()
 		if err != nil {
-			log.Printf("Failed to connect to fleamarket database: %v", err)
-			log.Printf("Connection string (without password): host=%s, user=%s, dbname=%s, port=%s",
-				dbHost, dbUser, targetDBName, dbPort)
-			panic(fmt.Sprintf("Failed to connect to fleamarket database: %v", err))
+			c.Error(apperrors.ErrUnavailable.Wrap(err))
+			return
 		}
-		log.Printf("Successfully connected to database: %s", targetDBName)
-	} else {
-		log.Printf("Using existing database: %s", currentDBName)
+		middlewares.MarkForwarded(c)
+		engine.ServeHTTP(c.Writer, c.Request)
 	}
 
-	if os.Getenv("AUTO_MIGRATE") == "true" {
-		if err := db.AutoMigrate(&models.User{}, &models.Item{}); err != nil {
-			panic("Failed to migrate database")
+	// DBの接続とマイグレーションが終わるまでは、トラフィックを流さないよう503を返す
+	r.GET("/readyz", func(c *gin.Context) {
+		select {
+		case <-ready:
+			forward(c)
+		default:
+			c.Header("Cache-Control", "no-store")
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": constants.HealthStatusUnavailable,
+				"checks": gin.H{"database": gin.H{"status": constants.HealthStatusUnavailable, "latency_ms": 0}},
+			})
 		}
-
-		// トークンブラックリスト用のSQLiteデータベースのマイグレーション
-		tokenDB := infra.SetupTokenDB()
-		if err := tokenDB.AutoMigrate(&models.BlacklistedToken{}); err != nil {
-			log.Printf("Failed to migrate token blacklist database: %v", err)
+	})
+
+	handler := func(c *gin.Context) {
+		select {
+		case <-ready:
+			forward(c)
+		case <-time.After(10 * time.Second):
+			c.Error(apperrors.ErrUnavailable.Wrap(errors.New("database connection timeout")))
 		}
 	}
 
-	return db
+	r.NoRoute(handler)
+
+	return r
 }
 
-func main() {
-	isLambda := os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+var (
+	globalDB   *gorm.DB
+	dbReady    = make(chan struct{})
+	dbInitOnce sync.Once
 
-	if isLambda {
-		log.Println("Lambda environment detected, initializing database asynchronously...")
+	// jobsContext シャットダウン時にキャンセルし、実行中のジョブの終了をbackgroundJobsで待つ
+	jobsContext, stopJobs = context.WithCancel(context.Background())
+	backgroundJobs        sync.WaitGroup
+)
 
-		port := os.Getenv("PORT")
-		if port == "" {
-			port = os.Getenv("AWS_LWA_PORT")
+func initDB(cfg *config.Config) (*gorm.DB, error) {
+	db, err := infra.OpenDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// 複数のインスタンスが同時に起動しても、アドバイザリーロックで1つずつ適用される
+	if cfg.AutoMigrate {
+		migrator, err := migrate.New(db)
+		if err != nil {
+			return nil, err
 		}
-		if port == "" {
-			port = "8080"
+		if _, err := migrator.Up(); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
 		}
+	}
 
-		r := gin.New()
-		r.Use(gin.Logger())
-		r.Use(gin.Recovery())
-		r.Use(cors.Default())
-
-		var routerMutex sync.RWMutex
-		var actualRouter *gin.Engine
-
-		r.GET("/", func(c *gin.Context) {
-			log.Println("Health check endpoint called (root path)")
-			c.JSON(http.StatusOK, gin.H{"status": "ok"})
-		})
-		r.GET("/health", func(c *gin.Context) {
-			log.Println("Health check endpoint called")
-			c.JSON(http.StatusOK, gin.H{"status": "ok"})
-		})
-
-		handler := func(c *gin.Context) {
-			log.Printf("Request received: %s %s", c.Request.Method, c.Request.URL.Path)
-			select {
-			case <-dbReady:
-				routerMutex.RLock()
-				if actualRouter == nil {
-					routerMutex.RUnlock()
-					routerMutex.Lock()
-					if actualRouter == nil {
-						actualRouter = setupRouter(globalDB)
-						log.Println("Router initialized with database connection")
-					}
-					routerMutex.Unlock()
-				} else {
-					routerMutex.RUnlock()
-				}
-				routerMutex.RLock()
-				actualRouter.ServeHTTP(c.Writer, c.Request)
-				routerMutex.RUnlock()
-			case <-time.After(10 * time.Second):
-				log.Println("Database connection timeout")
-				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database connection timeout"})
-			}
-		}
+	return db, nil
+}
 
-		r.NoRoute(handler)
+func main() {
+	// 設定に誤りがある場合はリクエストを受け付ける前に起動を中止する
+	cfg := config.MustLoad()
+	if err := logging.Setup(cfg.Log); err != nil {
+		log.Fatalf("Failed to setup logging: %v", err)
+	}
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to setup tracing: %v", err)
+	}
+	port := cfg.Port
+
+	if cfg.Lambda {
+		slog.Info("Lambda environment detected, initializing database asynchronously...")
+
+		r := setupLambdaRouter(cfg, dbReady, func() *gorm.DB { return globalDB })
 
 		srv := &http.Server{
 			Addr:         ":" + port,
@@ -210,7 +369,7 @@ func main() {
 
 		serverReady := make(chan bool, 1)
 		go func() {
-			log.Printf("Starting server on port %s (Lambda environment)", port)
+			slog.Info("Starting server (Lambda environment)", "port", port)
 			ln, err := net.Listen("tcp", ":"+port)
 			if err != nil {
 				log.Fatalf("Failed to listen on port %s: %v", port, err)
@@ -223,27 +382,29 @@ func main() {
 
 		<-serverReady
 		time.Sleep(50 * time.Millisecond)
-		log.Printf("Server started on port %s, ready to handle requests (database connecting in background)", port)
+		slog.Info("Server started, ready to handle requests (database connecting in background)", "port", port)
 
 		go func() {
 			dbInitOnce.Do(func() {
-				globalDB = initDB()
+				db, err := initDB(cfg)
+				if err != nil {
+					log.Fatalf("Failed to initialize database: %v", err)
+				}
+				globalDB = db
 				close(dbReady)
-				log.Println("Database connection established")
+				slog.Info("Database connection established")
 			})
 		}()
 
 		select {}
 	} else {
-		db := initDB()
-		r := setupRouter(db)
-
-		port := os.Getenv("PORT")
-		if port == "" {
-			port = os.Getenv("AWS_LWA_PORT")
+		db, err := initDB(cfg)
+		if err != nil {
+			log.Fatalf("Failed to initialize database: %v", err)
 		}
-		if port == "" {
-			port = "8080"
+		r, err := setupRouter(db, cfg)
+		if err != nil {
+			log.Fatalf("Failed to setup router: %v", err)
 		}
 
 		srv := &http.Server{
@@ -255,7 +416,7 @@ func main() {
 		}
 
 		go func() {
-			log.Printf("Starting server on port %s (local environment)", port)
+			slog.Info("Starting server (local environment)", "port", port)
 			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				log.Fatalf("Failed to start server: %v", err)
 			}
@@ -265,12 +426,17 @@ func main() {
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 
-		log.Println("Shutting down server...")
+		slog.Info("Shutting down server...")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Fatal("Server forced to shutdown:", err)
 		}
-		log.Println("Server exited")
+		stopJobs()
+		backgroundJobs.Wait()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush spans", "error", err)
+		}
+		slog.Info("Server exited")
 	}
 }
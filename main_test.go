@@ -1,21 +1,56 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/config"
+	"gin-fleamarket/constants"
 	"gin-fleamarket/dto"
+	"gin-fleamarket/fixtures"
 	"gin-fleamarket/infra"
+	"gin-fleamarket/jwks"
+	"gin-fleamarket/logging"
+	"gin-fleamarket/metrics"
+	"gin-fleamarket/middlewares"
+	"gin-fleamarket/migrate"
 	"gin-fleamarket/models"
+	"gin-fleamarket/oidc"
+	"gin-fleamarket/openapi"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/revocation"
+	"gin-fleamarket/scheduler"
 	"gin-fleamarket/services"
+	"gin-fleamarket/tracing"
+	"io"
 	"log"
+	"log/slog"
+	"math/big"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -24,40 +59,66 @@ func TestMain(m *testing.M) {
 		log.Fatal("Error loading .env.test file")
 	}
 
+	// アップロードされたファイルをリポジトリ内に残さない
+	uploadDir, err := os.MkdirTemp("", "fleamarket-uploads")
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("UPLOAD_DIR", uploadDir)
+
 	code := m.Run()
+	os.RemoveAll(uploadDir)
 
 	os.Exit(code)
 }
 
+// setupTestData testdata/fixtures.yamlのユーザーと商品を投入する（パスワードはすべてpassword123）
 func setupTestData(db *gorm.DB) {
-	items := []models.Item{
-		{Name: "テストアイテム1", Price: 1000, Description: "", SoldOut: false, UserID: 1},
-		{Name: "テストアイテム2", Price: 2000, Description: "テスト2", SoldOut: true, UserID: 1},
-		{Name: "テストアイテム3", Price: 3000, Description: "テスト3", SoldOut: false, UserID: 2},
-	}
-
-	users := []models.User{
-		{Email: "test1@example.com", Password: "password1"},
-		{Email: "test2@example.com", Password: "password2"},
+	fixture, err := fixtures.LoadFile("testdata/fixtures.yaml")
+	if err != nil {
+		panic(err)
 	}
-
-	for _, user := range users {
-		db.Create(&user)
-	}
-
-	for _, item := range items {
-		db.Create(&item)
+	if _, err := fixtures.NewSeeder(db, bcrypt.MinCost).Apply(fixture); err != nil {
+		panic(err)
 	}
 }
 
 func setup() *gin.Engine {
-	db := infra.SetupDB()
-	db.AutoMigrate(&models.Item{}, &models.User{})
+	router, _ := setupWithDB()
+	return router
+}
+
+func setupWithDB() (*gin.Engine, *gorm.DB) {
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+	db, err := infra.OpenDB(cfg)
+	if err != nil {
+		panic(err)
+	}
+	migrator, err := migrate.New(db)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		panic(err)
+	}
 
 	setupTestData(db)
-	router := setupRouter(db)
+	router, err := setupRouter(db, cfg)
+	if err != nil {
+		panic(err)
+	}
 
-	return router
+	return router, db
+}
+
+// testKeyRing setupRouterがDBに登録した署名鍵を読み込む
+func testKeyRing(t *testing.T, db *gorm.DB) services.IKeyRing {
+	keyRing, err := services.NewKeyRing(repositories.NewSigningKeyRepository(db), "", "", time.Time{})
+	assert.NoError(t, err)
+	return keyRing
 }
 
 func TestFindAll(t *testing.T) {
@@ -72,13 +133,14 @@ func TestFindAll(t *testing.T) {
 	json.Unmarshal([]byte(w.Body.String()), &res)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, 3, len(res["data"]))
+	// 売り切れの商品は出品者本人以外には表示されない
+	assert.Equal(t, 2, len(res["data"]))
 }
 
 func TestCreate(t *testing.T) {
-	router := setup()
+	router, db := setupWithDB()
 
-	token, err := services.CreateToken(1, "test1@example.com", "user")
+	token, err := services.CreateAccessToken(testKeyRing(t, db), 1, "test1@example.com", "user", "")
 	assert.Equal(t, nil, err)
 
 	createItemInput := dto.CreateItemInput{
@@ -121,3 +183,1973 @@ func TestCreatedUnauthorized(t *testing.T) {
 
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+// fakeOIDCProvider テスト用のローカルOpenID Connectプロバイダ
+type fakeOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization
+}
+
+type fakeAuthorization struct {
+	challenge     string
+	nonce         string
+	subject       string
+	email         string
+	emailVerified bool
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	p := &fakeOIDCProvider{key: key, clientID: "fleamarket-client", codes: map[string]fakeAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks.JSONWebKeySet{Keys: []jwks.JSONWebKey{{
+			Kty: "RSA",
+			Kid: "fake-key",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.mu.Lock()
+		authorization, ok := p.codes[r.Form.Get("code")]
+		delete(p.codes, r.Form.Get("code"))
+		p.mu.Unlock()
+		if !ok || oidc.CodeChallenge(r.Form.Get("code_verifier")) != authorization.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            p.server.URL,
+			"aud":            p.clientID,
+			"sub":            authorization.subject,
+			"email":          authorization.email,
+			"email_verified": authorization.emailVerified,
+			"nonce":          authorization.nonce,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "fake-key"
+		idToken, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	t.Setenv("OIDC_PROVIDERS", "fake")
+	t.Setenv("OIDC_FAKE_ISSUER", p.server.URL)
+	t.Setenv("OIDC_FAKE_CLIENT_ID", p.clientID)
+	t.Setenv("OIDC_FAKE_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_FAKE_REDIRECT_URL", "http://localhost/auth/oidc/fake/callback")
+	return p
+}
+
+// authorize ユーザーがプロバイダで同意した状態を再現し、コールバックに渡すcodeを返す
+func (p *fakeOIDCProvider) authorize(t *testing.T, authURL string, subject string, email string, emailVerified bool) string {
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+
+	code, _ := oidc.RandomString()
+	p.mu.Lock()
+	p.codes[code] = fakeAuthorization{
+		challenge:     parsed.Query().Get("code_challenge"),
+		nonce:         parsed.Query().Get("nonce"),
+		subject:       subject,
+		email:         email,
+		emailVerified: emailVerified,
+	}
+	p.mu.Unlock()
+	return code
+}
+
+// startOIDCLogin 認可URL、state、ブラウザに設定されたstateのCookieを返す
+func startOIDCLogin(t *testing.T, router *gin.Engine) (string, string, *http.Cookie) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/oidc/fake/login", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	authURL := w.Header().Get("Location")
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	var stateCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == constants.OIDCStateCookie {
+			stateCookie = cookie
+		}
+	}
+	assert.NotNil(t, stateCookie)
+	assert.True(t, stateCookie.HttpOnly)
+	return authURL, parsed.Query().Get("state"), stateCookie
+}
+
+func oidcCallback(router *gin.Engine, code string, state string, stateCookie *http.Cookie) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/oidc/fake/callback?code="+code+"&state="+state, nil)
+	if stateCookie != nil {
+		req.AddCookie(stateCookie)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	router := setup()
+
+	authURL, state, stateCookie := startOIDCLogin(t, router)
+	code := provider.authorize(t, authURL, "fake-subject-1", "social@example.com", true)
+
+	w := oidcCallback(router, code, state, stateCookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var res dto.LoginResponse
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.NotEmpty(t, res.AccessToken)
+
+	reqBody, _ := json.Marshal(dto.CreateItemInput{Name: "ソーシャル出品", Price: 500})
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/items", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+res.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// 同じstateの再利用は拒否される
+	code = provider.authorize(t, authURL, "fake-subject-1", "social@example.com", true)
+	assert.Equal(t, http.StatusUnauthorized, oidcCallback(router, code, state, stateCookie).Code)
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	router := setup()
+
+	// 攻撃者が自分で開始したログインのコールバックURLを、被害者のブラウザで開かせる
+	attackerURL, attackerState, _ := startOIDCLogin(t, router)
+	attackerCode := provider.authorize(t, attackerURL, "fake-attacker", "attacker@example.com", true)
+	assert.Equal(t, http.StatusUnauthorized, oidcCallback(router, attackerCode, attackerState, nil).Code)
+
+	_, _, victimCookie := startOIDCLogin(t, router)
+	w := oidcCallback(router, attackerCode, attackerState, victimCookie)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "oidc_invalid_state")
+}
+
+func TestOIDCLoginRequiresVerifiedEmail(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	router := setup()
+
+	authURL, state, stateCookie := startOIDCLogin(t, router)
+	code := provider.authorize(t, authURL, "fake-subject-2", "test1@example.com", false)
+
+	assert.Equal(t, http.StatusUnauthorized, oidcCallback(router, code, state, stateCookie).Code)
+}
+
+func TestJWKSVerifiesIssuedTokensAcrossRotation(t *testing.T) {
+	router, db := setupWithDB()
+	keyRing := testKeyRing(t, db)
+
+	token, err := services.CreateAccessToken(keyRing, 1, "test1@example.com", "user", "")
+	assert.NoError(t, err)
+
+	fetchKeys := func() jwks.JSONWebKeySet {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var keySet jwks.JSONWebKeySet
+		json.Unmarshal(w.Body.Bytes(), &keySet)
+		return keySet
+	}
+	verify := func(keySet jwks.JSONWebKeySet, tokenString string) error {
+		_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			for _, key := range keySet.Keys {
+				if key.Kid == token.Header["kid"] {
+					return key.PublicKey()
+				}
+			}
+			return nil, fmt.Errorf("kid not found")
+		}, jwt.WithValidMethods([]string{"RS256"}))
+		return err
+	}
+
+	assert.NoError(t, verify(fetchKeys(), *token))
+
+	db.Model(&models.User{}).Where("id = ?", 1).Update("role", "admin")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/admin/keys/rotate", nil)
+	req.Header.Set("Authorization", "Bearer "+*token)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	keyRing = testKeyRing(t, db)
+	rotatedToken, err := services.CreateAccessToken(keyRing, 1, "test1@example.com", "user", "")
+	assert.NoError(t, err)
+
+	// ローテーション後も旧鍵で署名されたトークンは検証できる
+	keySet := fetchKeys()
+	assert.Equal(t, 2, len(keySet.Keys))
+	assert.NoError(t, verify(keySet, *token))
+	assert.NoError(t, verify(keySet, *rotatedToken))
+}
+
+func TestLegacyHS256TokensAcceptedUntilCutoff(t *testing.T) {
+	_, db := setupWithDB()
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("legacy-secret"))
+	assert.NoError(t, err)
+
+	parse := func(legacyUntil time.Time) error {
+		keyRing, err := services.NewKeyRing(repositories.NewSigningKeyRepository(db), "", "legacy-secret", legacyUntil)
+		assert.NoError(t, err)
+		_, err = jwt.Parse(legacyToken, keyRing.Keyfunc, jwt.WithValidMethods(keyRing.ValidMethods()))
+		return err
+	}
+
+	assert.NoError(t, parse(time.Now().Add(time.Hour)))
+	// 期限を過ぎた場合や期限を設定していない場合は、SECRET_KEYがあっても受け付けない
+	assert.Error(t, parse(time.Now().Add(-time.Hour)))
+	assert.Error(t, parse(time.Time{}))
+}
+
+func signupAndLogin(t *testing.T, router *gin.Engine, email string) dto.LoginResponse {
+	reqBody, _ := json.Marshal(dto.SignupInput{Email: email, Password: "password123"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/signup", bytes.NewBuffer(reqBody))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	reqBody, _ = json.Marshal(dto.LoginInput{Email: email, Password: "password123"})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/auth/login", bytes.NewBuffer(reqBody))
+	req.Header.Set("User-Agent", "fleamarket-test")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var res dto.LoginResponse
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return res
+}
+
+func refresh(router *gin.Engine, refreshToken string) *httptest.ResponseRecorder {
+	reqBody, _ := json.Marshal(dto.RefreshTokenInput{RefreshToken: refreshToken})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(reqBody))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// doRequest bodyをJSONにして送り、tokenがあればBearerトークンとして付ける
+func doRequest(t *testing.T, router *gin.Engine, method string, path string, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	reqBody, _ := json.Marshal(body)
+	return doRawRequest(t, router, method, path, token, bytes.NewBuffer(reqBody))
+}
+
+// doRawRequest bodyをそのまま送る。不正なJSONを送るテストに使う
+func doRawRequest(t *testing.T, router *gin.Engine, method string, path string, token string, body io.Reader) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(method, path, body)
+	assert.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	router := setup()
+	first := signupAndLogin(t, router, "reuse@example.com")
+
+	w := refresh(router, first.RefreshToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var second dto.LoginResponse
+	json.Unmarshal(w.Body.Bytes(), &second)
+
+	// ローテーション済みのトークンを再提示するとファミリー全体が失効する
+	w = refresh(router, first.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = refresh(router, second.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+second.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRefreshTokenReflectsCurrentUser(t *testing.T) {
+	router, db := setupWithDB()
+	db.Model(&models.User{}).Where("email = ?", "test1@example.com").Update("role", "admin")
+	reqBody, _ := json.Marshal(dto.LoginInput{Email: "test1@example.com", Password: "password123"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(reqBody))
+	router.ServeHTTP(w, req)
+	var admin dto.LoginResponse
+	json.Unmarshal(w.Body.Bytes(), &admin)
+
+	// 降格後のリフレッシュでは、リフレッシュトークンのクレームではなく現在のロールで発行する
+	db.Model(&models.User{}).Where("email = ?", "test1@example.com").Update("role", "user")
+	w = refresh(router, admin.RefreshToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var refreshed dto.LoginResponse
+	json.Unmarshal(w.Body.Bytes(), &refreshed)
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(refreshed.AccessToken, claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", claims["role"])
+
+	// 停止中のユーザーはリフレッシュできない
+	db.Model(&models.User{}).Where("email = ?", "test1@example.com").Update("status", constants.UserStatusSuspended)
+	w = refresh(router, refreshed.RefreshToken)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestListAndRevokeSessions(t *testing.T) {
+	router := setup()
+	tokens := signupAndLogin(t, router, "sessions@example.com")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var res map[string][]dto.SessionResponse
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Equal(t, 1, len(res["data"]))
+	assert.Equal(t, "fleamarket-test", res["data"][0].Device)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/me/sessions/%d", res["data"][0].ID), nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = refresh(router, tokens.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAPIKeyAuthenticationAndScopes(t *testing.T) {
+	router := setup()
+	tokens := signupAndLogin(t, router, "apikey@example.com")
+
+	reqBody, _ := json.Marshal(dto.CreateAPIKeyInput{Name: "出品スクリプト", Scopes: []string{"items:read"}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/me/api-keys", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created map[string]dto.CreateAPIKeyResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	apiKey := created["data"].Key
+	assert.NotEmpty(t, apiKey)
+
+	// 読み取りスコープのみのキーでは出品できない
+	reqBody, _ = json.Marshal(dto.CreateItemInput{Name: "スクリプト出品", Price: 100})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/items", bytes.NewBuffer(reqBody))
+	req.Header.Set("Authorization", "ApiKey "+apiKey)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// APIキーでアカウント管理のAPIは使えない
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me/api-keys", nil)
+	req.Header.Set("Authorization", "ApiKey "+apiKey)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me/api-keys", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), apiKey)
+
+	// 出品中の商品は読み取りスコープで閲覧でき、売り切れた他人の商品は見つからない
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items/1", nil)
+	req.Header.Set("Authorization", "ApiKey "+apiKey)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items/2", nil)
+	req.Header.Set("Authorization", "ApiKey "+apiKey)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items/1", nil)
+	req.Header.Set("Authorization", "ApiKey "+apiKey+"x")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestModeratorPermissions(t *testing.T) {
+	router, db := setupWithDB()
+	moderator := signupAndLogin(t, router, "moderator@example.com")
+	admin := signupAndLogin(t, router, "admin@example.com")
+	db.Model(&models.User{}).Where("email = ?", "moderator@example.com").Update("role", "moderator")
+	db.Model(&models.User{}).Where("email = ?", "admin@example.com").Update("role", "admin")
+
+	// モデレーターは商品を取り下げられるが、ロール管理はできない
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "DELETE", "/items/1", moderator.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "GET", "/admin/roles", moderator.AccessToken, nil).Code)
+
+	// 管理者がモデレーターの権限を外すと即座に反映される
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "PUT", "/admin/roles/moderator", admin.AccessToken, dto.UpdateRoleInput{Permissions: &[]string{}}).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "DELETE", "/items/3", moderator.AccessToken, nil).Code)
+
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "PUT", "/admin/roles/admin", admin.AccessToken, dto.UpdateRoleInput{Permissions: &[]string{}}).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, router, "POST", "/admin/roles", admin.AccessToken, dto.CreateRoleInput{Name: "support", Permissions: []string{"no.such.permission"}}).Code)
+	assert.Equal(t, http.StatusCreated, doRequest(t, router, "POST", "/admin/roles", admin.AccessToken, dto.CreateRoleInput{Name: "support", Permissions: []string{"order.refund"}}).Code)
+}
+
+func TestItemOwnershipPolicy(t *testing.T) {
+	router := setup()
+	seller := signupAndLogin(t, router, "seller@example.com")
+	buyer := signupAndLogin(t, router, "buyer@example.com")
+
+	w := doRequest(t, router, "POST", "/items", seller.AccessToken, dto.CreateItemInput{Name: "出品テスト", Price: 500})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]models.Item
+	json.Unmarshal(w.Body.Bytes(), &created)
+	itemPath := fmt.Sprintf("/items/%d", created["data"].ID)
+
+	// 出品中の商品は他のユーザーや未ログインでも閲覧できる
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "GET", itemPath, buyer.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "GET", itemPath, "", nil).Code)
+
+	// 出品者以外は編集・削除できない
+	newName := "書き換え"
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "PUT", itemPath, buyer.AccessToken, dto.UpdateItemInput{Name: &newName}).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "DELETE", itemPath, buyer.AccessToken, nil).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, router, "DELETE", itemPath, "", nil).Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "DELETE", itemPath, seller.AccessToken, nil).Code)
+
+	// 売り切れの商品は出品者本人だけが閲覧でき、商品情報は変更できない
+	soldOut := true
+	assert.Equal(t, http.StatusNotFound, doRequest(t, router, "GET", "/items/2", buyer.AccessToken, nil).Code)
+	w = doRequest(t, router, "POST", "/items", seller.AccessToken, dto.CreateItemInput{Name: "売り切れテスト", Price: 800})
+	json.Unmarshal(w.Body.Bytes(), &created)
+	itemPath = fmt.Sprintf("/items/%d", created["data"].ID)
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "PUT", itemPath, seller.AccessToken, dto.UpdateItemInput{SoldOut: &soldOut}).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(t, router, "GET", itemPath, buyer.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "GET", itemPath, seller.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "PUT", itemPath, seller.AccessToken, dto.UpdateItemInput{Name: &newName}).Code)
+}
+
+func TestAdminUserManagement(t *testing.T) {
+	router, db := setupWithDB()
+	admin := signupAndLogin(t, router, "admin@example.com")
+	target := signupAndLogin(t, router, "target@example.com")
+	db.Model(&models.User{}).Where("email = ?", "admin@example.com").Update("role", "admin")
+
+	w := doRequest(t, router, "GET", "/admin/users?q=TARGET", admin.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var found struct {
+		Data []dto.UserResponse `json:"data"`
+		Meta dto.PageMeta       `json:"meta"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &found)
+	assert.Equal(t, int64(1), found.Meta.Total)
+	targetPath := fmt.Sprintf("/admin/users/%d", found.Data[0].ID)
+
+	w = doRequest(t, router, "GET", "/admin/users?per_page=3&page=2", admin.AccessToken, nil)
+	json.Unmarshal(w.Body.Bytes(), &found)
+	assert.Equal(t, int64(4), found.Meta.Total)
+	assert.Equal(t, 1, len(found.Data))
+
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "GET", "/admin/users", target.AccessToken, nil).Code)
+	assert.Contains(t, doRequest(t, router, "GET", "/admin/users/1/items", admin.AccessToken, nil).Body.String(), "テストアイテム2")
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, router, "PUT", targetPath+"/role", admin.AccessToken, dto.UpdateUserRoleInput{Role: "no-such-role"}).Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "PUT", targetPath+"/role", admin.AccessToken, dto.UpdateUserRoleInput{Role: "moderator"}).Code)
+
+	// BANすると発行済みのトークンはすべて使えなくなり、再ログインもできない
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "POST", targetPath+"/ban", admin.AccessToken, dto.UpdateUserStatusInput{Reason: "spam"}).Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, router, "GET", "/me/sessions", target.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh(router, target.RefreshToken).Code)
+	w = doRequest(t, router, "POST", "/auth/login", "", dto.LoginInput{Email: "target@example.com", Password: "password123"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "POST", targetPath+"/reinstate", admin.AccessToken, nil).Code)
+	w = doRequest(t, router, "POST", "/auth/login", "", dto.LoginInput{Email: "target@example.com", Password: "password123"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 自分自身のロールや状態は変更できない
+	json.Unmarshal(doRequest(t, router, "GET", "/admin/users?role=admin", admin.AccessToken, nil).Body.Bytes(), &found)
+	assert.Equal(t, int64(1), found.Meta.Total)
+	adminPath := fmt.Sprintf("/admin/users/%d", found.Data[0].ID)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "PUT", adminPath+"/role", admin.AccessToken, dto.UpdateUserRoleInput{Role: "user"}).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "POST", adminPath+"/suspend", admin.AccessToken, nil).Code)
+}
+
+func TestAdminUserManagementPreventsPrivilegeEscalation(t *testing.T) {
+	router, db := setupWithDB()
+	admin := signupAndLogin(t, router, "admin@example.com")
+	manager := signupAndLogin(t, router, "manager@example.com")
+	signupAndLogin(t, router, "member@example.com")
+	db.Model(&models.User{}).Where("email = ?", "admin@example.com").Update("role", "admin")
+
+	userPath := func(email string) string {
+		var user models.User
+		db.Where("email = ?", email).First(&user)
+		return fmt.Sprintf("/admin/users/%d", user.ID)
+	}
+
+	// モデレーターにユーザー管理とBANの権限を与える
+	permissions := []string{constants.PermItemDeleteAny, constants.PermUserManage, constants.PermUserBan}
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "PUT", "/admin/roles/moderator", admin.AccessToken, dto.UpdateRoleInput{Permissions: &permissions}).Code)
+	db.Model(&models.User{}).Where("email = ?", "manager@example.com").Update("role", "moderator")
+
+	// 自分自身の昇格も、自分が持たない権限を持つロールの付与もできない
+	w := doRequest(t, router, "PUT", userPath("manager@example.com")+"/role", manager.AccessToken, dto.UpdateUserRoleInput{Role: "admin"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot_manage_self")
+	w = doRequest(t, router, "PUT", userPath("member@example.com")+"/role", manager.AccessToken, dto.UpdateUserRoleInput{Role: "admin"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "insufficient_privilege")
+
+	// 自分の権限の範囲内のロールは付与できる
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "PUT", userPath("member@example.com")+"/role", manager.AccessToken, dto.UpdateUserRoleInput{Role: "moderator"}).Code)
+
+	// 管理者は停止・BANできない
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "POST", userPath("admin@example.com")+"/ban", manager.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "POST", userPath("admin@example.com")+"/suspend", manager.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, router, "POST", userPath("member@example.com")+"/suspend", manager.AccessToken, nil).Code)
+
+	var user models.User
+	db.Where("email = ?", "manager@example.com").First(&user)
+	assert.Equal(t, "moderator", user.Role)
+}
+
+func TestBootstrapAdminWithToken(t *testing.T) {
+	t.Setenv("ADMIN_BOOTSTRAP_TOKEN", "bootstrap-secret")
+	router := setup()
+
+	bootstrap := func(token string, email string) int {
+		reqBody, _ := json.Marshal(dto.SignupInput{Email: email, Password: "password123"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/auth/bootstrap", bytes.NewBuffer(reqBody))
+		req.Header.Set("Authorization", "Bootstrap "+token)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 通常の登録では管理者にならない
+	first := signupAndLogin(t, router, "first@example.com")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/roles", nil)
+	req.Header.Set("Authorization", "Bearer "+first.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, bootstrap("wrong-token", "root@example.com"))
+	assert.Equal(t, http.StatusCreated, bootstrap("bootstrap-secret", "root@example.com"))
+	// 管理者が存在する場合、トークンは再利用できない
+	assert.Equal(t, http.StatusConflict, bootstrap("bootstrap-secret", "root2@example.com"))
+
+	reqBody, _ := json.Marshal(dto.LoginInput{Email: "root@example.com", Password: "password123"})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/auth/login", bytes.NewBuffer(reqBody))
+	router.ServeHTTP(w, req)
+	var root dto.LoginResponse
+	json.Unmarshal(w.Body.Bytes(), &root)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/roles", nil)
+	req.Header.Set("Authorization", "Bearer "+root.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAdminImpersonation(t *testing.T) {
+	router, db := setupWithDB()
+	admin := signupAndLogin(t, router, "support@example.com")
+	customer := signupAndLogin(t, router, "customer@example.com")
+	db.Model(&models.User{}).Where("email = ?", "support@example.com").Update("role", "admin")
+
+	var customerUser models.User
+	db.First(&customerUser, "email = ?", "customer@example.com")
+	impersonatePath := fmt.Sprintf("/admin/users/%d/impersonate", customerUser.ID)
+
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "POST", impersonatePath, customer.AccessToken, dto.ImpersonateInput{Reason: "test"}).Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, router, "POST", impersonatePath, admin.AccessToken, nil).Code)
+
+	w := doRequest(t, router, "POST", impersonatePath, admin.AccessToken, dto.ImpersonateInput{Reason: "問い合わせ#123の調査"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var res map[string]dto.ImpersonationResponse
+	json.Unmarshal(w.Body.Bytes(), &res)
+	impersonated := res["data"]
+	assert.True(t, impersonated.ReadOnly)
+
+	// 対象ユーザーとして閲覧できるが、変更や管理操作はできない
+	w = doRequest(t, router, "GET", "/me/sessions", impersonated.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "support@example.com", w.Header().Get("X-Impersonated-By"))
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "POST", "/items", impersonated.AccessToken, dto.CreateItemInput{Name: "なりすまし出品", Price: 100}).Code)
+
+	// 書き込みを許可しても管理者の権限は引き継がない
+	db.Model(&models.User{}).Where("id = ?", customerUser.ID).Update("role", "moderator")
+	w = doRequest(t, router, "POST", impersonatePath, admin.AccessToken, dto.ImpersonateInput{Reason: "出品の不具合の再現", AllowWrite: true})
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Equal(t, http.StatusCreated, doRequest(t, router, "POST", "/items", res["data"].AccessToken, dto.CreateItemInput{Name: "再現用の出品", Price: 100}).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "DELETE", "/items/3", res["data"].AccessToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "GET", "/admin/roles", res["data"].AccessToken, nil).Code)
+	// 書き込みを許可しても本人の認証情報やセッション、プロフィールは変更できない
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "POST", "/me/api-keys", res["data"].AccessToken, dto.CreateAPIKeyInput{Name: "なりすまし", Scopes: []string{"items:read"}}).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "DELETE", "/me/sessions/1", res["data"].AccessToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "PUT", "/me/profile", res["data"].AccessToken, dto.UpdateProfileInput{}).Code)
+
+	var starts, requests int64
+	db.Model(&models.AuditLog{}).Where("action = ?", "impersonation.start").Count(&starts)
+	db.Model(&models.AuditLog{}).Where("action = ?", "impersonation.request").Count(&requests)
+	assert.Equal(t, int64(2), starts)
+	assert.Equal(t, int64(8), requests)
+}
+
+func TestAuditLogRecordsAndDetectsTampering(t *testing.T) {
+	router, db := setupWithDB()
+	admin := signupAndLogin(t, router, "auditor@example.com")
+	user := signupAndLogin(t, router, "audited@example.com")
+	db.Model(&models.User{}).Where("email = ?", "auditor@example.com").Update("role", "admin")
+
+	assert.Equal(t, http.StatusForbidden, doRequest(t, router, "GET", "/admin/audit-logs", user.AccessToken, nil).Code)
+	doRequest(t, router, "POST", "/auth/login", "", dto.LoginInput{Email: "audited@example.com", Password: "wrong-password"})
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, router, "PUT", "/me/password", user.AccessToken, dto.ChangePasswordInput{CurrentPassword: "wrong-password", NewPassword: "new-password123"}).Code)
+	assert.Equal(t, http.StatusNoContent, doRequest(t, router, "PUT", "/me/password", user.AccessToken, dto.ChangePasswordInput{CurrentPassword: "password123", NewPassword: "new-password123"}).Code)
+	// パスワード変更ですべてのセッションが失効する
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, router, "GET", "/me/sessions", user.AccessToken, nil).Code)
+
+	var res struct {
+		Data []dto.AuditLogResponse `json:"data"`
+		Meta dto.PageMeta           `json:"meta"`
+	}
+	w := doRequest(t, router, "GET", "/admin/audit-logs?action=auth.login.failure", admin.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Equal(t, int64(1), res.Meta.Total)
+	assert.Equal(t, "wrong_password", res.Data[0].Detail["reason"])
+	// 既存のユーザーはtarget_idで特定できるため、メールアドレスは記録しない
+	assert.NotContains(t, res.Data[0].Detail, "email")
+
+	json.Unmarshal(doRequest(t, router, "GET", "/admin/audit-logs?action=auth.password.change", admin.AccessToken, nil).Body.Bytes(), &res)
+	assert.Equal(t, int64(1), res.Meta.Total)
+
+	var verification map[string]dto.AuditChainVerification
+	json.Unmarshal(doRequest(t, router, "GET", "/admin/audit-logs/verify", admin.AccessToken, nil).Body.Bytes(), &verification)
+	assert.True(t, verification["data"].Valid)
+	assert.Equal(t, int64(6), verification["data"].Checked)
+
+	// IPアドレスとUser-Agentはハッシュに含まれないため、消去しても検証に影響しない
+	db.Exec("UPDATE audit_logs SET ip_address = '', user_agent = '' WHERE id = ?", 3)
+	json.Unmarshal(doRequest(t, router, "GET", "/admin/audit-logs/verify", admin.AccessToken, nil).Body.Bytes(), &verification)
+	assert.True(t, verification["data"].Valid)
+
+	// 途中の行を書き換えるとチェーンの検証に失敗する
+	db.Exec("UPDATE audit_logs SET target_id = ? WHERE id = ?", 999, 3)
+	json.Unmarshal(doRequest(t, router, "GET", "/admin/audit-logs/verify", admin.AccessToken, nil).Body.Bytes(), &verification)
+	assert.False(t, verification["data"].Valid)
+	assert.Equal(t, uint(3), verification["data"].BrokenAt)
+}
+
+func TestProfileAndPublicSellerPage(t *testing.T) {
+	router, db := setupWithDB()
+	seller := signupAndLogin(t, router, "profile@example.com")
+
+	displayName := "のみの市商店"
+	prefecture := "大阪府"
+	invalidPrefecture := "大阪都"
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, router, "PUT", "/me/profile", seller.AccessToken, dto.UpdateProfileInput{Prefecture: &invalidPrefecture}).Code)
+	w := doRequest(t, router, "PUT", "/me/profile", seller.AccessToken, dto.UpdateProfileInput{DisplayName: &displayName, Prefecture: &prefecture})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	upload := func(filename string, content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("avatar", filename)
+		part.Write(content)
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/me/profile/avatar", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+seller.AccessToken)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 拡張子ではなく内容で画像形式を判定する
+	assert.Equal(t, http.StatusBadRequest, upload("avatar.png", []byte("<html>not an image</html>")).Code)
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+	w = upload("avatar.png", png)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var profile map[string]dto.ProfileResponse
+	json.Unmarshal(w.Body.Bytes(), &profile)
+	assert.Regexp(t, `^/uploads/[0-9a-f]{32}\.png$`, profile["data"].AvatarURL)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", profile["data"].AvatarURL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var user models.User
+	db.First(&user, "email = ?", "profile@example.com")
+	doRequest(t, router, "POST", "/items", seller.AccessToken, dto.CreateItemInput{Name: "出品中の商品", Price: 500})
+	soldOut := models.Item{Name: "売り切れの商品", Price: 800, SoldOut: true, UserID: user.ID}
+	db.Create(&soldOut)
+
+	// 公開ページにはメールアドレスや売り切れの商品を含めない
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/users/%d", user.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "profile@example.com")
+	var page map[string]dto.PublicUserResponse
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, "のみの市商店", page["data"].DisplayName)
+	assert.Equal(t, "大阪府", page["data"].Prefecture)
+	assert.Equal(t, 1, len(page["data"].Items))
+}
+
+func TestAccountExportAndDeletion(t *testing.T) {
+	router, db := setupWithDB()
+	seller := signupAndLogin(t, router, "leaving@example.com")
+
+	var user models.User
+	db.First(&user, "email = ?", "leaving@example.com")
+	doRequest(t, router, "POST", "/items", seller.AccessToken, dto.CreateItemInput{Name: "出品中の商品", Price: 500})
+	sold := models.Item{Name: "売れた商品", Price: 800, SoldOut: true, UserID: user.ID}
+	db.Create(&sold)
+	// 管理者が本人を対象に行った操作
+	auditService := services.NewAuditService(repositories.NewAuditLogRepository(db))
+	auditService.Record(services.AuditEntry{
+		Action:   constants.AuditUserStatusChange,
+		ActorID:  9999,
+		TargetID: user.ID,
+		Client:   services.ClientInfo{IPAddress: "198.51.100.1", UserAgent: "admin-browser"},
+		Detail:   map[string]interface{}{"reason": "内部メモ"},
+	})
+
+	w := doRequest(t, router, "GET", "/me/export", seller.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	var export map[string]dto.AccountExport
+	json.Unmarshal(w.Body.Bytes(), &export)
+	assert.Equal(t, "leaving@example.com", export["data"].Profile.Email)
+	assert.Equal(t, 2, len(export["data"].Items))
+	assert.Equal(t, 1, len(export["data"].Sessions))
+	assert.NotEmpty(t, export["data"].AuditLogs)
+	// 管理者が本人を対象に行った操作は、管理者のIPアドレス・User-Agent・詳細を含めずにエクスポートする
+	for _, entry := range export["data"].AuditLogs {
+		if entry.ActorID == user.ID {
+			continue
+		}
+		assert.Empty(t, entry.IPAddress)
+		assert.Empty(t, entry.UserAgent)
+		assert.Empty(t, entry.Detail)
+	}
+	assert.Contains(t, w.Body.String(), "fleamarket-test")
+	assert.NotContains(t, w.Body.String(), "admin-browser")
+	assert.NotContains(t, w.Body.String(), "内部メモ")
+	var recorded int64
+	db.Model(&models.AuditLog{}).Where("actor_id = ? AND user_agent <> ''", user.ID).Count(&recorded)
+	assert.NotZero(t, recorded)
+
+	// セッションに紐づかないトークン（なりすましや旧形式のトークン）
+	sessionless, err := services.CreateAccessToken(testKeyRing(t, db), user.ID, "leaving@example.com", "user", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusNoContent, doRequest(t, router, "DELETE", "/me", seller.AccessToken, nil).Code)
+
+	// 売れた商品は購入者の取引履歴として残り、出品中の商品は取り下げられる
+	var items []models.Item
+	db.Where("user_id = ?", user.ID).Find(&items)
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, sold.ID, items[0].ID)
+
+	db.First(&user, user.ID)
+	assert.Equal(t, constants.UserStatusDeleted, user.Status)
+	assert.NotContains(t, user.Email, "leaving")
+
+	// 監査ログの行は残るが、本人が操作した行のIPアドレスとUser-Agentは消去され、チェーンの検証も通る
+	var auditLogs []models.AuditLog
+	db.Where("actor_id = ?", user.ID).Find(&auditLogs)
+	assert.NotEmpty(t, auditLogs)
+	for _, entry := range auditLogs {
+		assert.Empty(t, entry.IPAddress)
+		assert.Empty(t, entry.UserAgent)
+	}
+	// 管理者が本人を対象に行った操作の記録は、管理者の説明責任のためにそのまま残す
+	var adminEntry models.AuditLog
+	db.First(&adminEntry, "actor_id = ? AND target_id = ?", 9999, user.ID)
+	assert.Equal(t, "198.51.100.1", adminEntry.IPAddress)
+	assert.Equal(t, "admin-browser", adminEntry.UserAgent)
+	verification, err := auditService.Verify()
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid)
+
+	// 発行済みのトークンは使えず、同じメールアドレスでログインすることもできない
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, router, "GET", "/me/profile", seller.AccessToken, nil).Code)
+	assert.NotEqual(t, http.StatusOK, refresh(router, seller.RefreshToken).Code)
+	loginBody, _ := json.Marshal(dto.LoginInput{Email: "leaving@example.com", Password: "password123"})
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(loginBody))
+	router.ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/users/%d", user.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 同じメールアドレスで別のユーザーが登録しても、退会したユーザーのトークンはそのユーザーとして扱われない
+	signupAndLogin(t, router, "leaving@example.com")
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, router, "GET", "/me/profile", *sessionless, nil).Code)
+}
+
+func TestCookieAuthModeRequiresCSRFToken(t *testing.T) {
+	router := setup()
+	signupAndLogin(t, router, "browser@example.com")
+
+	reqBody, _ := json.Marshal(dto.LoginInput{Email: "browser@example.com", Password: "password123"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/login?mode=cookie", bytes.NewBuffer(reqBody))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	// トークンはボディに含めず、HttpOnlyのCookieでのみ返す
+	assert.NotContains(t, w.Body.String(), "accessToken")
+	var login dto.CookieLoginResponse
+	json.Unmarshal(w.Body.Bytes(), &login)
+	assert.NotEmpty(t, login.CSRFToken)
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	assert.True(t, cookies[constants.AccessTokenCookie].HttpOnly)
+	assert.True(t, cookies[constants.AccessTokenCookie].Secure)
+	assert.True(t, cookies[constants.RefreshTokenCookie].HttpOnly)
+	assert.False(t, cookies[constants.CSRFTokenCookie].HttpOnly)
+
+	request := func(method string, path string, csrfToken string, body interface{}) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(reqBody))
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		if csrfToken != "" {
+			req.Header.Set(constants.CSRFTokenHeader, csrfToken)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	displayName := "ブラウザ"
+	assert.Equal(t, http.StatusOK, request("GET", "/me/profile", "", nil).Code)
+	assert.Equal(t, http.StatusForbidden, request("PUT", "/me/profile", "", dto.UpdateProfileInput{DisplayName: &displayName}).Code)
+	assert.Equal(t, http.StatusForbidden, request("PUT", "/me/profile", "wrong", dto.UpdateProfileInput{DisplayName: &displayName}).Code)
+	assert.Equal(t, http.StatusOK, request("PUT", "/me/profile", login.CSRFToken, dto.UpdateProfileInput{DisplayName: &displayName}).Code)
+
+	// リフレッシュもCookieで行い、新しいトークンはCookieで返す
+	assert.Equal(t, http.StatusForbidden, request("POST", "/auth/refresh", "", nil).Code)
+	w = request("POST", "/auth/refresh", login.CSRFToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	// リフレッシュではCSRFトークンを引き継ぎ、並行中のリクエストを失敗させない
+	assert.Equal(t, login.CSRFToken, cookies[constants.CSRFTokenCookie].Value)
+
+	// ログインでは第三者に仕込まれたCSRFトークンのCookieを引き継がず、必ず作り直す
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/auth/login?mode=cookie", bytes.NewBuffer(reqBody))
+	req.AddCookie(&http.Cookie{Name: constants.CSRFTokenCookie, Value: "planted-by-attacker"})
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var relogin dto.CookieLoginResponse
+	json.Unmarshal(w.Body.Bytes(), &relogin)
+	assert.NotEmpty(t, relogin.CSRFToken)
+	assert.NotEqual(t, "planted-by-attacker", relogin.CSRFToken)
+
+	w = request("POST", "/auth/logout", login.CSRFToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, cookie := range w.Result().Cookies() {
+		assert.True(t, cookie.MaxAge < 0)
+	}
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/me/profile", "", nil).Code)
+}
+
+// fakeRedis 失効ストアのテスト用に、必要なコマンドだけを実装したプロセス内のRedisサーバー
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	zsets    map[string]map[string]float64
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeRedis{listener: listener, zsets: make(map[string]map[string]float64)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeRedis) url() string {
+	return "redis://" + s.listener.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		count, _ := strconv.Atoi(strings.TrimSpace(line)[1:])
+		args := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			r.ReadString('\n')
+			arg, _ := r.ReadString('\n')
+			args = append(args, strings.TrimSuffix(arg, "\r\n"))
+		}
+		conn.Write([]byte(s.execute(args)))
+	}
+}
+
+func (s *fakeRedis) execute(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inRange := func(score float64, min string, max string) bool {
+		bound := func(value string) (float64, bool) {
+			exclusive := strings.HasPrefix(value, "(")
+			f, _ := strconv.ParseFloat(strings.TrimPrefix(value, "("), 64)
+			return f, exclusive
+		}
+		low, lowExclusive := bound(min)
+		high, highExclusive := bound(max)
+		return (score > low || !lowExclusive && score == low) && (score < high || !highExclusive && score == high)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "ZADD":
+		if s.zsets[args[1]] == nil {
+			s.zsets[args[1]] = make(map[string]float64)
+		}
+		score, _ := strconv.ParseFloat(args[2], 64)
+		s.zsets[args[1]][args[3]] = score
+		return ":1\r\n"
+	case "ZSCORE":
+		score, ok := s.zsets[args[1]][args[2]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		value := strconv.FormatFloat(score, 'f', -1, 64)
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "ZRANGEBYSCORE":
+		var members []string
+		for member, score := range s.zsets[args[1]] {
+			if inRange(score, args[2], args[3]) {
+				members = append(members, member)
+			}
+		}
+		reply := fmt.Sprintf("*%d\r\n", len(members))
+		for _, member := range members {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(member), member)
+		}
+		return reply
+	case "ZREMRANGEBYSCORE":
+		removed := 0
+		for member, score := range s.zsets[args[1]] {
+			if inRange(score, args[2], args[3]) {
+				delete(s.zsets[args[1]], member)
+				removed++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", removed)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestTokenRevocationStores(t *testing.T) {
+	_, db := setupWithDB()
+	redisServer := newFakeRedis(t)
+	redisStore, err := revocation.NewRedisStore(redisServer.url(), "test:")
+	assert.NoError(t, err)
+
+	stores := map[string]revocation.ITokenRevocationStore{
+		"sql":    revocation.NewBloomFilteredStore(revocation.NewSQLStore(db), time.Minute),
+		"memory": revocation.NewMemoryStore(),
+		"redis":  revocation.NewBloomFilteredStore(redisStore, time.Minute),
+	}
+	now := time.Now().Unix()
+	for name, store := range stores {
+		// フィルタを先に構築しておき、構築後に失効させたトークンも検出できることを確認する
+		revoked, err := store.IsRevoked("unknown")
+		assert.NoError(t, err, name)
+		assert.False(t, revoked, name)
+
+		assert.NoError(t, store.Revoke("revoked-jti", now+60), name)
+		assert.NoError(t, store.Revoke("expired-jti", now-10), name)
+
+		revoked, _ = store.IsRevoked("revoked-jti")
+		assert.True(t, revoked, name)
+		revoked, _ = store.IsRevoked("expired-jti")
+		assert.False(t, revoked, name)
+		revoked, _ = store.IsRevoked("unknown")
+		assert.False(t, revoked, name)
+
+		active, err := store.ListActive()
+		assert.NoError(t, err, name)
+		assert.Equal(t, []string{"revoked-jti"}, active, name)
+		assert.NoError(t, store.DeleteExpired(), name)
+	}
+
+	// 別のインスタンスで失効させたトークンも、フィルタを同期しない設定では即座に拒否される
+	other, err := revocation.NewRedisStore(redisServer.url(), "test:")
+	assert.NoError(t, err)
+	instanceA := revocation.NewBloomFilteredStore(redisStore, 0)
+	instanceB := revocation.NewBloomFilteredStore(other, 0)
+	assert.NoError(t, instanceA.Revoke("logged-out-elsewhere", now+60))
+	revoked, err := instanceB.IsRevoked("logged-out-elsewhere")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// 構築済みのフィルタにも、バックグラウンドの差分の同期で反映される
+	instanceC := revocation.NewBloomFilteredStore(other, 20*time.Millisecond)
+	instanceC.IsRevoked("warmup")
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, instanceA.Revoke("logged-out-later", now+60))
+	assert.Eventually(t, func() bool {
+		revoked, err := instanceC.IsRevoked("logged-out-later")
+		return err == nil && revoked
+	}, time.Second, 10*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		revoked, _ = instanceC.IsRevoked("logged-out-later")
+		assert.True(t, revoked)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 7, 30, 0, time.Local)
+	assert.Equal(t, time.Date(2026, 1, 1, 10, 15, 0, 0, time.Local), scheduler.MustCron("*/15 * * * *").Next(base))
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 30, 0, 0, time.Local), scheduler.MustCron("30 3 * * *").Next(base))
+	// 2026年1月5日は月曜日
+	assert.Equal(t, time.Date(2026, 1, 5, 9, 0, 0, 0, time.Local), scheduler.MustCron("0 9 * * 1").Next(base))
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), scheduler.MustCron("0 0 1 3,9 *").Next(base))
+
+	for _, expr := range []string{"61 * * * *", "* * *", "*/0 * * * *", "5-1 * * * *"} {
+		_, err := scheduler.Cron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestJobSchedulerRunsOnLeaderOnly(t *testing.T) {
+	router, db := setupWithDB()
+	jobRepository := repositories.NewJobRepository(db)
+
+	var mu sync.Mutex
+	runs := 0
+	newScheduler := func() scheduler.IScheduler {
+		s := scheduler.NewScheduler(jobRepository)
+		s.Register(scheduler.Job{
+			Name:     "counting",
+			Schedule: scheduler.Every(time.Hour),
+			Run: func(ctx context.Context) error {
+				mu.Lock()
+				runs++
+				mu.Unlock()
+				return nil
+			},
+		})
+		s.Register(scheduler.Job{
+			Name:     "failing",
+			Schedule: scheduler.Every(time.Hour),
+			Run: func(ctx context.Context) error {
+				return fmt.Errorf("boom")
+			},
+		})
+		return s
+	}
+
+	// 2つのレプリカが同時に動いても、リースを取得した1つだけが実行する
+	leader, follower := newScheduler(), newScheduler()
+	ctx := context.Background()
+	assert.NoError(t, leader.Tick(ctx))
+	assert.NoError(t, follower.Tick(ctx))
+	assert.Eventually(t, func() bool {
+		var finished int64
+		db.Model(&models.JobRun{}).Where("status <> ?", constants.JobStatusRunning).Count(&finished)
+		return finished == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 次の実行時刻までは再実行しない
+	assert.NoError(t, leader.Tick(ctx))
+	var jobRuns []models.JobRun
+	db.Order("job_name").Find(&jobRuns)
+	assert.Equal(t, 2, len(jobRuns))
+	assert.Equal(t, constants.JobStatusSucceeded, jobRuns[0].Status)
+	assert.Equal(t, constants.JobStatusFailed, jobRuns[1].Status)
+	assert.Equal(t, "boom", jobRuns[1].Error)
+	assert.Equal(t, jobRuns[0].Holder, jobRuns[1].Holder)
+	mu.Lock()
+	assert.Equal(t, 1, runs)
+	mu.Unlock()
+
+	// 管理者は定期ジョブの状態と実行履歴を確認できる
+	admin := signupAndLogin(t, router, "jobs-admin@example.com")
+	db.Model(&models.User{}).Where("email = ?", "jobs-admin@example.com").Update("role", "admin")
+	db.Create(&models.JobRun{JobName: "revoked-tokens-cleanup", Holder: "test", StartedAt: time.Now(), Status: constants.JobStatusFailed, Error: "connection refused"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/jobs", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var jobs map[string][]dto.JobResponse
+	json.Unmarshal(w.Body.Bytes(), &jobs)
+	found := false
+	for _, job := range jobs["data"] {
+		if job.Name == "revoked-tokens-cleanup" {
+			found = true
+			assert.Equal(t, "connection refused", job.LastFailure.Error)
+			assert.True(t, job.NextRunAt > time.Now().Unix())
+		}
+	}
+	assert.True(t, found)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/jobs/unknown/runs", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 即時実行もリースを取得したインスタンスだけが行い、終了まで待って結果を返す
+	run, err := leader.RunNow(ctx, "failing")
+	assert.NoError(t, err)
+	assert.Equal(t, constants.JobStatusFailed, run.Status)
+	_, err = follower.RunNow(ctx, "counting")
+	assert.ErrorIs(t, err, scheduler.ErrJobBusy)
+	_, err = leader.RunNow(ctx, "unknown")
+	assert.ErrorIs(t, err, scheduler.ErrJobNotFound)
+
+	runJob := func(name string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/jobs/"+name+"/run", nil)
+		req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusConflict, runJob("revoked-tokens-cleanup").Code)
+	db.Where("name = ?", "scheduler").Delete(&models.JobLease{})
+	w = runJob("revoked-tokens-cleanup")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result map[string]dto.JobRunResponse
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.Equal(t, constants.JobStatusSucceeded, result["data"].Status)
+	assert.NotZero(t, result["data"].FinishedAt)
+	assert.Equal(t, http.StatusNotFound, runJob("unknown").Code)
+	// Lambdaのインスタンスがリースを持ち続けないよう、実行後に解放する
+	var leases int64
+	db.Model(&models.JobLease{}).Count(&leases)
+	assert.Equal(t, int64(0), leases)
+}
+
+func TestConfigLoadValidateAndRedactedDump(t *testing.T) {
+	// 優先順位は 既定値 < YAML < 環境変数
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("port: \"9000\"\nupload:\n  dir: /var/uploads\nrevocation:\n  store: memory\n"), 0o600)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("UPLOAD_DIR", "/tmp/override")
+	t.Setenv("REDIS_URL", "redis://:hunter2@redis:6379/0")
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "9000", cfg.Port)
+	assert.Equal(t, "/tmp/override", cfg.Upload.Dir)
+	assert.Equal(t, "memory", cfg.Revocation.Store)
+	assert.True(t, cfg.Cookie.Secure)
+
+	// 未知のキーや不正な値は起動時にエラーになる
+	os.WriteFile(path, []byte("prot: 9000\n"), 0o600)
+	_, err = config.Load()
+	assert.Error(t, err)
+	os.WriteFile(path, []byte(""), 0o600)
+	t.Setenv("JOBS_ENABLED", "maybe")
+	_, err = config.Load()
+	assert.Error(t, err)
+	t.Setenv("JOBS_ENABLED", "false")
+
+	// 旧HS256トークンを受け付ける期限までは、本番環境でもSECRET_KEYが必須
+	t.Setenv("ENV", config.EnvProd)
+	t.Setenv("SECRET_KEY", "")
+	t.Setenv("LEGACY_HS256_UNTIL", time.Now().Add(time.Hour).Format(time.RFC3339))
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "SECRET_KEY")
+	t.Setenv("LEGACY_HS256_UNTIL", time.Now().Add(-time.Hour).Format(time.RFC3339))
+	_, err = config.Load()
+	assert.NoError(t, err)
+	t.Setenv("LEGACY_HS256_UNTIL", "next week")
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "LEGACY_HS256_UNTIL")
+	t.Setenv("LEGACY_HS256_UNTIL", "")
+	t.Setenv("ENV", "")
+	t.Setenv("SECRET_KEY", "test-secret-key")
+
+	// 管理者向けの設定の出力では秘密情報が伏せられる
+	t.Setenv("ADMIN_BOOTSTRAP_TOKEN", "bootstrap-secret")
+	router, db := setupWithDB()
+	user := signupAndLogin(t, router, "config-user@example.com")
+	admin := signupAndLogin(t, router, "config-admin@example.com")
+	db.Model(&models.User{}).Where("email = ?", "config-admin@example.com").Update("role", "admin")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "test-secret-key")
+	assert.NotContains(t, w.Body.String(), "bootstrap-secret")
+	assert.NotContains(t, w.Body.String(), "hunter2")
+	var res map[string]config.Config
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Equal(t, "[REDACTED]", res["data"].SecretKey)
+	assert.Equal(t, "[REDACTED]", res["data"].Auth.AdminBootstrapToken)
+	assert.Equal(t, "/tmp/override", res["data"].Upload.Dir)
+}
+
+func TestOpenDBSupportsFileSQLiteAndReturnsConnectionErrors(t *testing.T) {
+	// ファイルのSQLiteは接続し直してもデータが残る
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "fleamarket.db"))
+	t.Setenv("DB_MAX_OPEN_CONNS", "4")
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+	db, err := infra.OpenDB(cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.User{}, &models.Item{}))
+	assert.NoError(t, db.Create(&models.User{Email: "file@example.com", Password: "x"}).Error)
+	sqlDB, _ := db.DB()
+	assert.Equal(t, 4, sqlDB.Stats().MaxOpenConnections)
+	sqlDB.Close()
+
+	db, err = infra.OpenDB(cfg)
+	assert.NoError(t, err)
+	var count int64
+	db.Model(&models.User{}).Where("email = ?", "file@example.com").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// 接続できない場合はpanicせず、再試行の後にエラーを返す
+	t.Setenv("DB_DRIVER", "postgres")
+	t.Setenv("DB_HOST", "127.0.0.1")
+	t.Setenv("DB_USER", "fleamarket")
+	t.Setenv("DB_NAME", "fleamarket")
+	t.Setenv("DB_PORT", "1")
+	t.Setenv("DB_CONNECT_ATTEMPTS", "2")
+	cfg, err = config.Load()
+	assert.NoError(t, err)
+	_, err = infra.OpenDB(cfg)
+	assert.ErrorContains(t, err, "after 2 attempts")
+
+	t.Setenv("DB_STATEMENT_TIMEOUT", "soon")
+	_, err = config.Load()
+	assert.Error(t, err)
+	t.Setenv("DB_STATEMENT_TIMEOUT", "")
+	t.Setenv("DB_DRIVER", "mysql")
+	_, err = config.Load()
+	assert.Error(t, err)
+}
+
+func TestVersionedMigrations(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	migrations, err := migrate.LoadSQL(os.DirFS("migrate/sql"), "sqlite")
+	assert.NoError(t, err)
+	migrations = append(migrations, migrate.Migration{
+		Version: 20990101000000,
+		Name:    "add_items_condition",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE items ADD COLUMN condition text NOT NULL DEFAULT 'used'").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE items DROP COLUMN condition").Error
+		},
+	})
+	migrator := migrate.NewWithMigrations(db, migrations)
+
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), len(applied))
+	assert.True(t, db.Migrator().HasColumn("items", "condition"))
+	applied, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(applied))
+
+	// 新しい順にロールバックし、もう一度適用できる
+	reverted, err := migrator.Down(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "add_items_condition", reverted[0].Name)
+	assert.False(t, db.Migrator().HasColumn("items", "condition"))
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.Nil(t, statuses[len(statuses)-1].AppliedAt)
+	_, err = migrator.Down(len(migrations) - 1)
+	assert.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("users"))
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable("users"))
+
+	// AutoMigrateで作成された既存のスキーマは基準のマイグレーションを適用済みとして記録し、データを残す
+	legacy, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	legacy.AutoMigrate(&models.User{}, &models.Item{}, &models.UserIdentity{}, &models.OIDCLoginState{}, &models.SigningKey{}, &models.RefreshSession{}, &models.APIKey{}, &models.Role{}, &models.Permission{}, &models.AuditLog{}, &models.RevokedToken{}, &models.JobLease{}, &models.JobRun{})
+	legacy.Create(&models.User{Email: "legacy@example.com", Password: "x"})
+	applied, err = migrate.NewWithMigrations(legacy, migrations).Up()
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations)-1, len(applied))
+	assert.Equal(t, "add_items_condition", applied[len(applied)-1].Name)
+	var count int64
+	legacy.Model(&models.User{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// createは方言ごとにup/downのファイルを作成する
+	dir := t.TempDir()
+	files, err := migrate.Create(dir, "add_items_condition", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(files))
+	assert.FileExists(t, filepath.Join(dir, "postgres", "20261018120000_add_items_condition.up.sql"))
+	_, err = migrate.Create(dir, "Add items!", time.Now())
+	assert.ErrorIs(t, err, migrate.ErrInvalidName)
+
+	// 埋め込まれたマイグレーションは方言ごとに同じバージョンが揃っている
+	for _, dialect := range migrate.Dialects {
+		loaded, err := migrate.LoadSQL(os.DirFS("migrate/sql"), dialect)
+		assert.NoError(t, err)
+		assert.Equal(t, len(migrations)-1, len(loaded))
+	}
+}
+
+func TestSeedFixturesAndDemoProfileAreIdempotent(t *testing.T) {
+	router, db := setupWithDB()
+	seeder := fixtures.NewSeeder(db, bcrypt.MinCost)
+
+	// 投入したユーザーはハッシュ化されたパスワードでログインできる
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(dto.LoginInput{Email: "test1@example.com", Password: "password123"})
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(reqBody))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 同じフィクスチャを再投入しても重複しない
+	fixture, err := fixtures.LoadFile("testdata/fixtures.yaml")
+	assert.NoError(t, err)
+	result, err := seeder.Apply(fixture)
+	assert.NoError(t, err)
+	assert.Equal(t, fixtures.Result{}, result)
+
+	// JSONも読み込め、未対応のキー（注文など）や不正な値はエラーになる
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "fixture.json")
+	os.WriteFile(jsonPath, []byte(`{"users":[{"email":"json@example.com","password":"password123","role":"moderator"}],"items":[{"seller":"json@example.com","name":"JSONの商品","price":500}]}`), 0o600)
+	fixture, err = fixtures.LoadFile(jsonPath)
+	assert.NoError(t, err)
+	result, err = seeder.Apply(fixture)
+	assert.NoError(t, err)
+	assert.Equal(t, fixtures.Result{UsersCreated: 1, ItemsCreated: 1}, result)
+	os.WriteFile(jsonPath, []byte(`{"orders":[]}`), 0o600)
+	_, err = fixtures.LoadFile(jsonPath)
+	assert.Error(t, err)
+	_, err = seeder.Apply(&fixtures.Fixture{Items: []fixtures.ItemFixture{{Seller: "nobody@example.com", Name: "x", Price: 100}}})
+	assert.Error(t, err)
+
+	// デモは出品者ごとの差分だけを追加する
+	result, err = seeder.Demo(fixtures.DemoOptions{Items: 300, Sellers: 7, Seed: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, fixtures.Result{UsersCreated: 7, ItemsCreated: 300}, result)
+	result, err = seeder.Demo(fixtures.DemoOptions{Items: 300, Sellers: 7, Seed: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, fixtures.Result{}, result)
+	result, err = seeder.Demo(fixtures.DemoOptions{Items: 350, Sellers: 7, Seed: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 50, result.ItemsCreated)
+
+	var demoItems int64
+	db.Model(&models.Item{}).Joins("JOIN users ON users.id = items.user_id").Where("users.email LIKE ?", "demo-seller-%").Count(&demoItems)
+	assert.Equal(t, int64(350), demoItems)
+
+	// 開発用のフィクスチャが壊れていない
+	_, err = fixtures.LoadFile("fixtures/dev.yaml")
+	assert.NoError(t, err)
+}
+
+func TestSetupRouterReturnsError(t *testing.T) {
+	_, db := setupWithDB()
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+	cfg.Revocation.Store = "unknown"
+
+	_, err = setupRouter(db, cfg)
+	assert.ErrorContains(t, err, "TOKEN_REVOCATION_STORE")
+
+	// Lambdaではプロセスを止めずに503を返す
+	ready := make(chan struct{})
+	close(ready)
+	lambdaRouter := setupLambdaRouter(cfg, ready, func() *gorm.DB { return db })
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/items", nil)
+	lambdaRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	router, db := setupWithDB()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/livez", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ready dto.HealthResponse
+	json.Unmarshal(w.Body.Bytes(), &ready)
+	assert.Equal(t, constants.HealthStatusOK, ready.Status)
+	assert.Equal(t, constants.HealthStatusOK, ready.Checks["database"].Status)
+	assert.Equal(t, constants.HealthStatusOK, ready.Checks["token_store"].Status)
+	assert.Equal(t, constants.HealthStatusOK, ready.Checks["migrations"].Status)
+	assert.Equal(t, constants.HealthStatusDisabled, ready.Checks["jobs"].Status)
+
+	// 詳細は管理者のみ
+	user := signupAndLogin(t, router, "health-user@example.com")
+	admin := signupAndLogin(t, router, "health-admin@example.com")
+	db.Model(&models.User{}).Where("email = ?", "health-admin@example.com").Update("role", "admin")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/debug/health", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/debug/health", nil)
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/debug/health", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var detail map[string]dto.HealthDetailResponse
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	assert.Empty(t, detail["data"].Migrations.Pending)
+	assert.True(t, detail["data"].Migrations.Applied > 0)
+	assert.NotEmpty(t, detail["data"].GoVersion)
+
+	// 定期ジョブが有効なのにスケジューラーが動いていなければ、準備ができていない
+	migrator, err := migrate.New(db)
+	assert.NoError(t, err)
+	jobScheduler := scheduler.NewScheduler(repositories.NewJobRepository(db))
+	healthService := services.NewHealthService(repositories.NewHealthRepository(db), revocation.NewMemoryStore(), migrator, jobScheduler, true)
+	jobsReady := healthService.Ready(context.Background())
+	assert.Equal(t, constants.HealthStatusUnavailable, jobsReady.Status)
+	assert.Equal(t, constants.HealthStatusUnavailable, jobsReady.Checks["jobs"].Status)
+	assert.Empty(t, jobsReady.Checks["jobs"].Error)
+	assert.NoError(t, jobScheduler.Tick(context.Background()))
+	assert.Equal(t, constants.HealthStatusOK, healthService.Ready(context.Background()).Checks["jobs"].Status)
+
+	// DBに接続できなくなると503になり、エラーの内容は公開しない
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &ready)
+	assert.Equal(t, constants.HealthStatusUnavailable, ready.Checks["database"].Status)
+	assert.NotContains(t, w.Body.String(), "closed")
+}
+
+func TestStructuredLoggingWithRequestIDAndRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, "json"))
+	defer slog.SetDefault(previous)
+	assert.NoError(t, logging.SetLevel("info"))
+	defer logging.SetLevel("info")
+
+	logLines := func() []map[string]any {
+		var lines []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var entry map[string]any
+			if json.Unmarshal([]byte(line), &entry) == nil {
+				lines = append(lines, entry)
+			}
+		}
+		return lines
+	}
+
+	// 受け取ったX-Request-IDを応答とログに引き継ぐ
+	router, db := setupWithDB()
+	buf.Reset()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/items", nil)
+	req.Header.Set("X-Request-ID", "req-test-123")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "req-test-123", w.Header().Get("X-Request-ID"))
+	lines := logLines()
+	assert.NotEmpty(t, lines)
+	assert.Equal(t, "request", lines[len(lines)-1]["msg"])
+	assert.Equal(t, "req-test-123", lines[len(lines)-1]["request_id"])
+	assert.Equal(t, "/items", lines[len(lines)-1]["route"])
+
+	// 不正な値は使わずに生成する
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items", nil)
+	req.Header.Set("X-Request-ID", "bad id\nforged")
+	router.ServeHTTP(w, req)
+	assert.Regexp(t, "^[0-9a-f]{32}$", w.Header().Get("X-Request-ID"))
+
+	// ハンドラー内のログにもリクエストIDが付き、メールアドレスは伏せられる
+	buf.Reset()
+	ctx := logging.WithRequestID(context.Background(), "req-test-456")
+	slog.InfoContext(ctx, "login for someone@example.com failed", "email", "someone@example.com", "error", fmt.Errorf("user other@example.com not found"), "user_id", 7)
+	log.Printf("legacy log for legacy@example.com")
+	lines = logLines()
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "req-test-456", lines[0]["request_id"])
+	assert.Equal(t, "[REDACTED]", lines[0]["email"])
+	assert.Equal(t, "user [REDACTED] not found", lines[0]["error"])
+	assert.Equal(t, float64(7), lines[0]["user_id"])
+	assert.NotContains(t, buf.String(), "example.com")
+
+	// debugは既定では出力されず、管理者が実行中に有効にできる
+	buf.Reset()
+	slog.Debug("hidden")
+	assert.Empty(t, buf.String())
+
+	user := signupAndLogin(t, router, "log-user@example.com")
+	admin := signupAndLogin(t, router, "log-admin@example.com")
+	db.Model(&models.User{}).Where("email = ?", "log-admin@example.com").Update("role", "admin")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level":"verbose"}`))
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level":"DEBUG"}`))
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, slog.LevelDebug, logging.Level())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/log-level", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	router.ServeHTTP(w, req)
+	var res map[string]dto.LogLevelResponse
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Equal(t, "debug", res["data"].Level)
+
+	buf.Reset()
+	slog.Debug("visible")
+	assert.Contains(t, buf.String(), "visible")
+}
+
+func scrapeMetrics(t *testing.T, router *gin.Engine, token string) string {
+	w := doRawRequest(t, router, "GET", "/metrics", token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	return w.Body.String()
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "scrape-token")
+	router, db := setupWithDB()
+
+	itemRequests := metrics.HTTPRequests.Value("GET", "/items/:id", "200")
+	unmatched := metrics.HTTPRequests.Value("GET", "unmatched", "404")
+	lookups := metrics.TokenRevocationLookups.Value("sql")
+	loginSuccesses := metrics.Logins.Value("password", "success")
+	loginFailures := metrics.Logins.Value("password", "failure")
+	dbQueries := metrics.DBQueryDuration.Count("query")
+
+	for _, path := range []string{"/items/1", "/items/1", "/no-such-route"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+	}
+	signupAndLogin(t, router, "metrics-user@example.com")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/login", strings.NewReader(`{"email":"metrics-unknown@example.com","password":"password123"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	user := signupAndLogin(t, router, "metrics-user2@example.com")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	router.ServeHTTP(w, req)
+
+	// ルートはIDを含まないテンプレートで集計する
+	assert.Equal(t, itemRequests+2, metrics.HTTPRequests.Value("GET", "/items/:id", "200"))
+	assert.Equal(t, unmatched+1, metrics.HTTPRequests.Value("GET", "unmatched", "404"))
+	assert.Equal(t, loginSuccesses+2, metrics.Logins.Value("password", "success"))
+	assert.Equal(t, loginFailures+1, metrics.Logins.Value("password", "failure"))
+	assert.Equal(t, lookups+1, metrics.TokenRevocationLookups.Value("sql"))
+	assert.Greater(t, metrics.DBQueryDuration.Count("query"), dbQueries)
+
+	// METRICS_TOKENを設定した場合はトークンが必要
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	body := scrapeMetrics(t, router, "scrape-token")
+	assert.Contains(t, body, "# TYPE http_request_duration_seconds histogram")
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/items/:id",status="200",le="+Inf"}`)
+	assert.NotContains(t, body, `route="/items/1"`)
+	assert.Contains(t, body, `db_query_duration_seconds_count{operation="query"}`)
+	assert.Contains(t, body, "db_pool_open_connections ")
+	assert.Contains(t, body, `token_revocation_hits_total`)
+	var activeListings int64
+	db.Model(&models.Item{}).Where("sold_out = ?", false).Count(&activeListings)
+	assert.Contains(t, body, fmt.Sprintf("fleamarket_active_listings %d\n", activeListings))
+
+	// Lambdaでは、DBの接続前にラッパーが応答したリクエストはラッパーで、転送したリクエストは転送先で1回だけ記録する
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+	ready := make(chan struct{})
+	lambdaRouter := setupLambdaRouter(cfg, ready, func() *gorm.DB { return db })
+	notReady := metrics.HTTPRequests.Value("GET", "/readyz", "503")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	lambdaRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, notReady+1, metrics.HTTPRequests.Value("GET", "/readyz", "503"))
+	assert.Contains(t, scrapeMetrics(t, lambdaRouter, "scrape-token"), "http_requests_total")
+
+	close(ready)
+	itemRequests = metrics.HTTPRequests.Value("GET", "/items/:id", "200")
+	unmatched = metrics.HTTPRequests.Value("GET", "unmatched", "200")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items/1", nil)
+	lambdaRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, itemRequests+1, metrics.HTTPRequests.Value("GET", "/items/:id", "200"))
+	assert.Equal(t, unmatched, metrics.HTTPRequests.Value("GET", "unmatched", "200"))
+}
+
+// recordingExporter テスト用に、エクスポートされたスパンを保持する
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingExporter) Export(ctx context.Context, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// take それまでに終了したスパンをすべて取り出す
+func (e *recordingExporter) take(t *testing.T, tracer *tracing.Tracer) map[string]tracing.SpanData {
+	assert.NoError(t, tracer.ForceFlush(context.Background()))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make(map[string]tracing.SpanData)
+	for _, span := range e.spans {
+		spans[span.Name] = span
+	}
+	e.spans = nil
+	return spans
+}
+
+func spanAttribute(span tracing.SpanData, key string) any {
+	for _, attribute := range span.Attributes {
+		if attribute.Key == key {
+			return attribute.Value
+		}
+	}
+	return nil
+}
+
+func TestTracingPropagatesTraceContext(t *testing.T) {
+	router, _ := setupWithDB()
+	exporter := &recordingExporter{}
+	tracer := tracing.NewTracer(exporter, 1)
+	tracing.SetTracer(tracer)
+	t.Cleanup(func() {
+		tracing.SetTracer(nil)
+		tracer.Shutdown(context.Background())
+	})
+
+	// 呼び出し元のトレースを引き継ぎ、HTTP → サービス → リポジトリ → クエリの順に親子関係を持つ
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/items", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "vendor=abc")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := exporter.take(t, tracer)
+	server, ok := spans["GET /items"]
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentSpanID.String())
+	assert.Equal(t, "vendor=abc", server.SpanContext.TraceState)
+	assert.Equal(t, tracing.SpanKindServer, server.Kind)
+	assert.Equal(t, "/items", spanAttribute(server, "http.route"))
+	assert.Equal(t, int64(http.StatusOK), spanAttribute(server, "http.response.status_code"))
+	service := spans["ItemService.FindAll"]
+	assert.Equal(t, server.SpanContext.SpanID, service.ParentSpanID)
+	repository := spans["ItemRepository.FindAll"]
+	assert.Equal(t, service.SpanContext.SpanID, repository.ParentSpanID)
+	query := spans["db SELECT"]
+	assert.Equal(t, repository.SpanContext.SpanID, query.ParentSpanID)
+	assert.Equal(t, server.SpanContext.TraceID, query.SpanContext.TraceID)
+	assert.Equal(t, "items", spanAttribute(query, "db.sql.table"))
+	assert.Contains(t, spanAttribute(query, "db.statement"), "SELECT")
+
+	// 認証ではトークンの失効確認がスパンになる
+	user := signupAndLogin(t, router, "tracing-user@example.com")
+	exporter.take(t, tracer)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items/1", nil)
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	spans = exporter.take(t, tracer)
+	authSpan := spans["AuthService.GetUserFromToken"]
+	assert.Equal(t, spans["GET /items/:id"].SpanContext.SpanID, authSpan.ParentSpanID)
+	assert.Equal(t, authSpan.SpanContext.SpanID, spans["TokenRevocationStore.IsRevoked"].ParentSpanID)
+	assert.Equal(t, false, spanAttribute(spans["TokenRevocationStore.IsRevoked"], "token.revoked"))
+
+	// 形式が正しくないtraceparentは無視し、新しいトレースを開始する
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items", nil)
+	req.Header.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	router.ServeHTTP(w, req)
+	server = exporter.take(t, tracer)["GET /items"]
+	assert.False(t, server.ParentSpanID.IsValid())
+	assert.NotEqual(t, "00000000000000000000000000000000", server.SpanContext.TraceID.String())
+
+	// 呼び出し元がサンプリングしないと決めたトレースは記録しない
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/items", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	router.ServeHTTP(w, req)
+	assert.Empty(t, exporter.take(t, tracer))
+
+	// 外部へのHTTPリクエストにはクライアントのスパンを作り、traceparentを付ける
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer upstream.Close()
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	outbound, _ := http.NewRequestWithContext(ctx, "POST", upstream.URL+"/hooks?secret=x", nil)
+	res, err := (&http.Client{Transport: tracing.NewTransport(nil)}).Do(outbound)
+	assert.NoError(t, err)
+	res.Body.Close()
+	parent.End()
+	spans = exporter.take(t, tracer)
+	client := spans["HTTP POST"]
+	assert.Equal(t, tracing.SpanKindClient, client.Kind)
+	assert.Equal(t, parent.SpanContext().SpanID, client.ParentSpanID)
+	assert.Equal(t, tracing.FormatTraceparent(client.SpanContext), received.Get("traceparent"))
+	assert.Equal(t, "/hooks", spanAttribute(client, "url.path"))
+	assert.Equal(t, int64(http.StatusAccepted), spanAttribute(client, "http.response.status_code"))
+}
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	router, _ := setupWithDB()
+	spec := openapi.Spec()
+
+	// ルートを追加してドキュメントに記載し忘れた場合はここで失敗する
+	var missing []string
+	for _, route := range router.Routes() {
+		if !spec.HasOperation(route.Method, route.Path) {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	assert.Empty(t, missing, "routes without an OpenAPI entry; add them to openapi.Routes")
+	// 削除したルートがドキュメントに残っていないこと
+	assert.Equal(t, len(router.Routes()), len(spec.Operations()))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas         map[string]map[string]any `json:"schemas"`
+			SecuritySchemes map[string]map[string]any `json:"securitySchemes"`
+			Responses       map[string]any            `json:"responses"`
+		} `json:"components"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, "bearer", doc.Components.SecuritySchemes["bearerAuth"]["scheme"])
+	assert.Contains(t, doc.Components.Responses, "NotFound")
+
+	// スキーマはdtoの型から作り、bindingタグの制約を反映する
+	createItem := doc.Components.Schemas["CreateItemInput"]
+	assert.ElementsMatch(t, []any{"name", "price"}, createItem["required"])
+	price := createItem["properties"].(map[string]any)["price"].(map[string]any)
+	assert.Equal(t, float64(1), price["minimum"])
+	assert.Equal(t, float64(999999), price["maximum"])
+	assert.Contains(t, doc.Components.Schemas["Item"]["properties"], "ID")
+
+	getItem := doc.Paths["/items/{id}"]["get"]
+	assert.Contains(t, getItem["responses"], "200")
+	assert.Contains(t, getItem["responses"], "404")
+	adminUsers := doc.Paths["/admin/users"]["get"]
+	assert.Contains(t, adminUsers["responses"], "403")
+	assert.Contains(t, adminUsers["description"], constants.PermUserManage)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/docs", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`)
+}
+
+func TestProblemDetailsErrors(t *testing.T) {
+	router, db := setupWithDB()
+	token, err := services.CreateAccessToken(testKeyRing(t, db), 1, "test1@example.com", "user", "")
+	assert.NoError(t, err)
+
+	request := func(method string, path string, token string, body string) (*httptest.ResponseRecorder, dto.Problem) {
+		w := doRawRequest(t, router, method, path, token, strings.NewReader(body))
+		var problem dto.Problem
+		json.Unmarshal(w.Body.Bytes(), &problem)
+		return w, problem
+	}
+
+	// 検証エラーはフィールドごとにJSONのキー名と検証ルールを返す
+	w, problem := request("POST", "/items", *token, `{"name": "", "price": 0}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, middlewares.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "invalid_input", problem.Code)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.ElementsMatch(t, []apperrors.FieldError{
+		{Field: "name", Code: "required", Message: "is required"},
+		{Field: "price", Code: "required", Message: "is required"},
+	}, problem.Errors)
+
+	w, problem = request("POST", "/items", *token, `{"name": "テスト", "price": "1000"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []apperrors.FieldError{{Field: "price", Code: "type", Message: "must be an integer"}}, problem.Errors)
+
+	w, problem = request("POST", "/items", *token, `{"name": `)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "malformed_body", problem.Code)
+
+	// ドメインのエラーは安定したコードで判別でき、従来のerrorメンバーも返す
+	w, problem = request("GET", "/items/999", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "item_not_found", problem.Code)
+	assert.Equal(t, constants.ErrItemNotFound, problem.Detail)
+	assert.Equal(t, constants.ErrItemNotFound, problem.Error)
+	assert.Equal(t, "/items/999", problem.Instance)
+	assert.Equal(t, w.Header().Get(constants.RequestIDHeader), problem.RequestID)
+
+	// ボディのなかった認証・認可のエラーもProblem Detailsで返す
+	w, problem = request("POST", "/items", "", `{"name": "テスト", "price": 1000}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, middlewares.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "unauthorized", problem.Code)
+	w, problem = request("GET", "/items", "invalid-token", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "invalid_token", problem.Code)
+	w, problem = request("GET", "/admin/users", *token, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "forbidden", problem.Code)
+
+	// パスワードの誤りと未登録のメールアドレスは区別しない
+	w, problem = request("POST", "/auth/login", "", `{"email": "test1@example.com", "password": "wrong-password"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "invalid_credentials", problem.Code)
+	w, problem = request("POST", "/auth/login", "", `{"email": "nobody@example.com", "password": "wrong-password"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "invalid_credentials", problem.Code)
+
+	signupAndLogin(t, router, "problem@example.com")
+	w, problem = request("POST", "/auth/signup", "", `{"email": "problem@example.com", "password": "password123"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "email_already_exists", problem.Code)
+
+	w, problem = request("GET", "/no-such-path", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "not_found", problem.Code)
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"runtime"
+	"time"
+)
+
+// dbBuckets SQL 1文の実行時間（秒）のバケット。HTTPより短い時間を細かく分ける
+var dbBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// アプリケーションのメトリクス。ラベルの値は種類が限られるもの（ルートのテンプレートなど）だけにする
+var (
+	// HTTPRequests routeは/items/:idのようなテンプレート。どのルートにも一致しない場合は"unmatched"
+	HTTPRequests        = NewCounterVec("http_requests_total", "Number of HTTP requests.", "method", "route", "status")
+	HTTPRequestDuration = NewHistogramVec("http_request_duration_seconds", "HTTP request latency in seconds.", DefaultBuckets, "method", "route", "status")
+
+	// DBQueryDuration operationはGORMのコールバックの種類（create / query / update / delete / row / raw）
+	DBQueryDuration = NewHistogramVec("db_query_duration_seconds", "GORM query duration in seconds.", dbBuckets, "operation")
+	// DBQueryErrors レコードが見つからない場合（gorm.ErrRecordNotFound）は含めない
+	DBQueryErrors = NewCounterVec("db_query_errors_total", "Number of failed GORM queries.", "operation")
+
+	TokenRevocationLookups = NewCounterVec("token_revocation_lookups_total", "Number of token revocation lookups.", "store")
+	TokenRevocationHits    = NewCounterVec("token_revocation_hits_total", "Number of lookups that found a revoked token.", "store")
+
+	// Logins methodはpassword / oidc、resultはsuccess / failure
+	Logins = NewCounterVec("auth_logins_total", "Number of login attempts.", "method", "result")
+)
+
+// startedAt process_start_time_secondsとして出力する
+var startedAt = time.Now()
+
+func init() {
+	RegisterGaugeFunc("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", nil, func() []Sample {
+		return []Sample{{Value: float64(startedAt.Unix())}}
+	})
+	RegisterGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", nil, func() []Sample {
+		return []Sample{{Value: float64(runtime.NumGoroutine())}}
+	})
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startedAtKey = "metrics:started_at"
+
+// GormPlugin すべてのクエリの実行時間とエラーを操作の種類ごとに記録する
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+// Initialize 他のコールバックより前後に登録し、フック（BeforeCreateなど）の時間も含めて計測する
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("metrics:before_create", before),
+		callbacks.Create().After("*").Register("metrics:after_create", after("create")),
+		callbacks.Query().Before("*").Register("metrics:before_query", before),
+		callbacks.Query().After("*").Register("metrics:after_query", after("query")),
+		callbacks.Update().Before("*").Register("metrics:before_update", before),
+		callbacks.Update().After("*").Register("metrics:after_update", after("update")),
+		callbacks.Delete().Before("*").Register("metrics:before_delete", before),
+		callbacks.Delete().After("*").Register("metrics:after_delete", after("delete")),
+		callbacks.Row().Before("*").Register("metrics:before_row", before),
+		callbacks.Row().After("*").Register("metrics:after_row", after("row")),
+		callbacks.Raw().Before("*").Register("metrics:before_raw", before),
+		callbacks.Raw().After("*").Register("metrics:after_raw", after("raw")),
+	)
+}
+
+func before(db *gorm.DB) {
+	db.InstanceSet(startedAtKey, time.Now())
+}
+
+func after(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+		DBQueryDuration.Observe(time.Since(value.(time.Time)).Seconds(), operation)
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			DBQueryErrors.Inc(operation)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType Prometheusのテキスト形式（exposition format 0.0.4）
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets HTTPリクエストの処理時間（秒）のバケット
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample RegisterGaugeFuncなどのcollectが収集時に返す1系列分の値。Labelsは登録時のラベル名と同じ順番で並べる
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// collector 1つのメトリクス（同じ名前の系列の集まり）
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry 登録された順番にメトリクスを出力する
+type Registry struct {
+	mu         sync.RWMutex
+	names      []string
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default アプリケーション全体で使うレジストリ。/metricsはこの内容を出力する
+var Default = NewRegistry()
+
+// register 同じ名前で登録し直した場合は置き換える（テストなどでルーターを作り直す場合に、古いDBを参照し続けないため）
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.collectors[name] = c
+}
+
+// WriteText すべてのメトリクスをテキスト形式で書き出す
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	collectors := make([]collector, 0, len(r.names))
+	for _, name := range r.names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// desc メトリクスの名前・説明・ラベル名
+type desc struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+}
+
+// labelKey ラベルの値の組をmapのキーにする
+func (d desc) labelKey(values []string) string {
+	if len(values) != len(d.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labelNames), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// series 1系列分のラベルの値（出力時にキーから復元しないよう保持する）
+type series struct {
+	labels []string
+}
+
+func formatLabels(names []string, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, escapeLabel(values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extra[i], escapeLabel(extra[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys 出力の順番を安定させる
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec 増加のみする値
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	series
+	value float64
+}
+
+// NewCounterVec Defaultに登録する
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, kind: "counter", labelNames: labelNames}, values: make(map[string]*counterSeries)}
+	Default.register(name, c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	key := c.labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &counterSeries{series: series{labels: append([]string(nil), labelValues...)}}
+		c.values[key] = s
+	}
+	s.value += delta
+}
+
+// Value テスト用に現在の値を返す
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labelNames, s.labels), formatFloat(s.value))
+	}
+}
+
+// HistogramVec 観測値をバケットごとに数える
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	series
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec Defaultに登録する。bucketsは昇順で、+Infは自動で追加される
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labelNames: labelNames},
+		buckets: buckets,
+		values:  make(map[string]*histogramSeries),
+	}
+	Default.register(name, h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{series: series{labels: append([]string(nil), labelValues...)}, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count テスト用に観測回数を返す
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	key := h.labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, s.labels, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labelNames, s.labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labelNames, s.labels), s.count)
+	}
+}
+
+// funcCollector 出力のたびにcollectを呼んで値を求める（DBのコネクションプールや件数など、その時点の値）
+type funcCollector struct {
+	desc
+	collect func() []Sample
+}
+
+// RegisterGaugeFunc Defaultに登録する。同じ名前で登録し直すと置き換わる
+func RegisterGaugeFunc(name string, help string, labelNames []string, collect func() []Sample) {
+	Default.register(name, &funcCollector{desc: desc{name: name, help: help, kind: "gauge", labelNames: labelNames}, collect: collect})
+}
+
+// RegisterCounterFunc 累計値を他から読み取る場合（sql.DBStatsの待ち回数など）に使う
+func RegisterCounterFunc(name string, help string, labelNames []string, collect func() []Sample) {
+	Default.register(name, &funcCollector{desc: desc{name: name, help: help, kind: "counter", labelNames: labelNames}, collect: collect})
+}
+
+func (f *funcCollector) write(w *bufio.Writer) {
+	samples := f.collect()
+	f.writeHeader(w)
+	for _, sample := range samples {
+		if len(sample.Labels) != len(f.labelNames) {
+			continue
+		}
+		fmt.Fprintf(w, "%s%s %s\n", f.name, formatLabels(f.labelNames, sample.Labels), formatFloat(sample.Value))
+	}
+}
@@ -1,6 +1,8 @@
 package middlewares
 
 import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
 	"gin-fleamarket/services"
 	"net/http"
 	"strings"
@@ -8,28 +10,132 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(authService services.IAuthService) gin.HandlerFunc {
+// AuthMiddleware Bearerトークン（JWT）で認証する
+// Authorizationヘッダーがない場合はaccess_token Cookieのトークンを使い、状態変更リクエストにはCSRFトークンを要求する
+// apiKeyServiceを渡したルートでは Authorization: ApiKey <key> も受け付け、
+// 付与されたスコープをctxの"apiKeyScopes"に設定する（RequireScopeで検査する）
+func AuthMiddleware(authService services.IAuthService, apiKeyService services.IAPIKeyService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		header := ctx.GetHeader("Authorization")
-		if header == "" {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+		if err := authenticate(ctx, authService, apiKeyService); err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if !csrfValid(ctx) {
+			abortWithError(ctx, apperrors.ErrInvalidCSRFToken)
+			return
+		}
+		if !impersonationAllows(ctx) {
+			abortWithError(ctx, apperrors.ErrImpersonationReadOnly)
 			return
 		}
 
-		if !strings.HasPrefix(header, "Bearer ") {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+		ctx.Next()
+	}
+}
+
+// OptionalAuthMiddleware 認証が任意のルート用。Authorizationヘッダーもaccess_token Cookieもなければ未ログインとして通し、
+// いずれかがある場合はAuthMiddlewareと同じく検証する
+func OptionalAuthMiddleware(authService services.IAuthService, apiKeyService services.IAPIKeyService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if bearerToken(ctx) != "" || ctx.GetHeader("Authorization") != "" {
+			if err := authenticate(ctx, authService, apiKeyService); err != nil {
+				abortWithError(ctx, err)
+				return
+			}
+			if !csrfValid(ctx) {
+				abortWithError(ctx, apperrors.ErrInvalidCSRFToken)
+				return
+			}
+		}
+		if !impersonationAllows(ctx) {
+			abortWithError(ctx, apperrors.ErrImpersonationReadOnly)
 			return
 		}
 
-		tokenStrings := strings.TrimPrefix(header, "Bearer ")
-		user, err := authService.GetUserFromToken(tokenStrings)
+		ctx.Next()
+	}
+}
+
+// authenticate 認証に失敗した理由（トークンの期限切れ、アカウントの停止など）はログにだけ出力し、クライアントには401のみを返す
+func authenticate(ctx *gin.Context, authService services.IAuthService, apiKeyService services.IAPIKeyService) error {
+	header := ctx.GetHeader("Authorization")
+	if strings.HasPrefix(header, "ApiKey ") {
+		if apiKeyService == nil {
+			return apperrors.ErrInvalidToken
+		}
+
+		user, scopes, err := apiKeyService.Authenticate(strings.TrimPrefix(header, "ApiKey "))
 		if err != nil {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
-			return
+			return apperrors.ErrInvalidToken.Wrap(err)
 		}
 
 		ctx.Set("user", user)
+		ctx.Set("apiKeyScopes", scopes)
+		return nil
+	}
+
+	tokenString := bearerToken(ctx)
+	if tokenString == "" {
+		if header != "" {
+			return apperrors.ErrInvalidToken
+		}
+		return apperrors.ErrUnauthorized
+	}
+
+	user, impersonation, err := authService.GetUserFromToken(ctx.Request.Context(), tokenString)
+	if err != nil {
+		return apperrors.ErrInvalidToken.Wrap(err)
+	}
+
+	ctx.Set("user", user)
+	if impersonation != nil {
+		ctx.Set("impersonation", impersonation)
+		// フロントエンドがなりすまし中であることを表示できるようにする
+		ctx.Header("X-Impersonated-By", impersonation.ActorEmail)
+	}
+	return nil
+}
 
+// bearerToken Authorizationヘッダーのトークン、なければaccess_token Cookieのトークンを返す
+// ヘッダーがBearer以外の形式の場合はCookieにフォールバックせずに空文字を返す
+func bearerToken(ctx *gin.Context) string {
+	header := ctx.GetHeader("Authorization")
+	if header != "" {
+		if !strings.HasPrefix(header, "Bearer ") {
+			return ""
+		}
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+
+	token, err := ctx.Cookie(constants.AccessTokenCookie)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// ForbidImpersonation パスワード・認証情報・セッション・個人データなど、本人だけが操作すべきルートに付ける
+// なりすましトークンでは書き込みモードでも403を返す。AuthMiddlewareの後に置くこと
+func ForbidImpersonation() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, impersonated := ctx.Get("impersonation"); impersonated {
+			abortWithError(ctx, apperrors.ErrForbidden)
+			return
+		}
 		ctx.Next()
 	}
 }
+
+// impersonationAllows 閲覧のみのなりすましトークンでは参照系のメソッドだけを許可する
+func impersonationAllows(ctx *gin.Context) bool {
+	value, exists := ctx.Get("impersonation")
+	if !exists || !value.(*services.Impersonation).ReadOnly {
+		return true
+	}
+
+	switch ctx.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFProtection Cookieで認証される状態変更リクエストにダブルサブミットのCSRFトークンを要求する
+// X-CSRF-Tokenヘッダーの値がcsrf_token Cookieと一致しない場合は403を返す
+// Authorizationヘッダーで認証するクライアント（モバイルアプリなど）は対象外
+func CSRFProtection() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !csrfValid(ctx) {
+			abortWithError(ctx, apperrors.ErrInvalidCSRFToken)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func csrfValid(ctx *gin.Context) bool {
+	switch ctx.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	// Authorizationヘッダーはブラウザが自動で付与しないため、CSRFの対象にならない
+	if ctx.GetHeader("Authorization") != "" || !hasSessionCookie(ctx) {
+		return true
+	}
+
+	cookie, err := ctx.Cookie(constants.CSRFTokenCookie)
+	if err != nil || cookie == "" {
+		return false
+	}
+	header := ctx.GetHeader(constants.CSRFTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) == 1
+}
+
+func hasSessionCookie(ctx *gin.Context) bool {
+	for _, name := range []string{constants.AccessTokenCookie, constants.RefreshTokenCookie} {
+		if value, err := ctx.Cookie(name); err == nil && value != "" {
+			return true
+		}
+	}
+	return false
+}
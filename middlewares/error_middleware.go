@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/logging"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ProblemContentType エラーレスポンスのContent-Type（RFC 7807）
+const ProblemContentType = "application/problem+json"
+
+var registerFieldNamesOnce sync.Once
+
+// ErrorHandler ハンドラやミドルウェアがctx.Errorで登録したエラーをapplication/problem+jsonで返す
+// 応答を書き込むのはハンドラの実行後のため、ステータスを記録するミドルウェア（AccessLogなど）より後に登録する
+func ErrorHandler() gin.HandlerFunc {
+	registerFieldNamesOnce.Do(registerFieldNames)
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+		err := ctx.Errors.Last().Err
+		appErr := apperrors.From(err)
+		if appErr.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(ctx.Request.Context(), "Request failed", "route", ctx.FullPath(), "error", err)
+		} else if appErr.Unwrap() != nil {
+			slog.DebugContext(ctx.Request.Context(), "Request rejected", "route", ctx.FullPath(), "code", appErr.Code, "error", err)
+		}
+		writeProblem(ctx, appErr)
+	}
+}
+
+// writeProblem 原因のエラー（Wrapしたもの）はクライアントに返さない
+func writeProblem(ctx *gin.Context, appErr *apperrors.Error) {
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(appErr.Status, dto.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(appErr.Status),
+		Status:    appErr.Status,
+		Detail:    appErr.Detail,
+		Instance:  ctx.Request.URL.Path,
+		Code:      appErr.Code,
+		RequestID: logging.RequestID(ctx.Request.Context()),
+		Errors:    appErr.Fields,
+		Error:     appErr.Detail,
+	})
+}
+
+// abortWithError 以降のハンドラを実行せずにエラーを返す（応答はErrorHandlerが書き込む）
+func abortWithError(ctx *gin.Context, err error) {
+	ctx.Error(err)
+	ctx.Abort()
+}
+
+// registerFieldNames 検証エラーのフィールド名を構造体のフィールド名ではなくJSONのキー名（クエリの場合はformのキー名）にする
+func registerFieldNames() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"gin-fleamarket/constants"
+	"gin-fleamarket/logging"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordImpersonatedRequests なりすましトークンで行われたリクエストをすべて監査ログに記録する
+// ルーター全体に登録し、認証ミドルウェアが"impersonation"を設定した後（ハンドラの実行後）に記録する
+func RecordImpersonatedRequests(auditService services.IAuditService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		value, exists := ctx.Get("impersonation")
+		if !exists {
+			return
+		}
+		impersonation := value.(*services.Impersonation)
+
+		var targetID uint
+		if user, exists := ctx.Get("user"); exists {
+			targetID = user.(*models.User).ID
+		}
+
+		err := auditService.Record(services.AuditEntry{
+			Action:   constants.AuditImpersonationRequest,
+			ActorID:  impersonation.ActorID,
+			TargetID: targetID,
+			Client: services.ClientInfo{
+				IPAddress: ctx.ClientIP(),
+				UserAgent: ctx.Request.UserAgent(),
+				RequestID: logging.RequestID(ctx.Request.Context()),
+			},
+			Detail: map[string]interface{}{
+				"jti":    impersonation.TokenID,
+				"method": ctx.Request.Method,
+				"path":   ctx.Request.URL.Path,
+				"status": ctx.Writer.Status(),
+			},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx.Request.Context(), "Failed to record impersonated request", "error", err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/logging"
+	"gin-fleamarket/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDPattern ログを汚されないよう、受け取るリクエストIDの文字と長さを制限する
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+// quietPaths ロードバランサーなどが頻繁に呼ぶため、アクセスログをdebugで出力するパス
+var quietPaths = map[string]bool{
+	"/livez":  true,
+	"/readyz": true,
+}
+
+// RequestID X-Request-IDヘッダーを引き継ぎ（なければ生成し）、応答ヘッダーとリクエストのcontextに設定する
+// 以降はslog.InfoContext(ctx.Request.Context(), ...)で出力したログにrequest_idが付く
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Lambdaのラッパーから渡されたリクエストには既に設定されている
+		requestID := logging.RequestID(ctx.Request.Context())
+		if requestID == "" {
+			requestID = ctx.GetHeader(constants.RequestIDHeader)
+			if !requestIDPattern.MatchString(requestID) {
+				requestID = newRequestID()
+			}
+			ctx.Request = ctx.Request.WithContext(logging.WithRequestID(ctx.Request.Context(), requestID))
+		}
+		ctx.Header(constants.RequestIDHeader, requestID)
+
+		ctx.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessLog リクエストごとに1行のアクセスログを出力する（gin.Loggerの代わり）
+// クエリ文字列にはトークンなどが含まれる場合があるため、パスのみを出力する
+func AccessLog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		started := time.Now()
+		ctx.Next()
+
+		level := slog.LevelInfo
+		switch {
+		case ctx.Writer.Status() >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case quietPaths[ctx.Request.URL.Path]:
+			level = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", ctx.Writer.Status()),
+			slog.Float64("latency_ms", float64(time.Since(started).Microseconds())/1000),
+			slog.String("client_ip", ctx.ClientIP()),
+			slog.Int("bytes", ctx.Writer.Size()),
+		}
+		if user, exists := ctx.Get("user"); exists {
+			attrs = append(attrs, slog.Uint64("user_id", uint64(user.(*models.User).ID)))
+		}
+		slog.LogAttrs(ctx.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery パニックをスタックトレースとともにログに出力し、500を返す（gin.Recoveryの代わり）
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered any) {
+		slog.ErrorContext(ctx.Request.Context(), "panic recovered", "error", recovered, "stack", string(debug.Stack()))
+		writeProblem(ctx, apperrors.ErrInternal)
+	})
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/models"
+	"gin-fleamarket/services"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission ユーザーのロールに指定されたすべての権限がある場合のみアクセスを許可するミドルウェア
+// AuthMiddlewareの後に使用することを想定（ctxに"user"が設定されている必要がある）
+func RequirePermission(roleService services.IRoleService, permissions ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, exists := ctx.Get("user")
+		if !exists {
+			abortWithError(ctx, apperrors.ErrUnauthorized)
+			return
+		}
+
+		userModel, ok := user.(*models.User)
+		if !ok {
+			abortWithError(ctx, apperrors.ErrUnauthorized)
+			return
+		}
+
+		// なりすまし中は対象ユーザーの権限ではなく、管理操作そのものを拒否する
+		if _, impersonated := ctx.Get("impersonation"); impersonated {
+			slog.InfoContext(ctx.Request.Context(), "Access denied for impersonated session",
+				"user_id", userModel.ID, "required_permissions", permissions)
+			abortWithError(ctx, apperrors.ErrForbidden)
+			return
+		}
+
+		// RoleBasedAccessControlと同様に、DBから取得した最新のロールで判定する
+		allowed, err := roleService.HasPermissions(userModel.Role, permissions...)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if !allowed {
+			slog.InfoContext(ctx.Request.Context(), "Access denied",
+				"user_id", userModel.ID, "role", userModel.Role, "required_permissions", permissions)
+			abortWithError(ctx, apperrors.ErrForbidden)
+			return
+		}
+
+		ctx.Next()
+	}
+}
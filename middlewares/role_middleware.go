@@ -1,9 +1,9 @@
 package middlewares
 
 import (
+	"gin-fleamarket/apperrors"
 	"gin-fleamarket/models"
-	"log"
-	"net/http"
+	"log/slog"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -15,21 +15,27 @@ func RoleBasedAccessControl(allowedRoles ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		user, exists := ctx.Get("user")
 		if !exists {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			abortWithError(ctx, apperrors.ErrUnauthorized)
 			return
 		}
 
 		userModel, ok := user.(*models.User)
 		if !ok {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			abortWithError(ctx, apperrors.ErrUnauthorized)
+			return
+		}
+
+		// なりすまし中のセッションには管理操作を許可しない
+		if _, impersonated := ctx.Get("impersonation"); impersonated {
+			slog.InfoContext(ctx.Request.Context(), "Access denied for impersonated session", "user_id", userModel.ID)
+			abortWithError(ctx, apperrors.ErrForbidden)
 			return
 		}
 
 		// 重要: トークンのロール情報ではなく、データベースのUSERテーブルのroleカラムを使用する
 		// AuthMiddlewareでGetUserFromTokenが呼ばれ、データベースから最新のユーザー情報が取得されている
-		// デバッグ用ログ
-		log.Printf("RoleBasedAccessControl: User ID=%d, Email=%s, Role=%s (from DB), AllowedRoles=%v",
-			userModel.ID, userModel.Email, userModel.Role, allowedRoles)
+		slog.DebugContext(ctx.Request.Context(), "Checking role",
+			"user_id", userModel.ID, "role", userModel.Role, "allowed_roles", allowedRoles)
 
 		// 許可されたロールかチェック（大文字小文字を無視、空白をトリム）
 		// userModel.Roleはデータベースから取得した最新のロール情報
@@ -43,9 +49,9 @@ func RoleBasedAccessControl(allowedRoles ...string) gin.HandlerFunc {
 		}
 
 		if !hasAccess {
-			log.Printf("RoleBasedAccessControl: Access denied. User role=%s, Required roles=%v",
-				userModel.Role, allowedRoles)
-			ctx.AbortWithStatus(http.StatusForbidden)
+			slog.InfoContext(ctx.Request.Context(), "Access denied",
+				"user_id", userModel.ID, "role", userModel.Role, "required_roles", allowedRoles)
+			abortWithError(ctx, apperrors.ErrForbidden)
 			return
 		}
 
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"gin-fleamarket/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope APIキーで認証されたリクエストに指定スコープを要求するミドルウェア
+// Bearerトークンで認証されたリクエストはユーザー本人の操作としてそのまま通す
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scopes, isAPIKey := ctx.Get("apiKeyScopes")
+		if !isAPIKey {
+			ctx.Next()
+			return
+		}
+
+		for _, granted := range scopes.([]string) {
+			if granted == scope {
+				ctx.Next()
+				return
+			}
+		}
+
+		abortWithError(ctx, apperrors.ErrInsufficientScope)
+	}
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"gin-fleamarket/models"
+	"gin-fleamarket/tracing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tracing リクエストごとにサーバーのスパンを作り、リクエストのcontextに設定する
+// traceparentヘッダーがあれば呼び出し元のトレースを引き継ぐ。スパン名は生のパスではなくルートのテンプレートにする
+func Tracing() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		name := ctx.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+		parent := tracing.Extract(ctx.Request.Context(), ctx.Request.Header)
+		spanCtx, span := tracing.Start(parent, name,
+			tracing.WithSpanKind(tracing.SpanKindServer),
+			tracing.WithAttributes(
+				tracing.String("http.request.method", ctx.Request.Method),
+				tracing.String("http.route", route),
+				tracing.String("url.path", ctx.Request.URL.Path),
+			),
+		)
+		defer span.End()
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+		if user, exists := ctx.Get("user"); exists {
+			span.SetAttributes(tracing.Int64("enduser.id", int64(user.(*models.User).ID)))
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"gin-fleamarket/models"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLockID 複数のインスタンスが同時に起動してもマイグレーションを1回だけ適用するためのアドバイザリーロックのキー
+const migrationLockID = 7_301_003
+
+// baselineVersion AutoMigrateで作成していた時点のスキーマを作るマイグレーション
+const baselineVersion = 20261018000000
+
+// Migration 1つのスキーマ変更。SQLファイルから読み込むか、Goの関数で定義する
+// 方言ごとに処理を分ける場合はtx.Dialector.Name()で判断する
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	// Down 省略した場合はロールバックできない
+	Down func(tx *gorm.DB) error
+}
+
+// MigrationStatus AppliedAtがnilの場合は未適用
+// Unknownは適用済みだが、このバージョンのコードに含まれていないマイグレーション（新しいバージョンから戻した場合など）
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+type IMigrator interface {
+	// Up 未適用のマイグレーションを古い順にすべて適用し、適用したものを返す
+	Up() ([]Migration, error)
+	// Down 適用済みのマイグレーションを新しい順にsteps個ロールバックし、戻したものを返す
+	Down(steps int) ([]Migration, error)
+	Status() ([]MigrationStatus, error)
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New DBの方言に合わせて、埋め込まれたSQLのマイグレーションを読み込む
+func New(db *gorm.DB) (IMigrator, error) {
+	migrations, err := LoadSQL(sqlFiles, "sql/"+db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return NewWithMigrations(db, migrations), nil
+}
+
+func NewWithMigrations(db *gorm.DB, migrations []Migration) IMigrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, migrations: sorted}
+}
+
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(func() error {
+		done, err := m.appliedVersions()
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 && m.hasLegacySchema() {
+			if err := m.baseline(); err != nil {
+				return err
+			}
+			done[baselineVersion] = models.SchemaMigration{Version: baselineVersion}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			slog.Info("Applying migration", "version", migration.Version, "name", migration.Name)
+			err := m.db.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&models.SchemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(func() error {
+		done, err := m.appliedVersions()
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d_%s cannot be rolled back", migration.Version, migration.Name)
+			}
+			slog.Info("Rolling back migration", "version", migration.Version, "name", migration.Name)
+			err := m.db.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&models.SchemaMigration{}, migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	done, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := done[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range done {
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, AppliedAt: &row.AppliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+func (m *Migrator) appliedVersions() (map[int64]models.SchemaMigration, error) {
+	if err := m.db.AutoMigrate(&models.SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var rows []models.SchemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int64]models.SchemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+// withLock PostgreSQLでは、Lambdaのコールドスタートなどで複数のインスタンスが同時にマイグレーションしないよう直列化する
+// セッション単位のロックのため、取得した接続を保持したまま実行する（コネクションプールの上限は2以上にすること）
+// SQLiteは単一プロセスでの利用を想定しているためロックしない
+func (m *Migrator) withLock(fn func() error) error {
+	if m.db.Dialector.Name() != "postgres" {
+		return fn()
+	}
+
+	ctx := context.Background()
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			slog.Error("Failed to release migration lock", "error", err)
+		}
+	}()
+	return fn()
+}
+
+// hasLegacySchema マイグレーションの履歴がないのにテーブルがある場合は、AutoMigrateで作成されたスキーマとみなす
+func (m *Migrator) hasLegacySchema() bool {
+	for _, migration := range m.migrations {
+		if migration.Version == baselineVersion {
+			return m.db.Migrator().HasTable(&models.User{})
+		}
+	}
+	return false
+}
+
+// baseline AutoMigrateで作成されたスキーマを基準のスキーマに揃え、基準のマイグレーションを適用済みとして記録する
+// 基準より古いコードで作成されたスキーマでも揃うよう、最後に1度だけAutoMigrateを実行する
+func (m *Migrator) baseline() error {
+	slog.Info("Existing schema without migration history detected; baselining", "version", baselineVersion)
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		// 以前のスキーマではユーザーの削除で商品（購入者の取引履歴を含む）が連鎖削除されていたため、制約を作り直す
+		if tx.Migrator().HasConstraint(&models.User{}, "Items") {
+			if err := tx.Migrator().DropConstraint(&models.User{}, "Items"); err != nil {
+				return err
+			}
+		}
+		if err := tx.AutoMigrate(baselineModels...); err != nil {
+			return err
+		}
+		return tx.Create(&models.SchemaMigration{
+			Version:   baselineVersion,
+			Name:      "baseline",
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+// baselineModels 基準のスキーマに含まれるモデル
+// 以降のスキーマ変更はマイグレーションで行うため、このリストには追加しない
+var baselineModels = []interface{}{
+	&models.User{}, &models.Item{}, &models.UserIdentity{}, &models.OIDCLoginState{}, &models.SigningKey{},
+	&models.RefreshSession{}, &models.APIKey{}, &models.Role{}, &models.Permission{}, &models.AuditLog{},
+	&models.RevokedToken{}, &models.JobLease{}, &models.JobRun{},
+}
@@ -0,0 +1,118 @@
+package migrate
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sqlFiles sql/<方言>/<バージョン>_<名前>.up.sql と .down.sql
+//
+//go:embed sql
+var sqlFiles embed.FS
+
+// Dialects SQLのマイグレーションを用意する方言（gormのDialector名）
+var Dialects = []string{"postgres", "sqlite"}
+
+var (
+	fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	namePattern     = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// ErrInvalidName マイグレーション名に使えない文字が含まれている
+var ErrInvalidName = errors.New("migration name must consist of lowercase letters, digits and underscores")
+
+// LoadSQL dirにあるSQLファイルをマイグレーションとして読み込む。downのファイルは省略できる
+func LoadSQL(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", path.Base(dir), err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	var versions []int64
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+			versions = append(versions, version)
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = execSQL(string(body))
+		} else {
+			migration.Down = execSQL(string(body))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		migration := byVersion[version]
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up file", version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
+
+// execSQL 複数の文を含むSQLをそのまま実行する（pgx・go-sqlite3ともに、引数なしのExecは複数の文に対応している）
+func execSQL(body string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(body).Error
+	}
+}
+
+// Create dirの方言ごとのディレクトリに、現在時刻をバージョンにした空のup/downファイルを作成する
+func Create(dir string, name string, now time.Time) ([]string, error) {
+	name = strings.ToLower(name)
+	if !namePattern.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	version := now.UTC().Format("20060102150405")
+
+	var created []string
+	for _, dialect := range Dialects {
+		for _, direction := range []string{"up", "down"} {
+			file := filepath.Join(dir, dialect, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			body := fmt.Sprintf("-- %s: %s (%s)\n", name, direction, dialect)
+			if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				return nil, err
+			}
+			// 同じ秒に同名で作成した場合に上書きしない
+			f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			_, err = f.WriteString(body)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			created = append(created, file)
+		}
+	}
+	return created, nil
+}
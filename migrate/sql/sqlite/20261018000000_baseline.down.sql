@@ -0,0 +1,14 @@
+DROP TABLE job_runs;
+DROP TABLE job_leases;
+DROP TABLE revoked_tokens;
+DROP TABLE audit_logs;
+DROP TABLE role_permissions;
+DROP TABLE permissions;
+DROP TABLE roles;
+DROP TABLE api_keys;
+DROP TABLE refresh_sessions;
+DROP TABLE signing_keys;
+DROP TABLE o_id_c_login_states;
+DROP TABLE user_identities;
+DROP TABLE items;
+DROP TABLE users;
@@ -0,0 +1,195 @@
+-- AutoMigrateで作成していた時点のスキーマ
+
+CREATE TABLE "users" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "email" text NOT NULL,
+    "password" text NOT NULL,
+    "role" text NOT NULL DEFAULT 'user',
+    "status" text NOT NULL DEFAULT 'active',
+    "status_reason" text,
+    "suspended_until" integer NOT NULL DEFAULT 0,
+    "display_name" text,
+    "avatar_url" text,
+    "bio" text,
+    "prefecture" text,
+    CONSTRAINT "uni_users_email" UNIQUE ("email")
+);
+CREATE INDEX "idx_users_status" ON "users"("status");
+CREATE INDEX "idx_users_deleted_at" ON "users"("deleted_at");
+
+CREATE TABLE "items" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "name" text NOT NULL,
+    "price" integer NOT NULL,
+    "description" text,
+    "sold_out" numeric NOT NULL DEFAULT false,
+    "user_id" integer NOT NULL,
+    CONSTRAINT "fk_users_items" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE RESTRICT
+);
+CREATE INDEX "idx_items_deleted_at" ON "items"("deleted_at");
+
+CREATE TABLE "user_identities" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer NOT NULL,
+    "provider" text NOT NULL,
+    "subject" text NOT NULL,
+    "email" text
+);
+CREATE UNIQUE INDEX "idx_identity_provider_subject" ON "user_identities"("provider","subject");
+CREATE INDEX "idx_user_identities_user_id" ON "user_identities"("user_id");
+CREATE INDEX "idx_user_identities_deleted_at" ON "user_identities"("deleted_at");
+
+CREATE TABLE "o_id_c_login_states" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "state" text NOT NULL,
+    "provider" text NOT NULL,
+    "nonce" text NOT NULL,
+    "code_verifier" text NOT NULL,
+    "expires_at" integer NOT NULL,
+    CONSTRAINT "uni_o_id_c_login_states_state" UNIQUE ("state")
+);
+CREATE INDEX "idx_o_id_c_login_states_expires_at" ON "o_id_c_login_states"("expires_at");
+CREATE INDEX "idx_o_id_c_login_states_state" ON "o_id_c_login_states"("state");
+CREATE INDEX "idx_o_id_c_login_states_deleted_at" ON "o_id_c_login_states"("deleted_at");
+
+CREATE TABLE "signing_keys" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "kid" text NOT NULL,
+    "algorithm" text NOT NULL,
+    "private_key" text NOT NULL,
+    "retired_at" integer NOT NULL DEFAULT 0,
+    CONSTRAINT "uni_signing_keys_kid" UNIQUE ("kid")
+);
+CREATE INDEX "idx_signing_keys_retired_at" ON "signing_keys"("retired_at");
+CREATE INDEX "idx_signing_keys_kid" ON "signing_keys"("kid");
+CREATE INDEX "idx_signing_keys_deleted_at" ON "signing_keys"("deleted_at");
+
+CREATE TABLE "refresh_sessions" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "family_id" text NOT NULL,
+    "user_id" integer NOT NULL,
+    "current_jti" text NOT NULL,
+    "user_agent" text,
+    "ip_address" text,
+    "last_used_at" integer NOT NULL,
+    "expires_at" integer NOT NULL,
+    "revoked_at" integer NOT NULL DEFAULT 0,
+    CONSTRAINT "uni_refresh_sessions_family_id" UNIQUE ("family_id")
+);
+CREATE INDEX "idx_refresh_sessions_expires_at" ON "refresh_sessions"("expires_at");
+CREATE INDEX "idx_refresh_sessions_user_id" ON "refresh_sessions"("user_id");
+CREATE INDEX "idx_refresh_sessions_family_id" ON "refresh_sessions"("family_id");
+CREATE INDEX "idx_refresh_sessions_deleted_at" ON "refresh_sessions"("deleted_at");
+
+CREATE TABLE "api_keys" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer NOT NULL,
+    "name" text NOT NULL,
+    "prefix" text NOT NULL,
+    "key_hash" text NOT NULL,
+    "scopes" text NOT NULL,
+    "expires_at" integer NOT NULL DEFAULT 0,
+    "last_used_at" integer NOT NULL DEFAULT 0,
+    CONSTRAINT "uni_api_keys_key_hash" UNIQUE ("key_hash")
+);
+CREATE INDEX "idx_api_keys_key_hash" ON "api_keys"("key_hash");
+CREATE INDEX "idx_api_keys_user_id" ON "api_keys"("user_id");
+CREATE INDEX "idx_api_keys_deleted_at" ON "api_keys"("deleted_at");
+
+CREATE TABLE "roles" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "name" text NOT NULL,
+    "description" text,
+    "builtin" numeric NOT NULL DEFAULT false,
+    CONSTRAINT "uni_roles_name" UNIQUE ("name")
+);
+CREATE INDEX "idx_roles_deleted_at" ON "roles"("deleted_at");
+
+CREATE TABLE "permissions" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "name" text NOT NULL,
+    "description" text,
+    CONSTRAINT "uni_permissions_name" UNIQUE ("name")
+);
+CREATE INDEX "idx_permissions_deleted_at" ON "permissions"("deleted_at");
+
+CREATE TABLE "role_permissions" (
+    "role_id" integer,
+    "permission_id" integer,
+    PRIMARY KEY ("role_id","permission_id"),
+    CONSTRAINT "fk_role_permissions_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id"),
+    CONSTRAINT "fk_role_permissions_permission" FOREIGN KEY ("permission_id") REFERENCES "permissions"("id")
+);
+
+CREATE TABLE "audit_logs" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime NOT NULL,
+    "action" text NOT NULL,
+    "actor_id" integer NOT NULL DEFAULT 0,
+    "target_id" integer NOT NULL DEFAULT 0,
+    "ip_address" text,
+    "user_agent" text,
+    "detail" text,
+    "prev_hash" text NOT NULL DEFAULT '',
+    "hash" text NOT NULL DEFAULT ''
+);
+CREATE UNIQUE INDEX "idx_audit_logs_hash" ON "audit_logs"("hash");
+CREATE INDEX "idx_audit_logs_target_id" ON "audit_logs"("target_id");
+CREATE INDEX "idx_audit_logs_actor_id" ON "audit_logs"("actor_id");
+CREATE INDEX "idx_audit_logs_action" ON "audit_logs"("action");
+CREATE INDEX "idx_audit_logs_created_at" ON "audit_logs"("created_at");
+
+CREATE TABLE "revoked_tokens" (
+    "jti" text,
+    "expires_at" integer NOT NULL,
+    "created_at" datetime,
+    PRIMARY KEY ("jti")
+);
+CREATE INDEX "idx_revoked_tokens_expires_at" ON "revoked_tokens"("expires_at");
+
+CREATE TABLE "job_leases" (
+    "name" text,
+    "holder" text NOT NULL,
+    "expires_at" integer NOT NULL,
+    PRIMARY KEY ("name")
+);
+
+CREATE TABLE "job_runs" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "job_name" text NOT NULL,
+    "holder" text NOT NULL,
+    "started_at" datetime NOT NULL,
+    "finished_at" datetime,
+    "duration_ms" integer NOT NULL DEFAULT 0,
+    "status" text NOT NULL,
+    "error" text
+);
+CREATE INDEX "idx_job_runs_status" ON "job_runs"("status");
+CREATE INDEX "idx_job_runs_name_started" ON "job_runs"("job_name","started_at");
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_created_at" ON "revoked_tokens" ("created_at");
@@ -1,21 +1,101 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"gin-fleamarket/config"
 	"gin-fleamarket/infra"
-	"gin-fleamarket/models"
+	"gin-fleamarket/migrate"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
 )
 
+// スキーマのマイグレーションを実行する
+//
+//	go run ./migrations up            未適用のマイグレーションをすべて適用する
+//	go run ./migrations down [n]      直近のn個（既定は1個）をロールバックする
+//	go run ./migrations status        適用状況を表示する
+//	go run ./migrations create <name> 空のマイグレーションファイルを方言ごとに作成する
 func main() {
-	infra.Initialize()
-	db := infra.SetupDB()
+	dir := flag.String("dir", "migrate/sql", "directory to create migration files in")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrations [-dir migrate/sql] up | down [n] | status | create <name>")
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	if err := db.AutoMigrate(&models.Item{}, &models.User{}); err != nil {
-		panic("Failed to migrate database")
+	// ファイルの作成にはDBへの接続は不要
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		files, err := migrate.Create(*dir, args[1], time.Now())
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		return
+	}
+
+	db, err := infra.OpenDB(config.MustLoad())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	migrator, err := migrate.New(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
 
-	// トークンブラックリスト用のSQLiteデータベースのマイグレーション
-	tokenDB := infra.SetupTokenDB()
-	if err := tokenDB.AutoMigrate(&models.BlacklistedToken{}); err != nil {
-		panic("Failed to migrate token blacklist database")
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		log.Printf("Applied %d migrations", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				flag.Usage()
+				os.Exit(2)
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		if err != nil {
+			log.Fatalf("Failed to roll back: %v", err)
+		}
+		log.Printf("Rolled back %d migrations", len(reverted))
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Failed to load migration status: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Unknown {
+				appliedAt += " (not in this build)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
 }
@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// APIKey スクリプトや外部連携用の個人APIキー
+// 平文のキーは発行時に一度だけ返し、DBにはSHA-256ハッシュのみを保存する
+type APIKey struct {
+	gorm.Model
+	UserID     uint   `gorm:"not null;index"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null"`
+	KeyHash    string `gorm:"not null;unique;index"`
+	Scopes     string `gorm:"not null"`
+	ExpiresAt  int64  `gorm:"not null;default:0"`
+	LastUsedAt int64  `gorm:"not null;default:0"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// AuditLog セキュリティ上重要な操作の記録。追記のみで削除はしない
+// 各行は直前の行のハッシュを含めてハッシュ化されるため、途中の行の改ざんや削除は検証で検出できる
+// 退会したユーザーが操作した行のIPアドレスとUser-Agentだけは消去するため、ハッシュに含めない
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"not null;index"`
+	Action    string    `gorm:"not null;index"`
+	// ActorID 操作したユーザー（未ログインの操作は0）
+	ActorID uint `gorm:"not null;default:0;index"`
+	// TargetID 操作の対象となったユーザー（対象がない場合は0）
+	TargetID  uint `gorm:"not null;default:0;index"`
+	IPAddress string
+	UserAgent string
+	// Detail アクションごとの追加情報（JSON）
+	Detail   string
+	PrevHash string `gorm:"not null;default:''"`
+	Hash     string `gorm:"not null;default:'';uniqueIndex"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// JobLease スケジューラーのリーダー選出用のリース
+// 期限内にリースを更新し続けたインスタンスだけがジョブを実行する
+type JobLease struct {
+	Name      string `gorm:"primaryKey"`
+	Holder    string `gorm:"not null"`
+	ExpiresAt int64  `gorm:"not null"`
+}
+
+// JobRun ジョブ1回分の実行履歴
+type JobRun struct {
+	ID         uint      `gorm:"primaryKey"`
+	JobName    string    `gorm:"not null;index:idx_job_runs_name_started"`
+	Holder     string    `gorm:"not null"`
+	StartedAt  time.Time `gorm:"not null;index:idx_job_runs_name_started"`
+	FinishedAt *time.Time
+	DurationMs int64 `gorm:"not null;default:0"`
+	// Status running / succeeded / failed
+	Status string `gorm:"not null;index"`
+	Error  string
+}
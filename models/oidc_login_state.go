@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// OIDCLoginState 認可リクエストごとのstate/nonce/PKCE verifier
+// Lambdaの複数インスタンスでコールバックを受けても検証できるようにDBに保存する
+type OIDCLoginState struct {
+	gorm.Model
+	State        string `gorm:"not null;unique;index"`
+	Provider     string `gorm:"not null"`
+	Nonce        string `gorm:"not null"`
+	CodeVerifier string `gorm:"not null"`
+	ExpiresAt    int64  `gorm:"not null;index"`
+}
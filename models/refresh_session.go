@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// RefreshSession ログイン1回分のリフレッシュトークンファミリー
+// リフレッシュのたびにCurrentJTIが更新され、古いjtiが提示された場合は再利用とみなしてファミリーごと失効させる
+type RefreshSession struct {
+	gorm.Model
+	FamilyID   string `gorm:"not null;unique;index"`
+	UserID     uint   `gorm:"not null;index"`
+	CurrentJTI string `gorm:"not null"`
+	UserAgent  string
+	IPAddress  string
+	LastUsedAt int64 `gorm:"not null"`
+	ExpiresAt  int64 `gorm:"not null;index"`
+	RevokedAt  int64 `gorm:"not null;default:0"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RevokedToken ログアウトなどで失効させたトークン。JWTのjtiで管理し、期限切れの行は削除してよい
+type RevokedToken struct {
+	JTI       string `gorm:"primaryKey"`
+	ExpiresAt int64  `gorm:"not null;index"`
+	// CreatedAt 失効させた時刻。ブルームフィルタの差分更新で使う
+	CreatedAt time.Time `gorm:"index"`
+}
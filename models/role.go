@@ -0,0 +1,19 @@
+package models
+
+import "gorm.io/gorm"
+
+// Role ユーザーに付与するロール。User.Roleにはロール名が入る
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"not null;unique"`
+	Description string
+	Builtin     bool         `gorm:"not null;default:false"`
+	Permissions []Permission `gorm:"many2many:role_permissions;"`
+}
+
+// Permission ロールに割り当てる名前付きの権限（例: item.delete.any）
+type Permission struct {
+	gorm.Model
+	Name        string `gorm:"not null;unique"`
+	Description string
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SchemaMigration 適用済みのマイグレーション。Versionはマイグレーションのファイル名の先頭の数値
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// SigningKey JWT署名用の鍵（PKCS#8 PEM形式の秘密鍵）
+// 秘密鍵は暗号化せずに保存するため、このテーブルを読めればトークンを偽造できる。DBとバックアップの権限で保護する
+// RetiredAtが0の鍵が署名に使われ、退役済みの鍵は発行済みトークンの有効期限まで検証にのみ使われる
+type SigningKey struct {
+	gorm.Model
+	Kid        string `gorm:"not null;unique;index"`
+	Algorithm  string `gorm:"not null"`
+	PrivateKey string `gorm:"not null"`
+	RetiredAt  int64  `gorm:"not null;default:0;index"`
+}
@@ -7,5 +7,17 @@ type User struct {
 	Email    string `gorm:"not null;unique"`
 	Password string `gorm:"not null"`
 	Role     string `gorm:"not null;default:'user'"`
-	Items    []Item `gorm:"constraint:OnDelete:CASCADE;"`
+	// Status active / suspended / banned / deleted
+	Status       string `gorm:"not null;default:'active';index"`
+	StatusReason string
+	// SuspendedUntil 一時停止の解除日時（UNIX秒）。0の場合は無期限
+	SuspendedUntil int64 `gorm:"not null;default:0"`
+	// 公開プロフィール（メールアドレスは公開しない）
+	DisplayName string
+	AvatarURL   string
+	Bio         string
+	Prefecture  string
+	// 売れた商品は購入者側の取引履歴でもあるため、ユーザーの削除で連鎖削除しない
+	// 退会はDELETEではなく匿名化で行う
+	Items []Item `gorm:"constraint:OnDelete:RESTRICT;"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// UserIdentity 外部IDプロバイダ（OIDC）のアカウントとUserの紐付け
+type UserIdentity struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	Provider string `gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject  string `gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Email    string
+}
@@ -0,0 +1,94 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"gin-fleamarket/jwks"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// remoteKeySet プロバイダのJWKSをキャッシュし、未知のkidを受け取った時だけ再取得する
+type remoteKeySet struct {
+	uri        string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// 鍵ローテーション直後でも取得が連打されないように最低限の間隔を空ける
+const jwksMinRefreshInterval = 30 * time.Second
+
+func newRemoteKeySet(uri string, httpClient *http.Client) *remoteKeySet {
+	return &remoteKeySet{uri: uri, httpClient: httpClient}
+}
+
+func (s *remoteKeySet) keyFor(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	if s.keys != nil && time.Since(s.fetchedAt) < jwksMinRefreshInterval {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id: %s", kid)
+}
+
+// lookup kidが空の場合は鍵が1つだけの時に限りそれを使う
+func (s *remoteKeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *remoteKeySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.uri, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set jwks.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode jwks: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
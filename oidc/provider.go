@@ -0,0 +1,212 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config OpenID Connectプロバイダ1つ分の設定
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// IDTokenClaims 検証済みIDトークンから取り出した値
+type IDTokenClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Nonce         string
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider Relying Partyとして1つのOpenID Connectプロバイダとやり取りする
+// ディスカバリは初回利用時に遅延実行する（起動時に外部へ通信しないため）
+type Provider struct {
+	config     Config
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keySet    *remoteKeySet
+}
+
+func NewProvider(config Config, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{config: config, httpClient: httpClient}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch discovery document: status %d", resp.StatusCode)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %v", err)
+	}
+	if doc.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected %s, got %s", p.config.Issuer, doc.Issuer)
+	}
+
+	p.discovery = &doc
+	p.keySet = newRemoteKeySet(doc.JWKSURI, p.httpClient)
+	return p.discovery, nil
+}
+
+// AuthCodeURL 認可エンドポイントへのリダイレクト先URLを組み立てる
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(doc.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(p.config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+	authURL.RawQuery = q.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange 認可コードをトークンエンドポイントでIDトークンに交換する
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to exchange code: status %d", resp.StatusCode)
+	}
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+	return tokenResponse.IDToken, nil
+}
+
+// VerifyIDToken 署名（JWKS）、iss、aud、exp、nonceを検証してクレームを返す
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, expectedNonce string) (*IDTokenClaims, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keySet.keyFor(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid id token claims")
+	}
+
+	nonce, _ := claims["nonce"].(string)
+	if expectedNonce == "" || nonce != expectedNonce {
+		return nil, fmt.Errorf("id token nonce mismatch")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("id token has no subject")
+	}
+
+	result := &IDTokenClaims{Subject: subject, Nonce: nonce}
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	// email_verifiedを文字列で返すプロバイダもあるため両方を受け付ける
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = v
+	case string:
+		result.EmailVerified = v == "true"
+	}
+	return result, nil
+}
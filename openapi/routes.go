@@ -0,0 +1,246 @@
+package openapi
+
+import (
+	"gin-fleamarket/config"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/jwks"
+	"gin-fleamarket/models"
+	"net/http"
+)
+
+var (
+	// userAuth /meなど、ログイン中のユーザー（JWT）が呼ぶエンドポイント
+	userAuth = []string{BearerAuth, CookieAuth}
+	// itemAuth 商品APIはAPIキーでも呼べる
+	itemAuth = []string{BearerAuth, CookieAuth, APIKeyAuth}
+)
+
+// statusSchema /livezなどの{"status": "ok"}
+var statusSchema = &Schema{
+	Type:       "object",
+	Properties: map[string]*Schema{"status": {Type: "string", Enum: []any{constants.HealthStatusOK}}},
+	Required:   []string{"status"},
+}
+
+var messageSchema = &Schema{
+	Type:       "object",
+	Properties: map[string]*Schema{"message": {Type: "string"}},
+	Required:   []string{"message"},
+}
+
+var permissionsSchema = &Schema{
+	Type: "array",
+	Items: &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"name": {Type: "string"}, "description": {Type: "string"}},
+		Required:   []string{"name", "description"},
+	},
+}
+
+var avatarSchema = &Schema{
+	Type:       "object",
+	Properties: map[string]*Schema{"avatar": {Type: "string", ContentMediaType: "image/*", Description: "JPEG / PNG / GIF / WebP"}},
+	Required:   []string{"avatar"},
+}
+
+// modeCookie ログインとOIDCのコールバックで、トークンをCookieで受け取る場合に指定する
+var modeCookie = Parameter{Name: "mode", In: "query", Schema: &Schema{Type: "string", Enum: []any{"cookie"}}}
+
+var tags = []Tag{
+	{Name: "items", Description: "商品"},
+	{Name: "auth", Description: "認証"},
+	{Name: "me", Description: "ログイン中のユーザー自身"},
+	{Name: "users", Description: "公開プロフィール"},
+	{Name: "admin", Description: "管理"},
+	{Name: "system", Description: "ヘルスチェック・メトリクス・ドキュメント"},
+}
+
+// Routes setupRouterで登録しているすべてのエンドポイント（ルートを追加した場合はここにも追加する）
+func Routes() []Route {
+	return []Route{
+		// 商品
+		{Method: http.MethodGet, Path: "/items", Tag: "items", Summary: "商品の一覧",
+			Description: "未ログインの場合は公開中の商品のみ。出品者本人とモデレーターは非公開の商品も取得できる",
+			Security:    itemAuth, OptionalAuth: true, Response: Data(http.StatusOK, []models.Item{})},
+		{Method: http.MethodGet, Path: "/items/:id", Tag: "items", Summary: "商品の詳細",
+			Security: itemAuth, OptionalAuth: true, Scope: constants.ScopeItemsRead,
+			Response: Data(http.StatusOK, models.Item{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodPost, Path: "/items", Tag: "items", Summary: "商品の出品",
+			Security: itemAuth, Scope: constants.ScopeItemsWrite, Body: dto.CreateItemInput{},
+			Response: Data(http.StatusCreated, models.Item{}), Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodPut, Path: "/items/:id", Tag: "items", Summary: "商品の更新",
+			Security: itemAuth, Scope: constants.ScopeItemsWrite, Body: dto.UpdateItemInput{},
+			Response: Data(http.StatusOK, models.Item{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodDelete, Path: "/items/:id", Tag: "items", Summary: "商品の削除",
+			Description: "出品者本人、または`item.delete.any`権限を持つモデレーターが削除できる",
+			Security:    itemAuth, Scope: constants.ScopeItemsWrite,
+			Response: Empty(http.StatusOK), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+		// 認証
+		{Method: http.MethodPost, Path: "/auth/signup", Tag: "auth", Summary: "ユーザー登録",
+			Body: dto.SignupInput{}, Response: Empty(http.StatusCreated), Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/auth/login", Tag: "auth", Summary: "ログイン",
+			Description: "`?mode=cookie`の場合はトークンをHttpOnlyのCookieで返し、ボディにはCSRFトークンのみを含める",
+			QueryParams: []Parameter{modeCookie}, Body: dto.LoginInput{},
+			Response: JSON(http.StatusOK, AnyOf(dto.LoginResponse{}, dto.CookieLoginResponse{})),
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden}},
+		{Method: http.MethodPost, Path: "/auth/refresh", Tag: "auth", Summary: "トークンの更新",
+			Description: "ボディを省略した場合はrefresh_token Cookieを使い、新しいトークンもCookieで返す（X-CSRF-Tokenヘッダーが必要）",
+			Body:        dto.RefreshTokenInput{}, BodyOptional: true,
+			Response: JSON(http.StatusOK, AnyOf(dto.LoginResponse{}, dto.CookieLoginResponse{})),
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden}},
+		{Method: http.MethodPost, Path: "/auth/logout", Tag: "auth", Summary: "ログアウト",
+			Security: userAuth, Response: JSON(http.StatusOK, messageSchema), Errors: []int{http.StatusForbidden}},
+		{Method: http.MethodPost, Path: "/auth/bootstrap", Tag: "auth", Summary: "初期管理者の作成",
+			Description: "ADMIN_BOOTSTRAP_TOKENを設定した場合のみ有効。管理者が既に存在する場合は409",
+			Security:    []string{BootstrapAuth}, Body: dto.SignupInput{}, Response: Empty(http.StatusCreated),
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodGet, Path: "/auth/oidc/:provider/login", Tag: "auth", Summary: "OIDCログインの開始",
+			Response: Redirect(http.StatusFound, "プロバイダの認可エンドポイントへのリダイレクト"),
+			Errors:   []int{http.StatusNotFound, http.StatusBadGateway}},
+		{Method: http.MethodGet, Path: "/auth/oidc/:provider/callback", Tag: "auth", Summary: "OIDCログインのコールバック",
+			QueryParams: []Parameter{
+				{Name: "code", In: "query", Schema: &Schema{Type: "string"}},
+				{Name: "state", In: "query", Schema: &Schema{Type: "string"}},
+				{Name: "error", In: "query", Schema: &Schema{Type: "string"}},
+			},
+			Response: JSON(http.StatusOK, dto.LoginResponse{}),
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound}},
+
+		// ログイン中のユーザー
+		{Method: http.MethodPut, Path: "/me/password", Tag: "me", Summary: "パスワードの変更",
+			Description: "変更するとすべてのセッションが失効する",
+			Security:    userAuth, Body: dto.ChangePasswordInput{}, Response: Empty(http.StatusNoContent),
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+		{Method: http.MethodGet, Path: "/me/profile", Tag: "me", Summary: "自分のプロフィール",
+			Security: userAuth, Response: Data(http.StatusOK, dto.ProfileResponse{}), Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodPut, Path: "/me/profile", Tag: "me", Summary: "プロフィールの更新",
+			Security: userAuth, Body: dto.UpdateProfileInput{}, Response: Data(http.StatusOK, dto.ProfileResponse{}),
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodPut, Path: "/me/profile/avatar", Tag: "me", Summary: "アバター画像の更新",
+			Security: userAuth, Body: avatarSchema, BodyContentType: "multipart/form-data",
+			Response: Data(http.StatusOK, dto.ProfileResponse{}),
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge}},
+		{Method: http.MethodGet, Path: "/me/export", Tag: "me", Summary: "個人データのエクスポート",
+			Security: userAuth, Response: Data(http.StatusOK, dto.AccountExport{}), Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodDelete, Path: "/me", Tag: "me", Summary: "アカウントの削除",
+			Description: "最後の管理者は削除できない（409）",
+			Security:    userAuth, Response: Empty(http.StatusNoContent),
+			Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodGet, Path: "/me/sessions", Tag: "me", Summary: "ログイン中のセッションの一覧",
+			Security: userAuth, Response: Data(http.StatusOK, []dto.SessionResponse{})},
+		{Method: http.MethodDelete, Path: "/me/sessions/:id", Tag: "me", Summary: "セッションの失効",
+			Security: userAuth, Response: Empty(http.StatusNoContent), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/me/api-keys", Tag: "me", Summary: "APIキーの一覧",
+			Security: userAuth, Response: Data(http.StatusOK, []dto.APIKeyResponse{})},
+		{Method: http.MethodPost, Path: "/me/api-keys", Tag: "me", Summary: "APIキーの発行",
+			Description: "平文のキーはこのレスポンスでのみ返す",
+			Security:    userAuth, Body: dto.CreateAPIKeyInput{}, Response: Data(http.StatusCreated, dto.CreateAPIKeyResponse{}),
+			Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodDelete, Path: "/me/api-keys/:id", Tag: "me", Summary: "APIキーの失効",
+			Security: userAuth, Response: Empty(http.StatusNoContent), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+		// 公開プロフィール
+		{Method: http.MethodGet, Path: "/users/:id", Tag: "users", Summary: "出品者の公開プロフィールと出品中の商品",
+			Response: Data(http.StatusOK, dto.PublicUserResponse{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/uploads/*filepath", Tag: "users", Summary: "アップロードされたファイル（アバター画像など）",
+			Response: Content(http.StatusOK, "application/octet-stream"), Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodHead, Path: "/uploads/*filepath", Tag: "users", Summary: "アップロードされたファイルのヘッダー",
+			Response: Empty(http.StatusOK), Errors: []int{http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/.well-known/jwks.json", Tag: "auth", Summary: "トークン検証用の公開鍵セット（JWKS）",
+			Response: JSON(http.StatusOK, jwks.JSONWebKeySet{})},
+
+		// 管理
+		{Method: http.MethodPost, Path: "/admin/keys/rotate", Tag: "admin", Summary: "署名鍵のローテーション",
+			Security: userAuth, Permission: constants.PermSystemManage, Response: Empty(http.StatusNoContent)},
+		{Method: http.MethodGet, Path: "/admin/users", Tag: "admin", Summary: "ユーザーの検索",
+			Security: userAuth, Permission: constants.PermUserManage, Query: dto.UserSearchQuery{},
+			Response: Page([]dto.UserResponse{}), Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodGet, Path: "/admin/users/:id", Tag: "admin", Summary: "ユーザーの詳細",
+			Security: userAuth, Permission: constants.PermUserManage,
+			Response: Data(http.StatusOK, dto.UserResponse{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/admin/users/:id/items", Tag: "admin", Summary: "ユーザーが出品した商品（非公開を含む）",
+			Security: userAuth, Permission: constants.PermUserManage,
+			Response: Data(http.StatusOK, []models.Item{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodPut, Path: "/admin/users/:id/role", Tag: "admin", Summary: "ロールの変更",
+			Security: userAuth, Permission: constants.PermUserManage, Body: dto.UpdateUserRoleInput{},
+			Response: Data(http.StatusOK, dto.UserResponse{}),
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/admin/users/:id/impersonate", Tag: "admin", Summary: "なりすましトークンの発行",
+			Security: userAuth, Permission: constants.PermUserImpersonate, Body: dto.ImpersonateInput{},
+			Response: Data(http.StatusCreated, dto.ImpersonationResponse{}),
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodPost, Path: "/admin/users/:id/suspend", Tag: "admin", Summary: "ユーザーの一時停止",
+			Security: userAuth, Permission: constants.PermUserBan, Body: dto.UpdateUserStatusInput{}, BodyOptional: true,
+			Response: Data(http.StatusOK, dto.UserResponse{}),
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/admin/users/:id/ban", Tag: "admin", Summary: "ユーザーの利用停止",
+			Security: userAuth, Permission: constants.PermUserBan, Body: dto.UpdateUserStatusInput{}, BodyOptional: true,
+			Response: Data(http.StatusOK, dto.UserResponse{}),
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/admin/users/:id/reinstate", Tag: "admin", Summary: "停止の解除",
+			Security: userAuth, Permission: constants.PermUserBan,
+			Response: Data(http.StatusOK, dto.UserResponse{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/admin/audit-logs", Tag: "admin", Summary: "監査ログの検索",
+			Security: userAuth, Permission: constants.PermAuditRead, Query: dto.AuditLogQuery{},
+			Response: Page([]dto.AuditLogResponse{}), Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodGet, Path: "/admin/audit-logs/verify", Tag: "admin", Summary: "監査ログの改ざん検知",
+			Security: userAuth, Permission: constants.PermAuditRead, Response: Data(http.StatusOK, dto.AuditChainVerification{})},
+		{Method: http.MethodGet, Path: "/admin/config", Tag: "admin", Summary: "読み込まれた設定（秘密情報は伏せる）",
+			Security: userAuth, Permission: constants.PermSystemManage, Response: Data(http.StatusOK, config.Config{})},
+		{Method: http.MethodGet, Path: "/admin/log-level", Tag: "admin", Summary: "ログレベルの確認",
+			Security: userAuth, Permission: constants.PermSystemManage, Response: Data(http.StatusOK, dto.LogLevelResponse{})},
+		{Method: http.MethodPut, Path: "/admin/log-level", Tag: "admin", Summary: "ログレベルの変更",
+			Description: "リクエストを受けたインスタンスのみに反映され、再起動するとLOG_LEVELに戻る",
+			Security:    userAuth, Permission: constants.PermSystemManage, Body: dto.UpdateLogLevelInput{},
+			Response: Data(http.StatusOK, dto.LogLevelResponse{}), Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodGet, Path: "/admin/jobs", Tag: "admin", Summary: "定期ジョブの一覧",
+			Security: userAuth, Permission: constants.PermSystemManage, Response: Data(http.StatusOK, []dto.JobResponse{})},
+		{Method: http.MethodGet, Path: "/admin/jobs/:name/runs", Tag: "admin", Summary: "定期ジョブの実行履歴",
+			Security: userAuth, Permission: constants.PermSystemManage, Query: dto.JobRunQuery{},
+			Response: Page([]dto.JobRunResponse{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodPost, Path: "/admin/jobs/:name/run", Tag: "admin", Summary: "定期ジョブの即時実行",
+			Description: "スケジューラーのリースを取得して実行し、終了まで待つ。ジョブの失敗は実行履歴のstatusで返す。Lambdaでは外部のスケジューラーから呼び出す",
+			Security:    userAuth, Permission: constants.PermSystemManage,
+			Response: Data(http.StatusOK, dto.JobRunResponse{}), Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodGet, Path: "/admin/permissions", Tag: "admin", Summary: "権限の一覧",
+			Security: userAuth, Permission: constants.PermRoleManage, Response: Data(http.StatusOK, permissionsSchema)},
+		{Method: http.MethodGet, Path: "/admin/roles", Tag: "admin", Summary: "ロールの一覧",
+			Security: userAuth, Permission: constants.PermRoleManage, Response: Data(http.StatusOK, []dto.RoleResponse{})},
+		{Method: http.MethodPost, Path: "/admin/roles", Tag: "admin", Summary: "ロールの作成",
+			Security: userAuth, Permission: constants.PermRoleManage, Body: dto.CreateRoleInput{},
+			Response: Data(http.StatusCreated, dto.RoleResponse{}), Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+		{Method: http.MethodPut, Path: "/admin/roles/:name", Tag: "admin", Summary: "ロールの更新",
+			Description: "組み込みのロールは変更できない（403）",
+			Security:    userAuth, Permission: constants.PermRoleManage, Body: dto.UpdateRoleInput{},
+			Response: Data(http.StatusOK, dto.RoleResponse{}), Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodDelete, Path: "/admin/roles/:name", Tag: "admin", Summary: "ロールの削除",
+			Description: "組み込みのロールと、ユーザーに割り当てられているロールは削除できない",
+			Security:    userAuth, Permission: constants.PermRoleManage,
+			Response: Empty(http.StatusNoContent), Errors: []int{http.StatusNotFound, http.StatusConflict}},
+
+		// システム
+		{Method: http.MethodGet, Path: "/livez", Tag: "system", Summary: "プロセスが応答できるか（依存先は確認しない）",
+			Response: JSON(http.StatusOK, statusSchema)},
+		{Method: http.MethodGet, Path: "/readyz", Tag: "system", Summary: "リクエストを受け付けられるか（依存先ごとの結果）",
+			Response: JSON(http.StatusOK, dto.HealthResponse{}), Errors: []int{http.StatusServiceUnavailable}},
+		{Method: http.MethodGet, Path: "/debug/health", Tag: "system", Summary: "ヘルスチェックの詳細",
+			Security: userAuth, Permission: constants.PermSystemManage, Response: Data(http.StatusOK, dto.HealthDetailResponse{})},
+		{Method: http.MethodGet, Path: "/metrics", Tag: "system", Summary: "Prometheusのメトリクス",
+			Security: []string{MetricsAuth}, OptionalAuth: true, Response: Content(http.StatusOK, "text/plain; version=0.0.4")},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "system", Summary: "このドキュメント（OpenAPI 3.1）",
+			Response: JSON(http.StatusOK, &Schema{Type: "object"})},
+		{Method: http.MethodGet, Path: "/docs", Tag: "system", Summary: "Swagger UI",
+			Response: Content(http.StatusOK, "text/html")},
+	}
+}
+
+// Spec このAPIのドキュメント
+func Spec() *Document {
+	return Build(Info{
+		Title:       "Gin Fleamarket API",
+		Version:     "1.0.0",
+		Description: "フリマアプリのREST API。エラー時はRFC 7807のapplication/problem+jsonを返し、`code`でエラーの種類を判別できる（`error`は従来のクライアント向けに`detail`と同じ値）",
+	}, tags, Routes())
+}
@@ -0,0 +1,219 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schema JSON Schema（OpenAPI 3.1はJSON Schema 2020-12に準拠する）
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	ContentMediaType     string             `json:"contentMediaType,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// schemaRegistry 構造体はcomponents.schemasに1度だけ登録し、$refで参照する
+type schemaRegistry struct {
+	schemas map[string]*Schema
+}
+
+// ref 構造体の型から、components.schemasへの参照を返す（初めての型は登録する）
+func (r *schemaRegistry) ref(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == deletedAtType:
+		return &Schema{Type: []string{"string", "null"}, Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, exists := r.schemas[name]; !exists {
+			// 自身を参照する型で無限に再帰しないよう、先に登録してから中身を作る
+			schema := &Schema{Type: "object"}
+			r.schemas[name] = schema
+			r.fillStruct(schema, t, isInput(t))
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.ref(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.ref(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	default:
+		return primitive(t)
+	}
+}
+
+// schemaName パッケージ名を付けずに型名を使う（dtoとmodelsで同じ名前の型はない）
+func schemaName(t reflect.Type) string {
+	return t.Name()
+}
+
+func primitive(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{}
+	}
+}
+
+// isInput dtoの命名規則で、リクエストのボディやクエリ（XxxInput / XxxQuery）かどうかを判定する
+func isInput(t reflect.Type) bool {
+	return strings.HasSuffix(t.Name(), "Input") || strings.HasSuffix(t.Name(), "Query")
+}
+
+// fillStruct encoding/jsonと同じ規則でプロパティを作る。埋め込みの構造体（gorm.Modelなど）のフィールドは展開する
+// bindingタグ（go-playground/validator）のrequired / min / max / email / oneofを制約に変換する
+// リクエストではbindingタグのrequiredを、レスポンスではomitemptyでない値型のフィールドを必須にする
+func (r *schemaRegistry) fillStruct(schema *Schema, t reflect.Type, input bool) {
+	if schema.Properties == nil {
+		schema.Properties = make(map[string]*Schema)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && embedded != timeType {
+				r.fillStruct(schema, embedded, input)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := r.ref(field.Type)
+		if field.Type.Kind() == reflect.Pointer {
+			property = nullable(property)
+		}
+		required := applyBinding(property, field.Tag.Get("binding"))
+		if !input {
+			required = !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer
+		}
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// nullable ポインタ型のフィールドはnullを許容する
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+	}
+	if typeName, ok := schema.Type.(string); ok {
+		schema.Type = []string{typeName, "null"}
+	}
+	return schema
+}
+
+// applyBinding bindingタグの制約をスキーマに反映し、必須かどうかを返す
+func applyBinding(schema *Schema, binding string) bool {
+	if binding == "" {
+		return false
+	}
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "oneof":
+			for _, option := range strings.Fields(value) {
+				schema.Enum = append(schema.Enum, option)
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			applyLimit(schema, key, n)
+		}
+	}
+	return required
+}
+
+func applyLimit(schema *Schema, key string, n int) {
+	switch schemaType(schema) {
+	case "string":
+		if key == "min" {
+			schema.MinLength = &n
+		} else {
+			schema.MaxLength = &n
+		}
+	case "array":
+		if key == "min" {
+			schema.MinItems = &n
+		}
+	case "integer", "number":
+		f := float64(n)
+		if key == "min" {
+			schema.Minimum = &f
+		} else {
+			schema.Maximum = &f
+		}
+	}
+}
+
+func schemaType(schema *Schema) string {
+	switch v := schema.Type.(type) {
+	case string:
+		return v
+	case []string:
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,437 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gin-fleamarket/dto"
+)
+
+// Document OpenAPI 3.1のドキュメント（このAPIで使う項目のみ）
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Tags       []Tag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*ResponseObject `json:"responses"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+	Parameters  []Parameter                `json:"parameters,omitempty"`
+	RequestBody *RequestBody               `json:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type ResponseObject struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// セキュリティスキームの名前（Route.Securityに指定する）
+const (
+	BearerAuth    = "bearerAuth"
+	CookieAuth    = "cookieAuth"
+	APIKeyAuth    = "apiKeyAuth"
+	BootstrapAuth = "bootstrapToken"
+	MetricsAuth   = "metricsToken"
+)
+
+// envelope レスポンスのボディの形
+type envelope int
+
+const (
+	// envelopeRaw ボディの型をそのまま返す
+	envelopeRaw envelope = iota
+	// envelopeData {"data": ...}
+	envelopeData
+	// envelopePage {"data": [...], "meta": PageMeta}
+	envelopePage
+)
+
+// Response 成功時のレスポンス。JSON / Data / Page / Empty / Content / Redirectで作る
+type Response struct {
+	Status      int
+	Description string
+	ContentType string
+	Body        any
+	envelope    envelope
+}
+
+// JSON ボディをそのまま返すレスポンス（/auth/loginなど）
+func JSON(status int, body any) Response {
+	return Response{Status: status, ContentType: "application/json", Body: body, envelope: envelopeRaw}
+}
+
+// Data {"data": body}で返すレスポンス（多くのエンドポイント）
+func Data(status int, body any) Response {
+	return Response{Status: status, ContentType: "application/json", Body: body, envelope: envelopeData}
+}
+
+// Page {"data": body, "meta": PageMeta}で返すページ分割されたレスポンス
+func Page(body any) Response {
+	return Response{Status: http.StatusOK, ContentType: "application/json", Body: body, envelope: envelopePage}
+}
+
+// Empty ボディのないレスポンス
+func Empty(status int) Response {
+	return Response{Status: status}
+}
+
+// Content JSON以外のボディ（Prometheusのテキスト形式、HTML、アップロードされたファイルなど）
+func Content(status int, contentType string) Response {
+	return Response{Status: status, ContentType: contentType}
+}
+
+// Redirect Locationヘッダーで別のURLに誘導するレスポンス
+func Redirect(status int, description string) Response {
+	return Response{Status: status, Description: description}
+}
+
+// Route 1つのエンドポイント。Pathはginの形式（/items/:id）で書く
+type Route struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	// Security いずれか1つで認証できればよい。空の場合は認証不要
+	Security []string
+	// OptionalAuth 認証が任意のエンドポイント（未ログインでも呼べる）
+	OptionalAuth bool
+	// Permission RequirePermissionで要求する権限（説明に含め、403を追加する）
+	Permission string
+	// Scope APIキーで呼ぶ場合に要求するスコープ
+	Scope string
+	// Query formタグを持つ構造体からクエリパラメータを作る
+	Query any
+	// QueryParams 構造体を使わずに受け取るクエリパラメータ
+	QueryParams []Parameter
+	// Body リクエストボディの型（または*Schema）。BodyContentTypeの既定はapplication/json
+	Body            any
+	BodyContentType string
+	// BodyOptional ボディを省略できる場合（Cookieのトークンを使う場合など）
+	BodyOptional bool
+	Response     Response
+	// Errors 成功時以外に返しうるステータスコード（401と403は認証・権限の指定から自動で追加する）
+	Errors []int
+}
+
+// problemContentType エラーレスポンスのContent-Type（middlewares.ErrorHandlerが返すRFC 7807の形式）
+const problemContentType = "application/problem+json"
+
+// errorResponses components.responsesに登録するエラーレスポンス
+var errorResponses = map[int]string{
+	http.StatusBadRequest:            "BadRequest",
+	http.StatusUnauthorized:          "Unauthorized",
+	http.StatusForbidden:             "Forbidden",
+	http.StatusNotFound:              "NotFound",
+	http.StatusConflict:              "Conflict",
+	http.StatusRequestEntityTooLarge: "PayloadTooLarge",
+	http.StatusUnsupportedMediaType:  "UnsupportedMediaType",
+	http.StatusInternalServerError:   "InternalServerError",
+	http.StatusBadGateway:            "BadGateway",
+	http.StatusServiceUnavailable:    "ServiceUnavailable",
+}
+
+// Build ルートの一覧からドキュメントを作る
+func Build(info Info, tags []Tag, routes []Route) *Document {
+	registry := &schemaRegistry{schemas: make(map[string]*Schema)}
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Tags:    tags,
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas:         registry.schemas,
+			Responses:       make(map[string]*ResponseObject),
+			SecuritySchemes: securitySchemes(),
+		},
+	}
+
+	errorSchema := registry.ref(reflect.TypeOf(dto.Problem{}))
+	for status, name := range errorResponses {
+		doc.Components.Responses[name] = &ResponseObject{
+			Description: http.StatusText(status),
+			Content:     map[string]*MediaType{problemContentType: {Schema: errorSchema}},
+		}
+	}
+
+	for _, route := range routes {
+		path, parameters := convertPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*Operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = buildOperation(registry, route, parameters)
+	}
+	return doc
+}
+
+// HasOperation ginの形式のパス（/items/:id）のエンドポイントが記載されているか
+func (d *Document) HasOperation(method string, ginPath string) bool {
+	path, _ := convertPath(ginPath)
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// Operations 記載されているエンドポイントを"METHOD /path"（OpenAPIの形式）の一覧で返す
+func (d *Document) Operations() []string {
+	var operations []string
+	for path, item := range d.Paths {
+		for method := range item {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+func securitySchemes() map[string]*SecurityScheme {
+	return map[string]*SecurityScheme{
+		BearerAuth:    {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "POST /auth/loginで取得したアクセストークン"},
+		CookieAuth:    {Type: "apiKey", In: "cookie", Name: "access_token", Description: "Cookie認証モード（?mode=cookie）のアクセストークン。GET以外のリクエストにはX-CSRF-Tokenヘッダーが必要"},
+		APIKeyAuth:    {Type: "apiKey", In: "header", Name: "Authorization", Description: "Authorization: ApiKey <key>（商品APIのみ。スコープが必要）"},
+		BootstrapAuth: {Type: "apiKey", In: "header", Name: "Authorization", Description: "Authorization: Bootstrap <ADMIN_BOOTSTRAP_TOKEN>"},
+		MetricsAuth:   {Type: "http", Scheme: "bearer", Description: "METRICS_TOKENを設定した場合のみ必要"},
+	}
+}
+
+// convertPath /items/:id を /items/{id} に変換し、パスパラメータを返す
+func convertPath(ginPath string) (string, []Parameter) {
+	segments := strings.Split(ginPath, "/")
+	var parameters []Parameter
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+		schema := &Schema{Type: "string"}
+		if name == "id" {
+			schema = &Schema{Type: "integer", Minimum: ptr(1.0)}
+		}
+		parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+func buildOperation(registry *schemaRegistry, route Route, parameters []Parameter) *Operation {
+	operation := &Operation{
+		OperationID: operationID(route),
+		Summary:     route.Summary,
+		Description: route.Description,
+		Parameters:  parameters,
+		Responses:   make(map[string]*ResponseObject),
+	}
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+
+	var notes []string
+	if route.Permission != "" {
+		notes = append(notes, "権限: `"+route.Permission+"`")
+	}
+	if route.Scope != "" {
+		notes = append(notes, "APIキーのスコープ: `"+route.Scope+"`")
+	}
+	if len(notes) > 0 {
+		if operation.Description != "" {
+			notes = append([]string{operation.Description}, notes...)
+		}
+		operation.Description = strings.Join(notes, "\n\n")
+	}
+
+	for _, scheme := range route.Security {
+		operation.Security = append(operation.Security, map[string][]string{scheme: {}})
+	}
+	// 空のオブジェクトは、認証しなくても呼べることを表す
+	if route.OptionalAuth {
+		operation.Security = append(operation.Security, map[string][]string{})
+	}
+
+	if route.Query != nil {
+		operation.Parameters = append(operation.Parameters, queryParameters(registry, reflect.TypeOf(route.Query))...)
+	}
+	operation.Parameters = append(operation.Parameters, route.QueryParams...)
+
+	if route.Body != nil {
+		contentType := route.BodyContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		schema := bodySchema(registry, route.Body)
+		operation.RequestBody = &RequestBody{
+			Required: !route.BodyOptional,
+			Content:  map[string]*MediaType{contentType: {Schema: schema}},
+		}
+	}
+
+	operation.Responses[strconv.Itoa(route.Response.Status)] = successResponse(registry, route.Response)
+
+	errors := append([]int(nil), route.Errors...)
+	if len(route.Security) > 0 {
+		errors = append(errors, http.StatusUnauthorized)
+	}
+	if route.Permission != "" || route.Scope != "" {
+		errors = append(errors, http.StatusForbidden)
+	}
+	errors = append(errors, http.StatusInternalServerError)
+	for _, status := range errors {
+		operation.Responses[strconv.Itoa(status)] = &ResponseObject{Ref: "#/components/responses/" + errorResponses[status]}
+	}
+	return operation
+}
+
+// operationID GET /admin/users/:id → getAdminUsersById
+func operationID(route Route) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(route.Method))
+	for _, segment := range strings.FieldsFunc(route.Path, func(r rune) bool { return r == '/' || r == '-' || r == '.' }) {
+		if segment[0] == ':' || segment[0] == '*' {
+			b.WriteString("By")
+			segment = segment[1:]
+		}
+		b.WriteString(strings.ToUpper(segment[:1]) + segment[1:])
+	}
+	return b.String()
+}
+
+func successResponse(registry *schemaRegistry, response Response) *ResponseObject {
+	object := &ResponseObject{Description: response.Description}
+	if object.Description == "" {
+		object.Description = http.StatusText(response.Status)
+	}
+	if response.Status == http.StatusFound {
+		object.Headers = map[string]*Header{"Location": {Schema: &Schema{Type: "string", Format: "uri"}}}
+	}
+	if response.ContentType == "" {
+		return object
+	}
+
+	var schema *Schema
+	switch {
+	case response.Body == nil:
+		schema = &Schema{Type: "string"}
+		if !strings.HasPrefix(response.ContentType, "text/") {
+			schema.ContentMediaType = response.ContentType
+		}
+	case response.envelope == envelopeData:
+		schema = &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{"data": bodySchema(registry, response.Body)},
+			Required:   []string{"data"},
+		}
+	case response.envelope == envelopePage:
+		schema = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"data": bodySchema(registry, response.Body),
+				"meta": registry.ref(reflect.TypeOf(dto.PageMeta{})),
+			},
+			Required: []string{"data", "meta"},
+		}
+	default:
+		schema = bodySchema(registry, response.Body)
+	}
+	object.Content = map[string]*MediaType{response.ContentType: {Schema: schema}}
+	return object
+}
+
+// anyOf 条件によって異なる形のボディを返す場合（Cookie認証モードのログインなど）
+type anyOf []any
+
+// AnyOf いずれかの型（または*Schema）のボディ
+func AnyOf(bodies ...any) any {
+	return anyOf(bodies)
+}
+
+// bodySchema *Schemaを渡した場合はそのまま使い、それ以外は型からスキーマを作る
+func bodySchema(registry *schemaRegistry, body any) *Schema {
+	switch body := body.(type) {
+	case *Schema:
+		return body
+	case anyOf:
+		schema := &Schema{}
+		for _, b := range body {
+			schema.AnyOf = append(schema.AnyOf, bodySchema(registry, b))
+		}
+		return schema
+	}
+	return registry.ref(reflect.TypeOf(body))
+}
+
+// queryParameters formタグを持つ構造体のフィールドをクエリパラメータにする
+func queryParameters(registry *schemaRegistry, t reflect.Type) []Parameter {
+	var parameters []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("form")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema := registry.ref(field.Type)
+		required := applyBinding(schema, field.Tag.Get("binding"))
+		parameters = append(parameters, Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+	return parameters
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
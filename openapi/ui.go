@@ -0,0 +1,8 @@
+package openapi
+
+import _ "embed"
+
+// SwaggerUI /docsで返すページ。Swagger UI本体（JavaScriptとCSS）はCDNから読み込み、/openapi.jsonを表示する
+//
+//go:embed swagger-ui.html
+var SwaggerUI []byte
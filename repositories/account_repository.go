@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"gin-fleamarket/models"
+
+	"gorm.io/gorm"
+)
+
+// IAccountRepository 本人によるデータのエクスポートと退会処理
+type IAccountRepository interface {
+	FindSessions(userID uint) (*[]models.RefreshSession, error)
+	FindIdentities(userID uint) (*[]models.UserIdentity, error)
+	FindAuditLogs(userID uint) (*[]models.AuditLog, error)
+	Anonymize(userID uint, updates map[string]interface{}) (bool, error)
+}
+
+type AccountRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRepository(db *gorm.DB) IAccountRepository {
+	return &AccountRepository{db: db}
+}
+
+// FindSessions 失効・期限切れのものも含めてすべて返す
+func (r *AccountRepository) FindSessions(userID uint) (*[]models.RefreshSession, error) {
+	var sessions []models.RefreshSession
+	result := r.db.Where("user_id = ?", userID).Order("id").Find(&sessions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &sessions, nil
+}
+
+func (r *AccountRepository) FindIdentities(userID uint) (*[]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	result := r.db.Where("user_id = ?", userID).Order("id").Find(&identities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &identities, nil
+}
+
+// FindAuditLogs ユーザーが操作した、または操作の対象になった監査ログを返す
+func (r *AccountRepository) FindAuditLogs(userID uint) (*[]models.AuditLog, error) {
+	var entries []models.AuditLog
+	result := r.db.Where("actor_id = ? OR target_id = ?", userID, userID).Order("id").Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &entries, nil
+}
+
+// Anonymize ユーザーを匿名化し、個人に紐づくデータを1つのトランザクションで削除する
+// 売れた商品は購入者の取引履歴として残し、出品中の商品だけを取り下げる
+// 最後の有効な管理者の場合は何もせずにfalseを返す
+func (r *AccountRepository) Anonymize(userID uint, updates map[string]interface{}) (bool, error) {
+	anonymized := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAdmins(tx); err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		lastAdmin, err := removesLastAdmin(tx, &user, updates)
+		if err != nil || lastAdmin {
+			return err
+		}
+
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND sold_out = ?", userID, false).Delete(&models.Item{}).Error; err != nil {
+			return err
+		}
+		// セッションにはIPアドレスとUser-Agentが含まれるため、失効ではなく削除する
+		// 削除されたファミリーのリフレッシュトークンは以後受け付けられない
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RefreshSession{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		// IPアドレスとUser-Agentはハッシュに含まれないため、チェーンを壊さずに消去できる
+		// 対象になっただけの行のIPアドレスとUser-Agentは操作した管理者のものなので残す
+		scrub := map[string]interface{}{"ip_address": "", "user_agent": ""}
+		if err := tx.Model(&models.AuditLog{}).Where("actor_id = ?", userID).Updates(scrub).Error; err != nil {
+			return err
+		}
+		anonymized = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return anonymized, nil
+}
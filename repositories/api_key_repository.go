@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"gin-fleamarket/models"
+
+	"gorm.io/gorm"
+)
+
+type IAPIKeyRepository interface {
+	Create(apiKey models.APIKey) (*models.APIKey, error)
+	FindByHash(keyHash string) (*models.APIKey, error)
+	FindByUserID(userID uint) (*[]models.APIKey, error)
+	UpdateLastUsedAt(apiKeyID uint, lastUsedAt int64) error
+	Delete(apiKeyID uint, userID uint) error
+}
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) IAPIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Create(apiKey models.APIKey) (*models.APIKey, error) {
+	result := r.db.Create(&apiKey)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &apiKey, nil
+}
+
+func (r *APIKeyRepository) FindByHash(keyHash string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	result := r.db.First(&apiKey, "key_hash = ?", keyHash)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &apiKey, nil
+}
+
+func (r *APIKeyRepository) FindByUserID(userID uint) (*[]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	result := r.db.Where("user_id = ?", userID).Order("id").Find(&apiKeys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &apiKeys, nil
+}
+
+func (r *APIKeyRepository) UpdateLastUsedAt(apiKeyID uint, lastUsedAt int64) error {
+	result := r.db.Model(&models.APIKey{}).Where("id = ?", apiKeyID).Update("last_used_at", lastUsedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) Delete(apiKeyID uint, userID uint) error {
+	result := r.db.Delete(&models.APIKey{}, "id = ? AND user_id = ?", apiKeyID, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 監査ログの追記を直列化するためのアドバイザリーロックのキー
+const auditLogLockID = 7_301_002
+
+type IAuditLogRepository interface {
+	Append(entry models.AuditLog, hash func(entry *models.AuditLog) string) error
+	Search(query dto.AuditLogQuery) (*[]models.AuditLog, int64, error)
+	FindAfter(lastID uint, limit int) (*[]models.AuditLog, error)
+}
+
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) IAuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+// Append 直前の行のハッシュを取得してから追記するまでを1つのトランザクションで行う
+// 同時に追記された場合でもチェーンが分岐しないよう、PostgreSQLではアドバイザリーロックで直列化する
+func (r *AuditLogRepository) Append(entry models.AuditLog, hash func(entry *models.AuditLog) string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLogLockID).Error; err != nil {
+				return err
+			}
+		}
+
+		var last models.AuditLog
+		result := tx.Order("id DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		entry.PrevHash = last.Hash
+		entry.Hash = hash(&entry)
+		return tx.Create(&entry).Error
+	})
+}
+
+// Search PageとPerPageは呼び出し側で既定値を設定しておくこと
+func (r *AuditLogRepository) Search(query dto.AuditLogQuery) (*[]models.AuditLog, int64, error) {
+	db := r.db.Model(&models.AuditLog{})
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.ActorID != 0 {
+		db = db.Where("actor_id = ?", query.ActorID)
+	}
+	if query.TargetID != 0 {
+		db = db.Where("target_id = ?", query.TargetID)
+	}
+	if query.IP != "" {
+		db = db.Where("ip_address = ?", query.IP)
+	}
+	if query.From != 0 {
+		db = db.Where("created_at >= ?", time.Unix(query.From, 0))
+	}
+	if query.To != 0 {
+		db = db.Where("created_at < ?", time.Unix(query.To, 0))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.AuditLog
+	result := db.Order("id DESC").
+		Offset((query.Page - 1) * query.PerPage).
+		Limit(query.PerPage).
+		Find(&entries)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return &entries, total, nil
+}
+
+// FindAfter チェーンの検証用に、ID順で少しずつ読み出す
+func (r *AuditLogRepository) FindAfter(lastID uint, limit int) (*[]models.AuditLog, error) {
+	var entries []models.AuditLog
+	result := r.db.Where("id > ?", lastID).Order("id").Limit(limit).Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &entries, nil
+}
@@ -1,19 +1,23 @@
 package repositories
 
 import (
-	"errors"
+	"gin-fleamarket/constants"
 	"gin-fleamarket/models"
-	"log"
 
 	"gorm.io/gorm"
 )
 
 type IAuthRepository interface {
-	CreateUser(user models.User) error
+	CreateUser(user models.User) (*models.User, error)
+	UpdatePassword(userID uint, hashedPassword string) error
 	FindUser(email string) (*models.User, error)
-	CountUsers() (int64, error)
+	FindUserByID(userID uint) (*models.User, error)
+	CreateFirstAdmin(user models.User) (bool, error)
 }
 
+// 初期管理者の作成を直列化するためのアドバイザリーロックのキー
+const adminBootstrapLockID = 7_301_001
+
 type AuthRepository struct {
 	db *gorm.DB
 }
@@ -22,11 +26,22 @@ func NewAuthRepository(db *gorm.DB) IAuthRepository {
 	return &AuthRepository{db: db}
 }
 
-func (r *AuthRepository) CreateUser(user models.User) error {
+func (r *AuthRepository) CreateUser(user models.User) (*models.User, error) {
 	result := r.db.Create(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+func (r *AuthRepository) UpdatePassword(userID uint, hashedPassword string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword)
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
 	return nil
 }
 
@@ -34,20 +49,48 @@ func (r *AuthRepository) FindUser(email string) (*models.User, error) {
 	var user models.User
 	result := r.db.First(&user, "email = ?", email)
 	if result.Error != nil {
-		if result.Error.Error() == "record not found" {
-			return nil, errors.New("User not found")
-		}
 		return nil, result.Error
 	}
 	return &user, nil
 }
 
-func (r *AuthRepository) CountUsers() (int64, error) {
-	var count int64
-	result := r.db.Model(&models.User{}).Count(&count)
+func (r *AuthRepository) FindUserByID(userID uint) (*models.User, error) {
+	var user models.User
+	result := r.db.First(&user, "id = ?", userID)
 	if result.Error != nil {
-		return 0, result.Error
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// CreateFirstAdmin 管理者が1人もいない場合のみ管理者ユーザーを作成する
+// 同時に実行された場合でも作成されるのは1人だけになるよう、確認と作成を同じトランザクションで行う
+func (r *AuthRepository) CreateFirstAdmin(user models.User) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// PostgreSQLのREAD COMMITTEDでは件数の確認だけでは競合を防げないため、トランザクション終了まで保持されるロックで直列化する
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", adminBootstrapLockID).Error; err != nil {
+				return err
+			}
+		}
+
+		var adminCount int64
+		if err := tx.Model(&models.User{}).Where("role = ?", constants.RoleAdmin).Count(&adminCount).Error; err != nil {
+			return err
+		}
+		if adminCount > 0 {
+			return nil
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		return false, err
 	}
-	log.Printf("CountUsers: Found %d users in database", count)
-	return count, nil
+	return created, nil
 }
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+type IHealthRepository interface {
+	Ping(ctx context.Context) error
+	PoolStats() (sql.DBStats, error)
+}
+
+type HealthRepository struct {
+	db *gorm.DB
+}
+
+func NewHealthRepository(db *gorm.DB) IHealthRepository {
+	return &HealthRepository{db: db}
+}
+
+func (r *HealthRepository) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (r *HealthRepository) PoolStats() (sql.DBStats, error) {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
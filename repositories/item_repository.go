@@ -1,33 +1,42 @@
 package repositories
 
 import (
+	"context"
 	"gin-fleamarket/models"
+	"gin-fleamarket/tracing"
 
 	"gorm.io/gorm"
 )
 
+// IItemRepository ctxはリクエストのcontext（クエリのスパンの親になる）
 type IItemRepository interface {
-	FindAll() (*[]models.Item, error)
-	FindById(itemID uint, userID uint) (*models.Item, error)
-	Create(newItem models.Item) (*models.Item, error)
-	Update(itemID uint, userID uint, updates map[string]interface{}) (*models.Item, error)
-	Delete(itemID uint) error
+	FindAll(ctx context.Context) (*[]models.Item, error)
+	FindById(ctx context.Context, itemID uint) (*models.Item, error)
+	Create(ctx context.Context, newItem models.Item) (*models.Item, error)
+	Update(ctx context.Context, itemID uint, updates map[string]interface{}) (*models.Item, error)
+	Delete(ctx context.Context, itemID uint) error
 }
 
 type ItemRepository struct {
 	db *gorm.DB
 }
 
-func (r *ItemRepository) Create(newItem models.Item) (*models.Item, error) {
-	result := r.db.Create(&newItem)
+func (r *ItemRepository) Create(ctx context.Context, newItem models.Item) (_ *models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemRepository.Create")
+	defer tracing.Finish(span, &err)
+
+	result := r.db.WithContext(ctx).Create(&newItem)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &newItem, nil
 }
 
-func (r *ItemRepository) Delete(itemID uint) error {
-	result := r.db.Delete(&models.Item{}, "id = ?", itemID)
+func (r *ItemRepository) Delete(ctx context.Context, itemID uint) (err error) {
+	ctx, span := tracing.Start(ctx, "ItemRepository.Delete")
+	defer tracing.Finish(span, &err)
+
+	result := r.db.WithContext(ctx).Delete(&models.Item{}, "id = ?", itemID)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -37,27 +46,36 @@ func (r *ItemRepository) Delete(itemID uint) error {
 	return nil
 }
 
-func (r *ItemRepository) FindAll() (*[]models.Item, error) {
+func (r *ItemRepository) FindAll(ctx context.Context) (_ *[]models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemRepository.FindAll")
+	defer tracing.Finish(span, &err)
+
 	var items []models.Item
-	result := r.db.Find(&items)
+	result := r.db.WithContext(ctx).Find(&items)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &items, nil
 }
 
-func (r *ItemRepository) FindById(itemID uint, userID uint) (*models.Item, error) {
+func (r *ItemRepository) FindById(ctx context.Context, itemID uint) (_ *models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemRepository.FindById")
+	defer tracing.Finish(span, &err)
+
 	var item models.Item
-	result := r.db.First(&item, "id = ? AND user_id = ?", itemID, userID)
+	result := r.db.WithContext(ctx).First(&item, "id = ?", itemID)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &item, nil
 }
 
-func (r *ItemRepository) Update(itemID uint, userID uint, updates map[string]interface{}) (*models.Item, error) {
-	result := r.db.Model(&models.Item{}).
-		Where("id = ? AND user_id = ?", itemID, userID).
+func (r *ItemRepository) Update(ctx context.Context, itemID uint, updates map[string]interface{}) (_ *models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemRepository.Update")
+	defer tracing.Finish(span, &err)
+
+	result := r.db.WithContext(ctx).Model(&models.Item{}).
+		Where("id = ?", itemID).
 		Updates(updates)
 
 	if result.Error != nil {
@@ -69,7 +87,7 @@ func (r *ItemRepository) Update(itemID uint, userID uint, updates map[string]int
 	}
 
 	var updatedItem models.Item
-	if err := r.db.First(&updatedItem, "id = ?", itemID).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&updatedItem, "id = ?", itemID).Error; err != nil {
 		return nil, err
 	}
 
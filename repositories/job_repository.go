@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"gin-fleamarket/constants"
+	"gin-fleamarket/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IJobRepository interface {
+	AcquireLease(name string, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(name string, holder string) error
+	StartRun(jobName string, holder string, startedAt time.Time) (*models.JobRun, error)
+	FinishRun(run *models.JobRun, finishedAt time.Time, runErr error) error
+	FindLastRun(jobName string) (*models.JobRun, error)
+	FindLastFailure(jobName string) (*models.JobRun, error)
+	FindRuns(jobName string, page int, perPage int) (*[]models.JobRun, int64, error)
+	DeleteRunsBefore(before time.Time) error
+}
+
+type JobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) IJobRepository {
+	return &JobRepository{db: db}
+}
+
+// AcquireLease リースが空いているか、自分が保持している場合にリースを取得（更新）してtrueを返す
+// 条件付きのUPDATEとON CONFLICT DO NOTHINGのINSERTはそれぞれ原子的に実行されるため、
+// 同時に複数のインスタンスが取得しようとしても成功するのは1つだけになる
+func (r *JobRepository) AcquireLease(name string, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl).Unix()
+
+	result := r.db.Model(&models.JobLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now.Unix()).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	result = r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.JobLease{
+		Name:      name,
+		Holder:    holder,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseLease シャットダウン時に呼び、他のインスタンスがリースの期限切れを待たずに引き継げるようにする
+func (r *JobRepository) ReleaseLease(name string, holder string) error {
+	result := r.db.Where("name = ? AND holder = ?", name, holder).Delete(&models.JobLease{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *JobRepository) StartRun(jobName string, holder string, startedAt time.Time) (*models.JobRun, error) {
+	run := models.JobRun{
+		JobName:   jobName,
+		Holder:    holder,
+		StartedAt: startedAt,
+		Status:    constants.JobStatusRunning,
+	}
+	result := r.db.Create(&run)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &run, nil
+}
+
+// FinishRun 結果をrunにも反映する
+func (r *JobRepository) FinishRun(run *models.JobRun, finishedAt time.Time, runErr error) error {
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Status = constants.JobStatusSucceeded
+	if runErr != nil {
+		run.Status = constants.JobStatusFailed
+		run.Error = runErr.Error()
+	}
+	result := r.db.Model(run).Select("finished_at", "duration_ms", "status", "error").Updates(run)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *JobRepository) FindLastRun(jobName string) (*models.JobRun, error) {
+	var run models.JobRun
+	result := r.db.Where("job_name = ?", jobName).Order("started_at DESC, id DESC").First(&run)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &run, nil
+}
+
+func (r *JobRepository) FindLastFailure(jobName string) (*models.JobRun, error) {
+	var run models.JobRun
+	result := r.db.Where("job_name = ? AND status = ?", jobName, constants.JobStatusFailed).
+		Order("started_at DESC, id DESC").
+		First(&run)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &run, nil
+}
+
+func (r *JobRepository) FindRuns(jobName string, page int, perPage int) (*[]models.JobRun, int64, error) {
+	db := r.db.Model(&models.JobRun{}).Where("job_name = ?", jobName)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var runs []models.JobRun
+	result := db.Order("started_at DESC, id DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&runs)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return &runs, total, nil
+}
+
+func (r *JobRepository) DeleteRunsBefore(before time.Time) error {
+	result := r.db.Where("started_at < ? AND status <> ?", before, constants.JobStatusRunning).Delete(&models.JobRun{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"database/sql"
+	"gin-fleamarket/models"
+
+	"gorm.io/gorm"
+)
+
+// IMetricsRepository /metricsの出力時に、その時点の件数やコネクションプールの状態を読み取る
+type IMetricsRepository interface {
+	CountActiveListings() (int64, error)
+	PoolStats() (sql.DBStats, error)
+}
+
+type MetricsRepository struct {
+	db *gorm.DB
+}
+
+func NewMetricsRepository(db *gorm.DB) IMetricsRepository {
+	return &MetricsRepository{db: db}
+}
+
+// CountActiveListings 売り切れていない（削除されていない）商品の数
+func (r *MetricsRepository) CountActiveListings() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Item{}).Where("sold_out = ?", false).Count(&count).Error
+	return count, err
+}
+
+func (r *MetricsRepository) PoolStats() (sql.DBStats, error) {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
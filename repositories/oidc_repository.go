@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"gin-fleamarket/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IOIDCRepository interface {
+	SaveLoginState(loginState models.OIDCLoginState) error
+	ConsumeLoginState(state string) (*models.OIDCLoginState, error)
+	DeleteExpiredLoginStates() error
+	FindIdentity(provider string, subject string) (*models.UserIdentity, error)
+	FindUserByID(userID uint) (*models.User, error)
+	LinkIdentity(identity models.UserIdentity) error
+	CreateUserWithIdentity(user models.User, identity models.UserIdentity) (*models.User, error)
+}
+
+type OIDCRepository struct {
+	db *gorm.DB
+}
+
+func NewOIDCRepository(db *gorm.DB) IOIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+func (r *OIDCRepository) SaveLoginState(loginState models.OIDCLoginState) error {
+	result := r.db.Create(&loginState)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// ConsumeLoginState stateを取得すると同時に削除し、同じstateでの再利用を防ぐ
+func (r *OIDCRepository) ConsumeLoginState(state string) (*models.OIDCLoginState, error) {
+	var loginState models.OIDCLoginState
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&loginState, "state = ? AND expires_at >= ?", state, time.Now().Unix()).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&models.OIDCLoginState{}, "id = ?", loginState.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loginState, nil
+}
+
+// DeleteExpiredLoginStates ログインを完了しなかった認可リクエストのstateを削除する
+func (r *OIDCRepository) DeleteExpiredLoginStates() error {
+	result := r.db.Unscoped().Where("expires_at < ?", time.Now().Unix()).Delete(&models.OIDCLoginState{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *OIDCRepository) FindIdentity(provider string, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	result := r.db.First(&identity, "provider = ? AND subject = ?", provider, subject)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &identity, nil
+}
+
+func (r *OIDCRepository) FindUserByID(userID uint) (*models.User, error) {
+	var user models.User
+	result := r.db.First(&user, "id = ?", userID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+func (r *OIDCRepository) LinkIdentity(identity models.UserIdentity) error {
+	result := r.db.Create(&identity)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *OIDCRepository) CreateUserWithIdentity(user models.User, identity models.UserIdentity) (*models.User, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"gin-fleamarket/models"
+
+	"gorm.io/gorm"
+)
+
+type IRoleRepository interface {
+	FindAll() (*[]models.Role, error)
+	FindByName(name string) (*models.Role, error)
+	Create(role models.Role) (*models.Role, error)
+	Update(role *models.Role, updates map[string]interface{}, permissions *[]models.Permission) error
+	Delete(name string) error
+	CountUsersWithRole(name string) (int64, error)
+	FindAllPermissions() (*[]models.Permission, error)
+	FindPermissionsByName(names []string) (*[]models.Permission, error)
+	EnsurePermission(permission models.Permission) error
+	EnsureRole(role models.Role) error
+}
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) IRoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) FindAll() (*[]models.Role, error) {
+	var roles []models.Role
+	result := r.db.Preload("Permissions").Order("id").Find(&roles)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &roles, nil
+}
+
+func (r *RoleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	result := r.db.Preload("Permissions").First(&role, "name = ?", name)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &role, nil
+}
+
+func (r *RoleRepository) Create(role models.Role) (*models.Role, error) {
+	result := r.db.Create(&role)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &role, nil
+}
+
+// Update permissionsがnilの場合は権限の割り当てを変更しない
+func (r *RoleRepository) Update(role *models.Role, updates map[string]interface{}, permissions *[]models.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(role).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if permissions != nil {
+			if err := tx.Model(role).Association("Permissions").Replace(*permissions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *RoleRepository) Delete(name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.First(&role, "name = ?", name).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&role).Error
+	})
+}
+
+func (r *RoleRepository) CountUsersWithRole(name string) (int64, error) {
+	var count int64
+	result := r.db.Model(&models.User{}).Where("role = ?", name).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+func (r *RoleRepository) FindAllPermissions() (*[]models.Permission, error) {
+	var permissions []models.Permission
+	result := r.db.Order("name").Find(&permissions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &permissions, nil
+}
+
+func (r *RoleRepository) FindPermissionsByName(names []string) (*[]models.Permission, error) {
+	var permissions []models.Permission
+	if len(names) == 0 {
+		return &permissions, nil
+	}
+	result := r.db.Where("name IN ?", names).Find(&permissions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &permissions, nil
+}
+
+// EnsurePermission 権限が未登録の場合のみ作成する
+func (r *RoleRepository) EnsurePermission(permission models.Permission) error {
+	result := r.db.Where("name = ?", permission.Name).Attrs(permission).FirstOrCreate(&permission)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// EnsureRole ロールが未登録の場合のみ、指定された権限付きで作成する
+// 既存のロールの権限は管理者が編集している可能性があるため変更しない
+func (r *RoleRepository) EnsureRole(role models.Role) error {
+	var count int64
+	if err := r.db.Model(&models.Role{}).Where("name = ?", role.Name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return r.db.Create(&role).Error
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"gin-fleamarket/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ISessionRepository interface {
+	Create(session models.RefreshSession) error
+	FindByFamilyID(familyID string) (*models.RefreshSession, error)
+	FindActiveByUserID(userID uint) (*[]models.RefreshSession, error)
+	Rotate(familyID string, currentJTI string, newJTI string, ipAddress string, userAgent string, expiresAt int64) (bool, error)
+	Revoke(familyID string) error
+	RevokeByID(sessionID uint, userID uint) error
+	RevokeAllByUserID(userID uint) error
+	DeleteExpired() error
+}
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) ISessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Create(session models.RefreshSession) error {
+	result := r.db.Create(&session)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *SessionRepository) FindByFamilyID(familyID string) (*models.RefreshSession, error) {
+	var session models.RefreshSession
+	result := r.db.First(&session, "family_id = ?", familyID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &session, nil
+}
+
+func (r *SessionRepository) FindActiveByUserID(userID uint) (*[]models.RefreshSession, error) {
+	var sessions []models.RefreshSession
+	result := r.db.Where("user_id = ? AND revoked_at = 0 AND expires_at >= ?", userID, time.Now().Unix()).
+		Order("last_used_at DESC").
+		Find(&sessions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &sessions, nil
+}
+
+// Rotate currentJTIが最新の場合のみjtiを差し替える
+// 同じリフレッシュトークンが並行して使われた場合、更新できるのは1つだけになる
+func (r *SessionRepository) Rotate(familyID string, currentJTI string, newJTI string, ipAddress string, userAgent string, expiresAt int64) (bool, error) {
+	result := r.db.Model(&models.RefreshSession{}).
+		Where("family_id = ? AND current_jti = ? AND revoked_at = 0", familyID, currentJTI).
+		Updates(map[string]interface{}{
+			"current_jti":  newJTI,
+			"ip_address":   ipAddress,
+			"user_agent":   userAgent,
+			"last_used_at": time.Now().Unix(),
+			"expires_at":   expiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *SessionRepository) Revoke(familyID string) error {
+	result := r.db.Model(&models.RefreshSession{}).
+		Where("family_id = ? AND revoked_at = 0", familyID).
+		Update("revoked_at", time.Now().Unix())
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeByID(sessionID uint, userID uint) error {
+	result := r.db.Model(&models.RefreshSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at = 0", sessionID, userID).
+		Update("revoked_at", time.Now().Unix())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeAllByUserID(userID uint) error {
+	result := r.db.Model(&models.RefreshSession{}).
+		Where("user_id = ? AND revoked_at = 0", userID).
+		Update("revoked_at", time.Now().Unix())
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// DeleteExpired 期限切れのセッションを削除する。期限切れのリフレッシュトークンはセッションがなくても拒否される
+func (r *SessionRepository) DeleteExpired() error {
+	result := r.db.Unscoped().Where("expires_at < ?", time.Now().Unix()).Delete(&models.RefreshSession{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"gin-fleamarket/models"
+
+	"gorm.io/gorm"
+)
+
+type ISigningKeyRepository interface {
+	FindUsable(retiredAfter int64) (*[]models.SigningKey, error)
+	Rotate(newKey models.SigningKey, retiredAt int64) error
+	DeleteRetiredBefore(retiredBefore int64) error
+}
+
+type SigningKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) ISigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// FindUsable 現在の署名鍵と、指定時刻より後に退役した検証用の鍵を新しい順に返す
+func (r *SigningKeyRepository) FindUsable(retiredAfter int64) (*[]models.SigningKey, error) {
+	var keys []models.SigningKey
+	result := r.db.Where("retired_at = 0 OR retired_at > ?", retiredAfter).Order("id DESC").Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &keys, nil
+}
+
+// Rotate 既存の署名鍵を退役させて新しい鍵を登録する
+func (r *SigningKeyRepository) Rotate(newKey models.SigningKey, retiredAt int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SigningKey{}).Where("retired_at = 0").Update("retired_at", retiredAt).Error; err != nil {
+			return err
+		}
+		return tx.Create(&newKey).Error
+	})
+}
+
+func (r *SigningKeyRepository) DeleteRetiredBefore(retiredBefore int64) error {
+	result := r.db.Unscoped().Where("retired_at > 0 AND retired_at < ?", retiredBefore).Delete(&models.SigningKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
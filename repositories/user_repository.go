@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// adminCountLockID 管理者の降格・停止・退会を直列化するアドバイザリーロックのキー
+const adminCountLockID = 7_301_004
+
+type IUserRepository interface {
+	Search(query dto.UserSearchQuery) (*[]models.User, int64, error)
+	FindByID(userID uint) (*models.User, error)
+	FindItems(userID uint) (*[]models.Item, error)
+	Update(userID uint, updates map[string]interface{}) error
+	UpdateKeepingAdmin(userID uint, updates map[string]interface{}) (bool, error)
+}
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) IUserRepository {
+	return &UserRepository{db: db}
+}
+
+// Search PageとPerPageは呼び出し側で既定値を設定しておくこと
+func (r *UserRepository) Search(query dto.UserSearchQuery) (*[]models.User, int64, error) {
+	db := r.db.Model(&models.User{})
+	if q := strings.TrimSpace(query.Query); q != "" {
+		db = db.Where("LOWER(email) LIKE ?", "%"+strings.ToLower(q)+"%")
+	}
+	if query.Role != "" {
+		db = db.Where("role = ?", query.Role)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	result := db.Order("id").
+		Offset((query.Page - 1) * query.PerPage).
+		Limit(query.PerPage).
+		Find(&users)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return &users, total, nil
+}
+
+func (r *UserRepository) FindByID(userID uint) (*models.User, error) {
+	var user models.User
+	result := r.db.First(&user, "id = ?", userID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) FindItems(userID uint) (*[]models.Item, error) {
+	var items []models.Item
+	result := r.db.Where("user_id = ?", userID).Order("id").Find(&items)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &items, nil
+}
+
+func (r *UserRepository) Update(userID uint, updates map[string]interface{}) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateKeepingAdmin 更新によって有効な管理者が1人もいなくなる場合は更新せずにfalseを返す
+// 同時に2人の管理者を降格させても両方が「他に管理者がいる」と判定しないよう、lockAdminsで直列化してから数え上げと更新を行う
+func (r *UserRepository) UpdateKeepingAdmin(userID uint, updates map[string]interface{}) (bool, error) {
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAdmins(tx); err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		lastAdmin, err := removesLastAdmin(tx, &user, updates)
+		if err != nil || lastAdmin {
+			return err
+		}
+
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		updated = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return updated, nil
+}
+
+// lockAdmins 管理者の数え上げから更新までをトランザクション終了まで保持されるロックで直列化する
+// PostgreSQLのREAD COMMITTEDでは同じトランザクション内で数えても他のトランザクションの降格を見落とすため必要になる
+// SQLiteは書き込みがデータベース単位で直列化されるためロックしない
+func lockAdmins(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", adminCountLockID).Error
+}
+
+// removesLastAdmin 更新によって有効な管理者が1人もいなくなるかを返す（lockAdminsを取得したトランザクション内で呼ぶこと）
+func removesLastAdmin(tx *gorm.DB, user *models.User, updates map[string]interface{}) (bool, error) {
+	if !isActiveAdmin(user.Role, user.Status) || isActiveAdmin(stringOr(updates["role"], user.Role), stringOr(updates["status"], user.Status)) {
+		return false, nil
+	}
+
+	var otherAdmins int64
+	err := tx.Model(&models.User{}).
+		Where("role = ? AND status = ? AND id <> ?", constants.RoleAdmin, constants.UserStatusActive, user.ID).
+		Count(&otherAdmins).Error
+	if err != nil {
+		return false, err
+	}
+	return otherAdmins == 0, nil
+}
+
+func isActiveAdmin(role string, status string) bool {
+	return role == constants.RoleAdmin && status == constants.UserStatusActive
+}
+
+func stringOr(value interface{}, fallback string) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fallback
+}
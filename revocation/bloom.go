@@ -0,0 +1,214 @@
+package revocation
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	bloomFalsePositiveRate = 0.01
+	// 失効済みのトークンが少ない間も、再構築の直後に追加される分を吸収できるようにする
+	bloomMinCapacity = 1024
+	// bloomRebuildInterval 差分更新で増えた期限切れのjtiを取り除くため、この間隔でListActiveから作り直す
+	bloomRebuildInterval = 5 * time.Minute
+	// bloomClockSkew 失効時刻は失効させたインスタンスの時計で記録されるため、差分はこの分だけ遡って取得する
+	bloomClockSkew = 30 * time.Second
+)
+
+// bloomFilter 失効済みのjtiの集合を表す。含まれないことだけを確実に判定できる
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+	// capacity 誤検出率を保てる要素数。addした数がこれを超えたら作り直す
+	capacity int
+	count    int
+}
+
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	if capacity < bloomMinCapacity {
+		capacity = bloomMinCapacity
+	}
+	n := float64(capacity)
+	size := uint64(math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(1, math.Round(float64(size)/n*math.Ln2)))
+	return &bloomFilter{
+		bits:     make([]uint64, (size+63)/64),
+		size:     size,
+		hashes:   hashes,
+		capacity: capacity,
+	}
+}
+
+func (f *bloomFilter) add(value string) {
+	f.count++
+	h1, h2 := bloomHash(value)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *bloomFilter) mayContain(value string) bool {
+	h1, h2 := bloomHash(value)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash 64ビットのFNV-1aを2つに分け、k個のハッシュ値を h1 + i*h2 で作る（Kirsch–Mitzenmacher）
+func bloomHash(value string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	sum := h.Sum64()
+	return sum & 0xffffffff, sum>>32 | 1
+}
+
+// BloomFilteredStore 失効していないトークン（ほぼすべてのリクエスト）の確認をローカルのブルームフィルタで済ませ、
+// フィルタに含まれる可能性がある場合だけ内側のストアに問い合わせる
+//
+// フィルタはsyncIntervalごとにバックグラウンドで、前回の同期以降に失効したjtiだけを取得して更新する（リクエストは待たせない）
+// 他のインスタンスで失効させたトークンが見えない期間を抑えるため、最後の同期からsyncIntervalの2倍を過ぎたフィルタは使わず、
+// 同期に失敗し続けている間や起動直後も含めて、常に内側のストアに問い合わせる
+type BloomFilteredStore struct {
+	store        ITokenRevocationStore
+	syncInterval time.Duration
+
+	mu     sync.RWMutex
+	filter *bloomFilter
+	// syncedAt 最後に同期を始めた時刻。次の差分はこの時刻から取得する
+	syncedAt  time.Time
+	rebuiltAt time.Time
+	// pending 作り直し中にこのインスタンスで失効させたjti（一覧の取得後に追加された分を取りこぼさないため）
+	pending    []string
+	rebuilding bool
+	syncMu     sync.Mutex
+}
+
+func NewBloomFilteredStore(store ITokenRevocationStore, syncInterval time.Duration) ITokenRevocationStore {
+	return &BloomFilteredStore{store: store, syncInterval: syncInterval}
+}
+
+func (s *BloomFilteredStore) Revoke(jti string, expiresAt int64) error {
+	if err := s.store.Revoke(jti, expiresAt); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.filter != nil {
+		s.filter.add(jti)
+	}
+	if s.rebuilding {
+		s.pending = append(s.pending, jti)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *BloomFilteredStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	filter, age := s.filter, time.Since(s.syncedAt)
+	mayContain := filter == nil || age >= 2*s.syncInterval || filter.mayContain(jti)
+	s.mu.RUnlock()
+
+	if filter == nil || age >= s.syncInterval {
+		s.syncInBackground()
+	}
+	if !mayContain {
+		return false, nil
+	}
+	return s.store.IsRevoked(jti)
+}
+
+func (s *BloomFilteredStore) ListActive() ([]string, error) {
+	return s.store.ListActive()
+}
+
+func (s *BloomFilteredStore) ListRevokedSince(since time.Time) ([]string, error) {
+	return s.store.ListRevokedSince(since)
+}
+
+func (s *BloomFilteredStore) DeleteExpired() error {
+	return s.store.DeleteExpired()
+}
+
+func (s *BloomFilteredStore) Ping() error {
+	return s.store.Ping()
+}
+
+// syncInBackground 同期は1つのゴルーチンだけが行い、同期中に呼ばれた場合は何もしない
+func (s *BloomFilteredStore) syncInBackground() {
+	if !s.syncMu.TryLock() {
+		return
+	}
+	go func() {
+		defer s.syncMu.Unlock()
+		if err := s.sync(); err != nil {
+			slog.Error("Failed to sync token revocation filter", "error", err)
+		}
+	}()
+}
+
+// sync 前回の同期以降の差分をフィルタに追加する。フィルタがない場合や古くなった場合はListActiveから作り直す
+func (s *BloomFilteredStore) sync() error {
+	startedAt := time.Now()
+	s.mu.RLock()
+	filter, syncedAt, rebuiltAt := s.filter, s.syncedAt, s.rebuiltAt
+	s.mu.RUnlock()
+
+	since := syncedAt.Add(-bloomClockSkew)
+	if filter == nil || filter.count > filter.capacity ||
+		startedAt.Sub(rebuiltAt) >= bloomRebuildInterval || startedAt.Sub(since) >= revokedSinceRetention {
+		return s.rebuild(startedAt)
+	}
+
+	jtis, err := s.store.ListRevokedSince(since)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for _, jti := range jtis {
+		s.filter.add(jti)
+	}
+	s.syncedAt = startedAt
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *BloomFilteredStore) rebuild(startedAt time.Time) error {
+	s.mu.Lock()
+	s.rebuilding = true
+	s.pending = nil
+	s.mu.Unlock()
+
+	jtis, err := s.store.ListActive()
+	if err != nil {
+		s.mu.Lock()
+		s.rebuilding = false
+		s.pending = nil
+		s.mu.Unlock()
+		return err
+	}
+	filter := newBloomFilter(len(jtis)*2, bloomFalsePositiveRate)
+	for _, jti := range jtis {
+		filter.add(jti)
+	}
+
+	s.mu.Lock()
+	for _, jti := range s.pending {
+		filter.add(jti)
+	}
+	s.filter = filter
+	s.syncedAt = startedAt
+	s.rebuiltAt = startedAt
+	s.rebuilding = false
+	s.pending = nil
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,23 @@
+package revocation
+
+import "gin-fleamarket/metrics"
+
+// InstrumentedStore 失効の確認回数と、失効済みだった回数をメトリクスに記録する
+type InstrumentedStore struct {
+	ITokenRevocationStore
+	// name メトリクスのstoreラベル（sql / redis / memory）
+	name string
+}
+
+func NewInstrumentedStore(store ITokenRevocationStore, name string) ITokenRevocationStore {
+	return &InstrumentedStore{ITokenRevocationStore: store, name: name}
+}
+
+func (s *InstrumentedStore) IsRevoked(jti string) (bool, error) {
+	metrics.TokenRevocationLookups.Inc(s.name)
+	revoked, err := s.ITokenRevocationStore.IsRevoked(jti)
+	if revoked {
+		metrics.TokenRevocationHits.Inc(s.name)
+	}
+	return revoked, err
+}
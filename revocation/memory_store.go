@@ -0,0 +1,86 @@
+package revocation
+
+import (
+	"sync"
+	"time"
+)
+
+// 期限切れのエントリを掃除する間隔
+const memoryPurgeInterval = time.Minute
+
+// MemoryStore プロセス内のTTL付きキャッシュ。インスタンス間で共有されないため、単一インスタンスの開発環境とテスト用
+type MemoryStore struct {
+	mu        sync.RWMutex
+	entries   map[string]int64
+	revokedAt map[string]time.Time
+	purgedAt  time.Time
+}
+
+func NewMemoryStore() ITokenRevocationStore {
+	return &MemoryStore{entries: make(map[string]int64), revokedAt: make(map[string]time.Time), purgedAt: time.Now()}
+}
+
+func (s *MemoryStore) Revoke(jti string, expiresAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[jti] = expiresAt
+	s.revokedAt[jti] = time.Now()
+	if time.Since(s.purgedAt) >= memoryPurgeInterval {
+		s.purgeLocked()
+	}
+	return nil
+}
+
+func (s *MemoryStore) Ping() error {
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	expiresAt, ok := s.entries[jti]
+	s.mu.RUnlock()
+	return ok && expiresAt >= time.Now().Unix(), nil
+}
+
+func (s *MemoryStore) ListActive() ([]string, error) {
+	now := time.Now().Unix()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jtis := make([]string, 0, len(s.entries))
+	for jti, expiresAt := range s.entries {
+		if expiresAt >= now {
+			jtis = append(jtis, jti)
+		}
+	}
+	return jtis, nil
+}
+
+func (s *MemoryStore) ListRevokedSince(since time.Time) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var jtis []string
+	for jti, revokedAt := range s.revokedAt {
+		if !revokedAt.Before(since) {
+			jtis = append(jtis, jti)
+		}
+	}
+	return jtis, nil
+}
+
+func (s *MemoryStore) DeleteExpired() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeLocked()
+	return nil
+}
+
+func (s *MemoryStore) purgeLocked() {
+	now := time.Now().Unix()
+	for jti, expiresAt := range s.entries {
+		if expiresAt < now {
+			delete(s.entries, jti)
+			delete(s.revokedAt, jti)
+		}
+	}
+	s.purgedAt = time.Now()
+}
@@ -0,0 +1,178 @@
+package revocation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisDialTimeout = 3 * time.Second
+	redisIOTimeout   = 2 * time.Second
+	// 使い終わった接続を保持しておく最大数
+	redisMaxIdleConns = 8
+)
+
+// redisError サーバーが返したエラー応答（-ERR ...）
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient RESP（Redisのプロトコル）を話す最小限のクライアント
+// 認証情報とDB番号は redis://[:password@]host:port[/db] 形式のURLで指定する
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	idle     chan net.Conn
+}
+
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("invalid redis url: %s", u.Redacted())
+	}
+
+	client := &redisClient{addr: u.Host, idle: make(chan net.Conn, redisMaxIdleConns)}
+	if !strings.Contains(u.Host, ":") {
+		client.addr = u.Host + ":6379"
+	}
+	if u.User != nil {
+		client.password, _ = u.User.Password()
+	}
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		client.db, err = strconv.Atoi(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis db: %s", path)
+		}
+	}
+	return client, nil
+}
+
+// do コマンドを送信して応答を返す
+// 応答は string / int64 / []interface{} / nil（Null）のいずれか。エラー応答はredisErrorになる
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := roundTrip(conn, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// 通信エラーの後は応答の途中で止まっている可能性があるため、接続を再利用しない
+		conn.Close()
+		return nil, err
+	}
+
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+func (c *redisClient) conn() (net.Conn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	conn, err := net.DialTimeout("tcp", c.addr, redisDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.password != "" {
+		if _, err := roundTrip(conn, []string{"AUTH", c.password}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := roundTrip(conn, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func roundTrip(conn net.Conn, args []string) (interface{}, error) {
+	if err := conn.SetDeadline(time.Now().Add(redisIOTimeout)); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readReply(bufio.NewReader(conn))
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			value, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
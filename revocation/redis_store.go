@@ -0,0 +1,98 @@
+package revocation
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RedisStore 失効させたjtiを、有効期限をスコアにしたソート済みセット1つで管理する
+// 期限の判定と一覧の取得が同じキーで完結するため、個別のキーとインデックスの不整合が起きない
+// ブルームフィルタの差分更新のため、失効させた時刻をスコアにしたセット（revokedAtKey）にも記録する
+type RedisStore struct {
+	client       *redisClient
+	key          string
+	revokedAtKey string
+}
+
+// NewRedisStore 接続を確認してから返す。keyPrefixは同じRedisを共有する他の用途とキーが衝突しないように付ける
+func NewRedisStore(rawURL string, keyPrefix string) (ITokenRevocationStore, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.do("PING"); err != nil {
+		return nil, err
+	}
+	return &RedisStore{
+		client:       client,
+		key:          keyPrefix + "revoked_tokens",
+		revokedAtKey: keyPrefix + "revoked_tokens:revoked_at",
+	}, nil
+}
+
+// Revoke 失効の判定に使うセットに先に追加し、時刻のセットへの追加に失敗しても失効自体は有効にする
+// 時刻のセットに記録できなかったjtiは、他のインスタンスのフィルタには次の作り直しまで反映されない
+func (s *RedisStore) Revoke(jti string, expiresAt int64) error {
+	if _, err := s.client.do("ZADD", s.key, strconv.FormatInt(expiresAt, 10), jti); err != nil {
+		return err
+	}
+	_, err := s.client.do("ZADD", s.revokedAtKey, strconv.FormatInt(time.Now().Unix(), 10), jti)
+	return err
+}
+
+func (s *RedisStore) Ping() error {
+	_, err := s.client.do("PING")
+	return err
+}
+
+func (s *RedisStore) IsRevoked(jti string) (bool, error) {
+	reply, err := s.client.do("ZSCORE", s.key, jti)
+	if err != nil || reply == nil {
+		return false, err
+	}
+	score, ok := reply.(string)
+	if !ok {
+		return false, fmt.Errorf("redis: unexpected ZSCORE reply %v", reply)
+	}
+	expiresAt, err := strconv.ParseFloat(score, 64)
+	if err != nil {
+		return false, err
+	}
+	return int64(expiresAt) >= time.Now().Unix(), nil
+}
+
+func (s *RedisStore) ListActive() ([]string, error) {
+	return s.rangeByScore(s.key, time.Now().Unix())
+}
+
+func (s *RedisStore) ListRevokedSince(since time.Time) ([]string, error) {
+	return s.rangeByScore(s.revokedAtKey, since.Unix())
+}
+
+// rangeByScore スコアがmin以上のメンバーを返す
+func (s *RedisStore) rangeByScore(key string, min int64) ([]string, error) {
+	reply, err := s.client.do("ZRANGEBYSCORE", key, strconv.FormatInt(min, 10), "+inf")
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected ZRANGEBYSCORE reply %v", reply)
+	}
+	jtis := make([]string, 0, len(values))
+	for _, value := range values {
+		if jti, ok := value.(string); ok {
+			jtis = append(jtis, jti)
+		}
+	}
+	return jtis, nil
+}
+
+func (s *RedisStore) DeleteExpired() error {
+	if _, err := s.client.do("ZREMRANGEBYSCORE", s.key, "-inf", "("+strconv.FormatInt(time.Now().Unix(), 10)); err != nil {
+		return err
+	}
+	_, err := s.client.do("ZREMRANGEBYSCORE", s.revokedAtKey, "-inf", "("+strconv.FormatInt(time.Now().Add(-revokedSinceRetention).Unix(), 10))
+	return err
+}
@@ -0,0 +1,77 @@
+package revocation
+
+import (
+	"gin-fleamarket/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SQLStore メインのDBに保存する。すべてのインスタンスから参照できる
+type SQLStore struct {
+	db *gorm.DB
+}
+
+func NewSQLStore(db *gorm.DB) ITokenRevocationStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Revoke(jti string, expiresAt int64) error {
+	// 同じトークンのログアウトが並行しても失敗しないようにする
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// Ping テーブルがなければ（マイグレーションの未適用など）エラーになる
+func (s *SQLStore) Ping() error {
+	var jtis []string
+	return s.db.Model(&models.RevokedToken{}).Limit(1).Pluck("jti", &jtis).Error
+}
+
+func (s *SQLStore) IsRevoked(jti string) (bool, error) {
+	var count int64
+	result := s.db.Model(&models.RevokedToken{}).
+		Where("jti = ? AND expires_at >= ?", jti, time.Now().Unix()).
+		Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+func (s *SQLStore) ListActive() ([]string, error) {
+	var jtis []string
+	result := s.db.Model(&models.RevokedToken{}).
+		Where("expires_at >= ?", time.Now().Unix()).
+		Pluck("jti", &jtis)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return jtis, nil
+}
+
+func (s *SQLStore) ListRevokedSince(since time.Time) ([]string, error) {
+	var jtis []string
+	result := s.db.Model(&models.RevokedToken{}).
+		Where("created_at >= ?", since).
+		Pluck("jti", &jtis)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return jtis, nil
+}
+
+func (s *SQLStore) DeleteExpired() error {
+	result := s.db.Where("expires_at < ?", time.Now().Unix()).Delete(&models.RevokedToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package revocation
+
+import "time"
+
+// revokedSinceRetention ListRevokedSinceで差分を取得できる期間。これより古い時点からの差分はListActiveで作り直す
+const revokedSinceRetention = 15 * time.Minute
+
+// ITokenRevocationStore 有効期限前に失効させたトークンをjtiで管理する
+// 複数のインスタンスで失効を共有するため、本番ではSQLかRedisの実装を使う
+type ITokenRevocationStore interface {
+	// Revoke expiresAt（UNIX秒）を過ぎたエントリは失効済みとして扱わなくてよい
+	Revoke(jti string, expiresAt int64) error
+	IsRevoked(jti string) (bool, error)
+	// ListActive 期限が切れていない失効済みのjtiをすべて返す（ブルームフィルタの再構築用）
+	ListActive() ([]string, error)
+	// ListRevokedSince since以降に失効させたjtiを返す（ブルームフィルタの差分更新用）。期限切れのjtiを含んでもよい
+	// revokedSinceRetentionより前の失効は含まれないことがある
+	ListRevokedSince(since time.Time) ([]string, error)
+	DeleteExpired() error
+	// Ping ヘルスチェック用。保存先に到達でき、使える状態かを確認する
+	Ping() error
+}
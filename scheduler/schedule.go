@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule ジョブの実行時刻を決める
+type Schedule interface {
+	// Next afterより後の次の実行時刻を返す
+	Next(after time.Time) time.Time
+	String() string
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Every 前回の実行開始から一定間隔で実行する
+func Every(interval time.Duration) Schedule {
+	return intervalSchedule{interval: interval}
+}
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func (s intervalSchedule) String() string {
+	return "every " + s.interval.String()
+}
+
+// cronSchedule 標準的な5フィールドのcron式（分 時 日 月 曜日）
+type cronSchedule struct {
+	expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	anyDom bool
+	anyDow bool
+}
+
+// cronFields 各フィールドの範囲
+var cronFields = []struct {
+	name string
+	min  int
+	max  int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 0と7はどちらも日曜日
+	{"day of week", 0, 7},
+}
+
+// Cron "*/15 * * * *" のようなcron式を解析する
+// 各フィールドで * 、数値、範囲（a-b）、間隔（*/n、a-b/n）、カンマ区切りのリストが使える
+// 時刻はサーバーのタイムゾーンで解釈する
+func Cron(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression must have %d fields: %q", len(cronFields), expr)
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in %q: %v", cronFields[i].name, expr, err)
+		}
+		bits[i] = b
+	}
+	// 曜日の7は日曜日（0）として扱う
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		expr:   expr,
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		anyDom: strings.HasPrefix(fields[2], "*"),
+		anyDow: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// MustCron ジョブの登録時など、式が固定の場合に使う
+func MustCron(expr string) Schedule {
+	schedule, err := Cron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				// "5/15" は5から最大値まで15刻み
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("value out of range %q", part)
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next 条件に合わない単位（月・日・時・分）をまとめて読み飛ばしながら探す
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// 2月29日のような稀な条件でも必ず見つかる範囲で打ち切る
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日と曜日の両方が指定された場合は、cronの慣例どおりどちらかに一致すればよい
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.anyDom || s.anyDow {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s *cronSchedule) String() string {
+	return s.expr
+}
@@ -0,0 +1,328 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// leaseName スケジューラー全体で1つのリースを取り合い、保持しているインスタンスがリーダーになる
+	leaseName = "scheduler"
+	// leaseTTL リーダーが停止した場合に他のインスタンスが引き継ぐまでの時間
+	leaseTTL = 30 * time.Second
+	// tickInterval リースの更新と実行時刻の確認の間隔（leaseTTLより十分短くする）
+	tickInterval   = 5 * time.Second
+	defaultTimeout = 5 * time.Minute
+
+	// HeartbeatTimeout 最後にリースの確認に成功してからこの時間が過ぎた場合、スケジューラーは停止しているとみなす
+	HeartbeatTimeout = 3 * tickInterval
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobBusy 同じジョブを実行中か、他のインスタンスがリースを保持している
+	ErrJobBusy = errors.New("job is running or another instance holds the scheduler lease")
+)
+
+// Job 定期的に実行する処理
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Timeout 省略時は5分。超えた場合はRunに渡したcontextがキャンセルされる
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// IScheduler 複数のレプリカで起動しても、リースを持つ1つのインスタンスだけがジョブを実行する
+type IScheduler interface {
+	Register(job Job)
+	Jobs() []Job
+	// Start ctxがキャンセルされるまでジョブを実行し、実行中のジョブの終了を待ってから戻る
+	Start(ctx context.Context)
+	// Tick リースを取得・更新し、実行時刻を過ぎたジョブを開始する（Startから定期的に呼ばれる）
+	Tick(ctx context.Context) error
+	// Heartbeat 最後にリースの確認（DBへの問い合わせ）に成功した時刻。リーダーでなくても更新される
+	Heartbeat() time.Time
+	IsLeader() bool
+	// RunNow リースを取得してジョブをすぐに実行し、終了まで待って実行履歴を返す
+	// 定期ジョブを実行しない環境（Lambda）では、外部のスケジューラーから管理APIを通して呼び出す
+	RunNow(ctx context.Context, name string) (*models.JobRun, error)
+}
+
+type Scheduler struct {
+	repository repositories.IJobRepository
+	holder     string
+
+	mu        sync.Mutex
+	jobs      []Job
+	nextRun   map[string]time.Time
+	running   map[string]bool
+	leader    bool
+	heartbeat time.Time
+	wg        sync.WaitGroup
+}
+
+func NewScheduler(repository repositories.IJobRepository) IScheduler {
+	return &Scheduler{
+		repository: repository,
+		holder:     newHolderID(),
+		nextRun:    make(map[string]time.Time),
+		running:    make(map[string]bool),
+	}
+}
+
+// newHolderID ログで見分けられるよう、ホスト名にランダムな接尾辞を付ける
+func newHolderID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hostname + "-" + hex.EncodeToString(b)
+}
+
+func (s *Scheduler) Register(job Job) {
+	if job.Timeout == 0 {
+		job.Timeout = defaultTimeout
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+}
+
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Job(nil), s.jobs...)
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	slog.Info("Scheduler started", "holder", s.holder, "jobs", len(s.Jobs()))
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Tick(ctx); err != nil {
+			slog.Error("Scheduler tick failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			if err := s.repository.ReleaseLease(leaseName, s.holder); err != nil {
+				slog.Error("Failed to release scheduler lease", "error", err)
+			}
+			slog.Info("Scheduler stopped", "holder", s.holder)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) Tick(ctx context.Context) error {
+	acquired, err := s.repository.AcquireLease(leaseName, s.holder, leaseTTL)
+	if err != nil {
+		s.setLeader(false)
+		return err
+	}
+	s.setLeader(acquired)
+	s.mu.Lock()
+	s.heartbeat = time.Now()
+	s.mu.Unlock()
+	if !acquired || ctx.Err() != nil {
+		return nil
+	}
+
+	now := time.Now()
+	for _, job := range s.Jobs() {
+		next, err := s.nextRunOf(job, now)
+		if err != nil {
+			slog.Error("Failed to load job history", "job", job.Name, "error", err)
+			continue
+		}
+		if next.After(now) {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.running[job.Name] {
+			s.mu.Unlock()
+			continue
+		}
+		s.running[job.Name] = true
+		s.nextRun[job.Name] = job.Schedule.Next(now)
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.run(ctx, job, now)
+	}
+	return nil
+}
+
+func (s *Scheduler) RunNow(ctx context.Context, name string) (*models.JobRun, error) {
+	var job *Job
+	for _, registered := range s.Jobs() {
+		if registered.Name == name {
+			job = &registered
+			break
+		}
+	}
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+
+	s.mu.Lock()
+	if s.running[job.Name] {
+		s.mu.Unlock()
+		return nil, ErrJobBusy
+	}
+	s.running[job.Name] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running[job.Name] = false
+		s.mu.Unlock()
+	}()
+
+	// 実行中に他のインスタンスがリーダーになって同じジョブを実行しないよう、タイムアウトまでリースを保持する
+	acquired, err := s.repository.AcquireLease(leaseName, s.holder, job.Timeout+leaseTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrJobBusy
+	}
+	defer func() {
+		// リーダーとして定期ジョブを実行している場合は、Tickがリースを更新し続ける
+		if s.IsLeader() {
+			return
+		}
+		if err := s.repository.ReleaseLease(leaseName, s.holder); err != nil {
+			slog.Error("Failed to release scheduler lease", "error", err)
+		}
+	}()
+
+	startedAt := time.Now()
+	s.mu.Lock()
+	if s.leader {
+		s.nextRun[job.Name] = job.Schedule.Next(startedAt)
+	}
+	s.mu.Unlock()
+	return s.execute(ctx, *job, startedAt)
+}
+
+func (s *Scheduler) Heartbeat() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heartbeat
+}
+
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// setLeader リーダーでなくなった場合は、次にリーダーになった時に履歴から実行時刻を計算し直す
+// （その間に他のインスタンスが実行している可能性があるため）
+func (s *Scheduler) setLeader(leader bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if leader && !s.leader {
+		slog.Info("Scheduler became the leader", "holder", s.holder)
+	}
+	if !leader {
+		s.nextRun = make(map[string]time.Time)
+	}
+	s.leader = leader
+}
+
+// NextRun 前回の実行開始時刻から次の実行時刻を求める
+// 一度も実行されていない場合、間隔指定のジョブはすぐに、cron式のジョブは次の該当時刻に実行する
+func NextRun(job Job, lastStartedAt *time.Time, now time.Time) time.Time {
+	if lastStartedAt != nil {
+		return job.Schedule.Next(*lastStartedAt)
+	}
+	if _, interval := job.Schedule.(intervalSchedule); interval {
+		return now
+	}
+	return job.Schedule.Next(now)
+}
+
+func (s *Scheduler) nextRunOf(job Job, now time.Time) (time.Time, error) {
+	s.mu.Lock()
+	next, ok := s.nextRun[job.Name]
+	s.mu.Unlock()
+	if ok {
+		return next, nil
+	}
+
+	var lastStartedAt *time.Time
+	last, err := s.repository.FindLastRun(job.Name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, err
+	}
+	if last != nil {
+		lastStartedAt = &last.StartedAt
+	}
+	next = NextRun(job, lastStartedAt, now)
+
+	s.mu.Lock()
+	s.nextRun[job.Name] = next
+	s.mu.Unlock()
+	return next, nil
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job, startedAt time.Time) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		s.running[job.Name] = false
+		s.mu.Unlock()
+	}()
+
+	if _, err := s.execute(ctx, job, startedAt); err != nil {
+		slog.Error("Failed to record job run", "job", job.Name, "error", err)
+	}
+}
+
+// execute ジョブを実行して結果を記録する。返すエラーは記録の失敗で、ジョブ自体の失敗は実行履歴のStatusに残す
+func (s *Scheduler) execute(ctx context.Context, job Job, startedAt time.Time) (*models.JobRun, error) {
+	run, err := s.repository.StartRun(job.Name, s.holder, startedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+	runErr := runSafely(jobCtx, job)
+	if runErr != nil {
+		slog.Error("Job failed", "job", job.Name, "error", runErr)
+	}
+
+	if err := s.repository.FinishRun(run, time.Now(), runErr); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// runSafely ジョブのpanicでスケジューラーごと停止しないようにする
+func runSafely(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"gin-fleamarket/config"
+	"gin-fleamarket/fixtures"
+	"gin-fleamarket/infra"
+	"log"
+	"os"
+)
+
+// 開発・負荷試験用のデータを投入する（先にマイグレーションを適用しておくこと）
+//
+//	go run ./seed -file fixtures/dev.yaml
+//	go run ./seed -profile demo -items 5000 -sellers 50
+//
+// 既にある行は変更しないため、何度実行してもデータは重複しない
+func main() {
+	defaults := fixtures.DefaultDemoOptions()
+	file := flag.String("file", "", "fixture file to load (YAML or JSON)")
+	profile := flag.String("profile", "", `generated data profile ("demo")`)
+	items := flag.Int("items", defaults.Items, "number of items for the demo profile")
+	sellers := flag.Int("sellers", defaults.Sellers, "number of sellers for the demo profile")
+	seed := flag.Int64("seed", defaults.Seed, "random seed for the demo profile")
+	flag.Parse()
+
+	if (*file == "") == (*profile == "") || (*profile != "" && *profile != "demo") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.MustLoad()
+	if cfg.IsProd() {
+		log.Fatal("Refusing to seed a prod database")
+	}
+	db, err := infra.OpenDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	seeder := fixtures.NewSeeder(db, 0)
+
+	var result fixtures.Result
+	if *file != "" {
+		fixture, err := fixtures.LoadFile(*file)
+		if err != nil {
+			log.Fatalf("Failed to load fixture: %v", err)
+		}
+		result, err = seeder.Apply(fixture)
+		if err != nil {
+			log.Fatalf("Failed to seed: %v", err)
+		}
+	} else {
+		result, err = seeder.Demo(fixtures.DemoOptions{Items: *items, Sellers: *sellers, Seed: *seed})
+		if err != nil {
+			log.Fatalf("Failed to seed demo data: %v", err)
+		}
+	}
+	log.Printf("Seeded %d users and %d items", result.UsersCreated, result.ItemsCreated)
+}
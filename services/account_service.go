@@ -0,0 +1,192 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/storage"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IAccountService 本人による個人データのエクスポートと退会
+type IAccountService interface {
+	Export(userID uint, client ClientInfo) (*dto.AccountExport, error)
+	Delete(userID uint, client ClientInfo) error
+}
+
+type AccountService struct {
+	repository       repositories.IAccountRepository
+	userRepository   repositories.IUserRepository
+	apiKeyRepository repositories.IAPIKeyRepository
+	storage          storage.IFileStorage
+	auditService     IAuditService
+}
+
+func NewAccountService(repository repositories.IAccountRepository, userRepository repositories.IUserRepository, apiKeyRepository repositories.IAPIKeyRepository, storage storage.IFileStorage, auditService IAuditService) IAccountService {
+	return &AccountService{
+		repository:       repository,
+		userRepository:   userRepository,
+		apiKeyRepository: apiKeyRepository,
+		storage:          storage,
+		auditService:     auditService,
+	}
+}
+
+// Export 売り切れ・非公開の商品や失効済みのセッションも含めて返す
+// APIキーはハッシュを含めず、発行時と同じ表示用の情報のみを返す
+// 監査ログは本人が操作した行だけIPアドレス・User-Agent・詳細を含める
+func (s *AccountService) Export(userID uint, client ClientInfo) (*dto.AccountExport, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.userRepository.FindItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.repository.FindSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := s.apiKeyRepository.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.repository.FindIdentities(userID)
+	if err != nil {
+		return nil, err
+	}
+	auditLogs, err := s.repository.FindAuditLogs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &dto.AccountExport{
+		ExportedAt: time.Now().Unix(),
+		Profile:    *toProfileResponse(user),
+		Account: dto.AccountExportUser{
+			Role:      user.Role,
+			Status:    user.Status,
+			CreatedAt: user.CreatedAt.Unix(),
+		},
+		Items:      *items,
+		Sessions:   make([]dto.SessionResponse, 0, len(*sessions)),
+		APIKeys:    make([]dto.APIKeyResponse, 0, len(*apiKeys)),
+		Identities: make([]dto.AccountIdentity, 0, len(*identities)),
+		AuditLogs:  make([]dto.AuditLogResponse, 0, len(*auditLogs)),
+	}
+	for i := range *sessions {
+		export.Sessions = append(export.Sessions, toSessionResponse(&(*sessions)[i]))
+	}
+	for i := range *apiKeys {
+		export.APIKeys = append(export.APIKeys, toAPIKeyResponse(&(*apiKeys)[i]))
+	}
+	for _, identity := range *identities {
+		export.Identities = append(export.Identities, dto.AccountIdentity{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+			LinkedAt: identity.CreatedAt.Unix(),
+		})
+	}
+	for i := range *auditLogs {
+		entry, err := toAuditLogResponse(&(*auditLogs)[i])
+		if err != nil {
+			return nil, err
+		}
+		// 対象になっただけの行のIPアドレス・User-Agent・詳細は操作した管理者などの情報なので含めない
+		if entry.ActorID != userID {
+			entry.IPAddress = ""
+			entry.UserAgent = ""
+			entry.Detail = nil
+		}
+		export.AuditLogs = append(export.AuditLogs, entry)
+	}
+
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditAccountExport,
+		ActorID:  userID,
+		TargetID: userID,
+		Client:   client,
+	})
+	return export, nil
+}
+
+// Delete 退会処理。行は削除せずに個人情報を匿名化し、売れた商品は購入者の取引履歴として残す
+// セッションとAPIキーは削除され、発行済みのアクセストークンも状態チェックで拒否されるようになる
+// 監査ログの行は残るが、ユーザーが操作した行のIPアドレスとUser-Agentは消去される
+func (s *AccountService) Delete(userID uint, client ClientInfo) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		// メールアドレスは一意制約があるため、元のアドレスを推測できない値に置き換える
+		"email": fmt.Sprintf("deleted-%d-%s@deleted.invalid", userID, hex.EncodeToString(b)),
+		// bcryptのハッシュとして解釈できない値にし、どのパスワードでもログインできないようにする
+		"password":        "",
+		"role":            constants.RoleUser,
+		"status":          constants.UserStatusDeleted,
+		"status_reason":   "",
+		"suspended_until": 0,
+		"display_name":    "",
+		"avatar_url":      "",
+		"bio":             "",
+		"prefecture":      "",
+	}
+	anonymized, err := s.repository.Anonymize(userID, updates)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrUserNotFound
+		}
+		return err
+	}
+	if !anonymized {
+		return apperrors.ErrLastAdmin
+	}
+
+	if user.AvatarURL != "" {
+		if err := s.storage.Delete(user.AvatarURL); err != nil {
+			client.Logger().Warn("Failed to delete avatar", "avatar_url", user.AvatarURL, "error", err)
+		}
+	}
+	client.Logger().Info("Anonymized user", "user_id", userID)
+
+	// 消去したばかりのIPアドレスとUser-Agentを残さないよう、退会の記録には含めない
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditAccountDelete,
+		ActorID:  userID,
+		TargetID: userID,
+		Client:   ClientInfo{RequestID: client.RequestID},
+	})
+	return nil
+}
+
+// findUser 退会済みのユーザーは存在しないものとして扱う
+func (s *AccountService) findUser(userID uint) (*models.User, error) {
+	user, err := s.userRepository.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	if user.Status == constants.UserStatusDeleted {
+		return nil, apperrors.ErrUserNotFound
+	}
+	return user, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix = "fm_"
+	// 最終利用日時の更新はこの間隔より細かく行わない（リクエストごとの書き込みを避けるため）
+	apiKeyLastUsedResolution = time.Minute
+)
+
+var validAPIKeyScopes = map[string]bool{
+	constants.ScopeItemsRead:  true,
+	constants.ScopeItemsWrite: true,
+	constants.ScopeOrdersRead: true,
+}
+
+type IAPIKeyService interface {
+	Create(userID uint, input dto.CreateAPIKeyInput) (*dto.CreateAPIKeyResponse, error)
+	FindAll(userID uint) (*[]dto.APIKeyResponse, error)
+	Delete(apiKeyID uint, userID uint) error
+	Authenticate(plaintextKey string) (*models.User, []string, error)
+}
+
+type APIKeyService struct {
+	repository     repositories.IAPIKeyRepository
+	authRepository repositories.IAuthRepository
+}
+
+func NewAPIKeyService(repository repositories.IAPIKeyRepository, authRepository repositories.IAuthRepository) IAPIKeyService {
+	return &APIKeyService{
+		repository:     repository,
+		authRepository: authRepository,
+	}
+}
+
+func (s *APIKeyService) Create(userID uint, input dto.CreateAPIKeyInput) (*dto.CreateAPIKeyResponse, error) {
+	for _, scope := range input.Scopes {
+		if !validAPIKeyScopes[scope] {
+			return nil, apperrors.ErrAPIKeyInvalidScope
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	plaintextKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	var expiresAt int64
+	if input.ExpiresInDays != nil {
+		expiresAt = time.Now().Add(time.Duration(*input.ExpiresInDays) * 24 * time.Hour).Unix()
+	}
+
+	apiKey := models.APIKey{
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    plaintextKey[:len(apiKeyPrefix)+6],
+		KeyHash:   hashAPIKey(plaintextKey),
+		Scopes:    strings.Join(input.Scopes, " "),
+		ExpiresAt: expiresAt,
+	}
+	createdKey, err := s.repository.Create(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreateAPIKeyResponse{
+		APIKeyResponse: toAPIKeyResponse(createdKey),
+		Key:            plaintextKey,
+	}, nil
+}
+
+func (s *APIKeyService) FindAll(userID uint) (*[]dto.APIKeyResponse, error) {
+	apiKeys, err := s.repository.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.APIKeyResponse, 0, len(*apiKeys))
+	for i := range *apiKeys {
+		responses = append(responses, toAPIKeyResponse(&(*apiKeys)[i]))
+	}
+	return &responses, nil
+}
+
+func (s *APIKeyService) Delete(apiKeyID uint, userID uint) error {
+	err := s.repository.Delete(apiKeyID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrAPIKeyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate 平文のAPIキーからユーザーと付与されたスコープを返す
+func (s *APIKeyService) Authenticate(plaintextKey string) (*models.User, []string, error) {
+	if !strings.HasPrefix(plaintextKey, apiKeyPrefix) {
+		return nil, nil, apperrors.ErrInvalidToken
+	}
+
+	apiKey, err := s.repository.FindByHash(hashAPIKey(plaintextKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, apperrors.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if apiKey.ExpiresAt != 0 && now.Unix() > apiKey.ExpiresAt {
+		return nil, nil, apperrors.ErrInvalidToken
+	}
+
+	user, err := s.authRepository.FindUserByID(apiKey.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkUserStatus(user); err != nil {
+		return nil, nil, err
+	}
+
+	if now.Unix()-apiKey.LastUsedAt >= int64(apiKeyLastUsedResolution.Seconds()) {
+		if err := s.repository.UpdateLastUsedAt(apiKey.ID, now.Unix()); err != nil {
+			slog.Warn("Failed to update api key last used time", "api_key_id", apiKey.ID, "error", err)
+		}
+	}
+
+	return user, strings.Fields(apiKey.Scopes), nil
+}
+
+// hashAPIKey APIキーは十分なエントロピーを持つため、パスワードと違い高速なハッシュで検索可能にする
+func hashAPIKey(plaintextKey string) string {
+	sum := sha256.Sum256([]byte(plaintextKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func toAPIKeyResponse(apiKey *models.APIKey) dto.APIKeyResponse {
+	return dto.APIKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     strings.Fields(apiKey.Scopes),
+		CreatedAt:  apiKey.CreatedAt.Unix(),
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+	}
+}
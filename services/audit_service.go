@@ -0,0 +1,165 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuditLogsPerPage = 50
+	// チェーンの検証で一度に読み込む行数
+	auditVerifyBatchSize = 500
+)
+
+// AuditEntry 監査ログに記録する1件の操作
+type AuditEntry struct {
+	Action   string
+	ActorID  uint
+	TargetID uint
+	Client   ClientInfo
+	Detail   map[string]interface{}
+}
+
+type IAuditService interface {
+	Record(entry AuditEntry) error
+	Search(query dto.AuditLogQuery) (*[]dto.AuditLogResponse, *dto.PageMeta, error)
+	Verify() (*dto.AuditChainVerification, error)
+}
+
+type AuditService struct {
+	repository repositories.IAuditLogRepository
+}
+
+func NewAuditService(repository repositories.IAuditLogRepository) IAuditService {
+	return &AuditService{repository: repository}
+}
+
+func (s *AuditService) Record(entry AuditEntry) error {
+	var detail string
+	if len(entry.Detail) > 0 {
+		b, err := json.Marshal(entry.Detail)
+		if err != nil {
+			return err
+		}
+		detail = string(b)
+	}
+
+	return s.repository.Append(models.AuditLog{
+		// DBの精度（PostgreSQLはマイクロ秒）に揃えておかないと、読み出した値でハッシュが一致しなくなる
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Action:    entry.Action,
+		ActorID:   entry.ActorID,
+		TargetID:  entry.TargetID,
+		IPAddress: entry.Client.IPAddress,
+		UserAgent: entry.Client.UserAgent,
+		Detail:    detail,
+	}, auditLogHash)
+}
+
+func (s *AuditService) Search(query dto.AuditLogQuery) (*[]dto.AuditLogResponse, *dto.PageMeta, error) {
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PerPage == 0 {
+		query.PerPage = defaultAuditLogsPerPage
+	}
+
+	entries, total, err := s.repository.Search(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responses := make([]dto.AuditLogResponse, 0, len(*entries))
+	for i := range *entries {
+		response, err := toAuditLogResponse(&(*entries)[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		responses = append(responses, response)
+	}
+	return &responses, &dto.PageMeta{Page: query.Page, PerPage: query.PerPage, Total: total}, nil
+}
+
+// Verify 先頭から順にハッシュを再計算し、チェーンが途切れていないかを検証する
+func (s *AuditService) Verify() (*dto.AuditChainVerification, error) {
+	result := &dto.AuditChainVerification{Valid: true}
+	var lastID uint
+	var prevHash string
+	for {
+		entries, err := s.repository.FindAfter(lastID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for i := range *entries {
+			entry := &(*entries)[i]
+			if entry.PrevHash != prevHash || entry.Hash != auditLogHash(entry) {
+				result.Valid = false
+				result.BrokenAt = entry.ID
+				slog.Error("SECURITY: Audit log chain is broken", "audit_log_id", entry.ID)
+				return result, nil
+			}
+			prevHash = entry.Hash
+			lastID = entry.ID
+			result.Checked++
+		}
+		if len(*entries) < auditVerifyBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// auditLogHash 直前の行のハッシュと記録内容からSHA-256を計算する
+// IPアドレスとUser-Agentは退会時に消去できるよう、ハッシュに含めない
+func auditLogHash(entry *models.AuditLog) string {
+	fields := []string{
+		entry.PrevHash,
+		strconv.FormatInt(entry.CreatedAt.UnixMicro(), 10),
+		entry.Action,
+		strconv.FormatUint(uint64(entry.ActorID), 10),
+		strconv.FormatUint(uint64(entry.TargetID), 10),
+		entry.Detail,
+	}
+	// 区切り文字を含む値で別の組み合わせと衝突しないよう、各フィールドを長さ付きで連結する
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(strconv.Itoa(len(field)))
+		b.WriteByte(':')
+		b.WriteString(field)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordAudit 監査ログの記録に失敗しても本来の処理は継続する
+func recordAudit(auditService IAuditService, entry AuditEntry) {
+	if err := auditService.Record(entry); err != nil {
+		entry.Client.Logger().Error("Failed to record audit log", "action", entry.Action, "error", err)
+	}
+}
+
+func toAuditLogResponse(entry *models.AuditLog) (dto.AuditLogResponse, error) {
+	response := dto.AuditLogResponse{
+		ID:        entry.ID,
+		CreatedAt: entry.CreatedAt.Unix(),
+		Action:    entry.Action,
+		ActorID:   entry.ActorID,
+		TargetID:  entry.TargetID,
+		IPAddress: entry.IPAddress,
+		UserAgent: entry.UserAgent,
+		Hash:      entry.Hash,
+	}
+	if entry.Detail != "" {
+		if err := json.Unmarshal([]byte(entry.Detail), &response.Detail); err != nil {
+			return dto.AuditLogResponse{}, err
+		}
+	}
+	return response, nil
+}
@@ -1,16 +1,26 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"gin-fleamarket/apperrors"
 	"gin-fleamarket/constants"
+	"gin-fleamarket/metrics"
 	"gin-fleamarket/models"
 	"gin-fleamarket/repositories"
-	"log"
-	"os"
+	"gin-fleamarket/revocation"
+	"gin-fleamarket/tracing"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type TokenPair struct {
@@ -18,72 +28,175 @@ type TokenPair struct {
 	RefreshToken string
 }
 
+// ClientInfo セッション一覧に表示するためのリクエスト元の情報
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+	// RequestID ログをリクエストのアクセスログと突き合わせるために付ける
+	RequestID string
+}
+
+// Logger リクエストIDを付けてログを出力する（サービスはリクエストのcontextを受け取らないため）
+func (c ClientInfo) Logger() *slog.Logger {
+	if c.RequestID == "" {
+		return slog.Default()
+	}
+	return slog.Default().With("request_id", c.RequestID)
+}
+
 type IAuthService interface {
-	Signup(email string, password string) error
-	Login(email string, password string) (*TokenPair, error)
-	RefreshToken(refreshTokenString string) (*TokenPair, error)
-	GetUserFromToken(tokenString string) (*models.User, error)
-	Logout(tokenString string) error
+	Signup(email string, password string, client ClientInfo) error
+	Login(email string, password string, client ClientInfo) (*TokenPair, error)
+	IssueTokenPair(user *models.User, client ClientInfo) (*TokenPair, error)
+	RefreshToken(refreshTokenString string, client ClientInfo) (*TokenPair, error)
+	GetUserFromToken(ctx context.Context, tokenString string) (*models.User, *Impersonation, error)
+	Logout(tokenString string, client ClientInfo) error
+	ChangePassword(user *models.User, currentPassword string, newPassword string, client ClientInfo) error
 }
 
+const (
+	AccessTokenTTL  = time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
 type AuthService struct {
-	repository      repositories.IAuthRepository
-	tokenRepository repositories.ITokenRepository
+	repository        repositories.IAuthRepository
+	revocationStore   revocation.ITokenRevocationStore
+	sessionRepository repositories.ISessionRepository
+	keyRing           IKeyRing
+	auditService      IAuditService
 }
 
-func NewAuthService(repository repositories.IAuthRepository, tokenRepository repositories.ITokenRepository) IAuthService {
+func NewAuthService(repository repositories.IAuthRepository, revocationStore revocation.ITokenRevocationStore, sessionRepository repositories.ISessionRepository, keyRing IKeyRing, auditService IAuditService) IAuthService {
 	return &AuthService{
-		repository:      repository,
-		tokenRepository: tokenRepository,
+		repository:        repository,
+		revocationStore:   revocationStore,
+		sessionRepository: sessionRepository,
+		keyRing:           keyRing,
+		auditService:      auditService,
 	}
 }
 
-func (s *AuthService) Signup(email string, password string) error {
+func (s *AuthService) Signup(email string, password string, client ClientInfo) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
-	userCount, err := s.repository.CountUsers()
+	// 管理者は登録順ではなく、BootstrapServiceで明示的に作成する
+	user := models.User{
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     constants.RoleUser,
+	}
+	createdUser, err := s.repository.CreateUser(user)
 	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return apperrors.ErrEmailAlreadyExists
+		}
 		return err
 	}
 
-	log.Printf("Signup: Current user count in DB = %d", userCount)
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditSignup,
+		ActorID:  createdUser.ID,
+		TargetID: createdUser.ID,
+		Client:   client,
+	})
+	return nil
+}
 
-	role := constants.RoleUser
-	if userCount == 0 {
-		role = constants.RoleAdmin
-		log.Printf("Signup: First user detected, setting role to admin for email=%s", email)
-	} else {
-		log.Printf("Signup: Existing users found (%d), setting role to user for email=%s", userCount, email)
+func (s *AuthService) Login(email string, password string, client ClientInfo) (*TokenPair, error) {
+	// メールアドレスが登録されているかを推測されないよう、パスワードの誤りと同じエラーを返す
+	foundUser, err := s.repository.FindUser(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.recordLoginFailure(0, email, "unknown_email", client)
+			return nil, apperrors.ErrInvalidCredentials
+		}
+		return nil, err
 	}
 
-	user := models.User{
-		Email:    email,
-		Password: string(hashedPassword),
-		Role:     role,
+	err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(password))
+	if err != nil {
+		s.recordLoginFailure(foundUser.ID, email, "wrong_password", client)
+		return nil, apperrors.ErrInvalidCredentials
 	}
-	return s.repository.CreateUser(user)
-}
 
-func (s *AuthService) Login(email string, password string) (*TokenPair, error) {
-	foundUser, err := s.repository.FindUser(email)
+	tokenPair, err := s.IssueTokenPair(foundUser, client)
 	if err != nil {
+		if errors.Is(err, apperrors.ErrUserSuspended) || errors.Is(err, apperrors.ErrUserBanned) {
+			s.recordLoginFailure(foundUser.ID, email, foundUser.Status, client)
+		}
 		return nil, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(password))
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditLoginSuccess,
+		ActorID:  foundUser.ID,
+		TargetID: foundUser.ID,
+		Client:   client,
+		Detail:   map[string]interface{}{"method": "password"},
+	})
+	metrics.Logins.Inc("password", "success")
+	return tokenPair, nil
+}
+
+// recordLoginFailure 既存のユーザーはTargetIDで特定できるため、メールアドレスは存在しないアカウントの場合だけ記録する
+// Detailはハッシュに含まれ退会時にも消去できないので、ユーザーに紐づく個人情報は入れない
+func (s *AuthService) recordLoginFailure(userID uint, email string, reason string, client ClientInfo) {
+	metrics.Logins.Inc("password", "failure")
+	detail := map[string]interface{}{"reason": reason}
+	if userID == 0 {
+		detail["email"] = email
+	}
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditLoginFailure,
+		TargetID: userID,
+		Client:   client,
+		Detail:   detail,
+	})
+}
+
+// IssueTokenPair 新しいリフレッシュトークンファミリー（セッション）を作成してトークンペアを発行する
+func (s *AuthService) IssueTokenPair(user *models.User, client ClientInfo) (*TokenPair, error) {
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	familyID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	jti, err := newTokenID()
 	if err != nil {
 		return nil, err
 	}
 
-	accessToken, err := CreateAccessToken(foundUser.ID, foundUser.Email, foundUser.Role)
+	now := time.Now()
+	session := models.RefreshSession{
+		FamilyID:   familyID,
+		UserID:     user.ID,
+		CurrentJTI: jti,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		LastUsedAt: now.Unix(),
+		ExpiresAt:  now.Add(RefreshTokenTTL).Unix(),
+	}
+	if err := s.sessionRepository.Create(session); err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(s.keyRing, user.ID, user.Email, user.Role, familyID, jti)
+}
+
+func issueTokenPair(keyRing IKeyRing, userID uint, email string, role string, sessionID string, jti string) (*TokenPair, error) {
+	accessToken, err := CreateAccessToken(keyRing, userID, email, role, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := CreateRefreshToken(foundUser.ID, foundUser.Email, foundUser.Role)
+	refreshToken, err := CreateRefreshToken(keyRing, userID, email, role, sessionID, jti)
 	if err != nil {
 		return nil, err
 	}
@@ -94,160 +207,332 @@ func (s *AuthService) Login(email string, password string) (*TokenPair, error) {
 	}, nil
 }
 
-func CreateAccessToken(userID uint, email string, role string) (*string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// revocationID 失効ストアのキー。jtiを持たない（導入前に発行された）トークンはトークン全体のハッシュで代用する
+func revocationID(claims jwt.MapClaims, tokenString string) string {
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		return jti
+	}
+	sum := sha256.Sum256([]byte(tokenString))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newTokenID jtiやファミリーIDに使うランダムな識別子を生成する
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateAccessToken sessionIDが空の場合はセッションに紐づかないトークンになる
+// jtiはログアウト時にトークン単位で失効させるために使う
+func CreateAccessToken(keyRing IKeyRing, userID uint, email string, role string, sessionID string) (*string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{
 		"sub":   userID,
 		"email": email,
 		"role":  role,
 		"type":  "access",
-		"exp":   time.Now().Add(time.Hour).Unix(),
-	})
+		"jti":   jti,
+		"exp":   time.Now().Add(AccessTokenTTL).Unix(),
+	}
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+	tokenString, err := keyRing.Sign(claims)
 	if err != nil {
 		return nil, err
 	}
 	return &tokenString, nil
 }
 
-func CreateRefreshToken(userID uint, email string, role string) (*string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+func CreateRefreshToken(keyRing IKeyRing, userID uint, email string, role string, sessionID string, jti string) (*string, error) {
+	tokenString, err := keyRing.Sign(jwt.MapClaims{
 		"sub":   userID,
 		"email": email,
 		"role":  role,
 		"type":  "refresh",
-		"exp":   time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"sid":   sessionID,
+		"jti":   jti,
+		"exp":   time.Now().Add(RefreshTokenTTL).Unix(),
 	})
-
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
 	if err != nil {
 		return nil, err
 	}
 	return &tokenString, nil
 }
 
-func (s *AuthService) GetUserFromToken(tokenString string) (*models.User, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("SECRET_KEY")), nil
-	})
+// GetUserFromToken なりすましトークンの場合は、操作している管理者の情報も返す
+func (s *AuthService) GetUserFromToken(ctx context.Context, tokenString string) (user *models.User, impersonation *Impersonation, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.GetUserFromToken")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	token, err := jwt.Parse(tokenString, s.keyRing.Keyfunc, jwt.WithValidMethods(s.keyRing.ValidMethods()))
 	if err != nil {
-		return nil, err
+		return nil, nil, apperrors.ErrInvalidToken.Wrap(err)
 	}
 
-	var user *models.User
 	if claims, ok := token.Claims.(jwt.MapClaims); ok {
 		if tokenType, ok := claims["type"].(string); ok && tokenType != "access" {
-			return nil, fmt.Errorf("invalid token type: access token required")
+			return nil, nil, apperrors.ErrInvalidToken.Wrap(fmt.Errorf("invalid token type: access token required"))
 		}
 
 		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			return nil, jwt.ErrTokenExpired
+			return nil, nil, apperrors.ErrInvalidToken.Wrap(jwt.ErrTokenExpired)
 		}
 
-		isBlacklisted, err := s.tokenRepository.IsTokenBlacklisted(tokenString)
+		_, revocationSpan := tracing.Start(ctx, "TokenRevocationStore.IsRevoked")
+		revoked, err := s.revocationStore.IsRevoked(revocationID(claims, tokenString))
+		revocationSpan.RecordError(err)
+		revocationSpan.SetAttributes(tracing.Bool("token.revoked", revoked))
+		revocationSpan.End()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if revoked {
+			return nil, nil, apperrors.ErrInvalidToken.Wrap(fmt.Errorf("token is blacklisted"))
 		}
-		if isBlacklisted {
-			return nil, fmt.Errorf("token is blacklisted")
+
+		// セッションが失効している場合は、そのセッションで発行されたアクセストークンも拒否する
+		if sessionID, ok := claims["sid"].(string); ok && sessionID != "" {
+			session, err := s.sessionRepository.FindByFamilyID(sessionID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if session.RevokedAt != 0 {
+				return nil, nil, apperrors.ErrSessionRevoked
+			}
 		}
 
-		email := claims["email"].(string)
-		user, err = s.repository.FindUser(email)
+		// 退会でメールアドレスは解放され、同じアドレスで別のユーザーが登録できるため、IDで引く
+		userID, ok := claims["sub"].(float64)
+		if !ok {
+			return nil, nil, apperrors.ErrInvalidToken.Wrap(fmt.Errorf("invalid token claims: sub is missing"))
+		}
+		user, err = s.repository.FindUserByID(uint(userID))
 		if err != nil {
-			return nil, err
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, apperrors.ErrUserNotFound
+			}
+			return nil, nil, err
 		}
-		log.Printf("GetUserFromToken: Retrieved user from DB - ID=%d, Email=%s, Role=%s",
-			user.ID, user.Email, user.Role)
+		if err := checkUserStatus(user); err != nil {
+			return nil, nil, err
+		}
+
+		// 管理者が停止・削除された場合は、発行済みのなりすましトークンも使えなくする
+		impersonation = impersonationFromClaims(claims)
+		if impersonation != nil {
+			actor, err := s.repository.FindUserByID(impersonation.ActorID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := checkUserStatus(actor); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		span.SetAttributes(tracing.Int64("enduser.id", int64(user.ID)))
+		slog.DebugContext(ctx, "Retrieved user from DB", "user_id", user.ID, "role", user.Role)
 	}
-	return user, nil
+	return user, impersonation, nil
 }
 
-func (s *AuthService) RefreshToken(refreshTokenString string) (*TokenPair, error) {
-	token, err := jwt.Parse(refreshTokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("SECRET_KEY")), nil
-	})
+func (s *AuthService) RefreshToken(refreshTokenString string, client ClientInfo) (*TokenPair, error) {
+	token, err := jwt.Parse(refreshTokenString, s.keyRing.Keyfunc, jwt.WithValidMethods(s.keyRing.ValidMethods()))
 	if err != nil {
-		return nil, fmt.Errorf("invalid refresh token: %v", err)
+		return nil, apperrors.ErrInvalidToken.Wrap(err)
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok {
 		if tokenType, ok := claims["type"].(string); !ok || tokenType != "refresh" {
-			return nil, fmt.Errorf("invalid token type")
+			return nil, apperrors.ErrInvalidToken.Wrap(fmt.Errorf("invalid token type: refresh token required"))
 		}
 
 		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			return nil, jwt.ErrTokenExpired
+			return nil, apperrors.ErrInvalidToken.Wrap(jwt.ErrTokenExpired)
 		}
 
-		isBlacklisted, err := s.tokenRepository.IsTokenBlacklisted(refreshTokenString)
+		userID := uint(claims["sub"].(float64))
+
+		sessionID, _ := claims["sid"].(string)
+		jti, _ := claims["jti"].(string)
+		if sessionID == "" || jti == "" {
+			return s.refreshLegacyToken(refreshTokenString, claims, client)
+		}
+
+		session, err := s.sessionRepository.FindByFamilyID(sessionID)
 		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrInvalidToken.Wrap(err)
+			}
 			return nil, err
 		}
-		if isBlacklisted {
-			return nil, fmt.Errorf("refresh token is blacklisted")
+		if session.RevokedAt != 0 {
+			return nil, apperrors.ErrSessionRevoked
+		}
+		if session.CurrentJTI != jti {
+			return nil, s.revokeReusedFamily(session, client)
 		}
 
-		userID := uint(claims["sub"].(float64))
-		email := claims["email"].(string)
-		role := claims["role"].(string)
-
-		accessToken, err := CreateAccessToken(userID, email, role)
+		// ロールの変更や停止を反映するため、クレームはリフレッシュトークンからではなくDBのユーザーから作る
+		user, err := s.repository.FindUserByID(userID)
 		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrInvalidToken.Wrap(err)
+			}
+			return nil, err
+		}
+		if err := checkUserStatus(user); err != nil {
 			return nil, err
 		}
 
-		newRefreshToken, err := CreateRefreshToken(userID, email, role)
+		newJTI, err := newTokenID()
 		if err != nil {
 			return nil, err
 		}
-
-		var expiresAt int64
-		if exp, ok := claims["exp"].(float64); ok {
-			expiresAt = int64(exp)
-		} else {
-			expiresAt = time.Now().Add(7 * 24 * time.Hour).Unix()
+		rotated, err := s.sessionRepository.Rotate(sessionID, jti, newJTI, client.IPAddress, client.UserAgent, time.Now().Add(RefreshTokenTTL).Unix())
+		if err != nil {
+			return nil, err
 		}
-		if err := s.tokenRepository.AddBlacklistedToken(refreshTokenString, expiresAt); err != nil {
-			fmt.Printf("Warning: Failed to blacklist old refresh token: %v\n", err)
+		if !rotated {
+			// 検証と更新の間に同じトークンが別のリクエストで使われた
+			return nil, s.revokeReusedFamily(session, client)
 		}
 
-		return &TokenPair{
-			AccessToken:  *accessToken,
-			RefreshToken: *newRefreshToken,
-		}, nil
+		recordAudit(s.auditService, AuditEntry{
+			Action:   constants.AuditRefresh,
+			ActorID:  userID,
+			TargetID: userID,
+			Client:   client,
+			Detail:   map[string]interface{}{"session_id": session.ID},
+		})
+		return issueTokenPair(s.keyRing, user.ID, user.Email, user.Role, sessionID, newJTI)
 	}
 
-	return nil, fmt.Errorf("invalid token claims")
+	return nil, apperrors.ErrInvalidToken.Wrap(fmt.Errorf("invalid token claims"))
 }
 
-func (s *AuthService) Logout(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("SECRET_KEY")), nil
+// revokeReusedFamily ローテーション済みのリフレッシュトークンが提示された場合、
+// 盗まれたトークンの可能性があるため正規の利用者側も含めてファミリー全体を失効させる
+func (s *AuthService) revokeReusedFamily(session *models.RefreshSession, client ClientInfo) error {
+	client.Logger().Warn("SECURITY: Refresh token reuse detected; revoking token family",
+		"user_id", session.UserID, "session_id", session.ID, "client_ip", client.IPAddress, "user_agent", client.UserAgent)
+	if err := s.sessionRepository.Revoke(session.FamilyID); err != nil {
+		return err
+	}
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditRefreshReuse,
+		TargetID: session.UserID,
+		Client:   client,
+		Detail:   map[string]interface{}{"session_id": session.ID},
 	})
+	return apperrors.ErrRefreshTokenReused
+}
+
+// refreshLegacyToken ファミリー導入前に発行されたリフレッシュトークンを一度だけ受け付け、新しいファミリーに移行する
+func (s *AuthService) refreshLegacyToken(refreshTokenString string, claims jwt.MapClaims, client ClientInfo) (*TokenPair, error) {
+	tokenID := revocationID(claims, refreshTokenString)
+	revoked, err := s.revocationStore.IsRevoked(tokenID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if revoked {
+		return nil, apperrors.ErrInvalidToken.Wrap(fmt.Errorf("refresh token is blacklisted"))
+	}
+
+	if err := s.revocationStore.Revoke(tokenID, int64(claims["exp"].(float64))); err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["sub"].(float64)
+	if !ok {
+		return nil, apperrors.ErrInvalidToken.Wrap(fmt.Errorf("invalid token claims: sub is missing"))
+	}
+	user, err := s.repository.FindUserByID(uint(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrInvalidToken.Wrap(err)
+		}
+		return nil, err
+	}
+	return s.IssueTokenPair(user, client)
+}
+
+// Logout アクセストークンを失効させ、トークンが属するセッションも終了する
+func (s *AuthService) Logout(tokenString string, client ClientInfo) error {
+	token, err := jwt.Parse(tokenString, s.keyRing.Keyfunc, jwt.WithValidMethods(s.keyRing.ValidMethods()))
+	if err != nil {
+		return apperrors.ErrInvalidToken.Wrap(err)
 	}
 
 	var expiresAt int64
+	tokenID := revocationID(nil, tokenString)
 	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		tokenID = revocationID(claims, tokenString)
 		if exp, ok := claims["exp"].(float64); ok {
 			expiresAt = int64(exp)
 		} else {
-			expiresAt = time.Now().Add(time.Hour).Unix()
+			expiresAt = time.Now().Add(AccessTokenTTL).Unix()
+		}
+		if sessionID, ok := claims["sid"].(string); ok && sessionID != "" {
+			if err := s.sessionRepository.Revoke(sessionID); err != nil {
+				return err
+			}
 		}
 	} else {
-		expiresAt = time.Now().Add(time.Hour).Unix()
+		expiresAt = time.Now().Add(AccessTokenTTL).Unix()
 	}
 
-	return s.tokenRepository.AddBlacklistedToken(tokenString, expiresAt)
+	if err := s.revocationStore.Revoke(tokenID, expiresAt); err != nil {
+		return err
+	}
+
+	var userID uint
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if sub, ok := claims["sub"].(float64); ok {
+			userID = uint(sub)
+		}
+	}
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditLogout,
+		ActorID:  userID,
+		TargetID: userID,
+		Client:   client,
+	})
+	return nil
+}
+
+// ChangePassword パスワードを変更し、漏えいしたパスワードで作られたセッションが残らないようすべてのセッションを失効させる
+func (s *AuthService) ChangePassword(user *models.User, currentPassword string, newPassword string, client ClientInfo) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)); err != nil {
+		return apperrors.ErrWrongPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repository.UpdatePassword(user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := s.sessionRepository.RevokeAllByUserID(user.ID); err != nil {
+		return err
+	}
+
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditPasswordChange,
+		ActorID:  user.ID,
+		TargetID: user.ID,
+		Client:   client,
+	})
+	return nil
 }
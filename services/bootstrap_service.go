@@ -0,0 +1,75 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"log/slog"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type IBootstrapService interface {
+	CreateAdmin(email string, password string) error
+	CreateAdminWithToken(token string, email string, password string) error
+}
+
+// BootstrapService 新規デプロイ時に最初の管理者を作成する
+// 管理者がすでに存在する場合は何もしないため、トークンは一度しか使えない
+type BootstrapService struct {
+	repository     repositories.IAuthRepository
+	bootstrapToken string
+}
+
+func NewBootstrapService(repository repositories.IAuthRepository, bootstrapToken string) IBootstrapService {
+	return &BootstrapService{
+		repository:     repository,
+		bootstrapToken: bootstrapToken,
+	}
+}
+
+// CreateAdmin サーバーにアクセスできる運用者がCLIから実行する
+func (s *BootstrapService) CreateAdmin(email string, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	created, err := s.repository.CreateFirstAdmin(models.User{
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     constants.RoleAdmin,
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return apperrors.ErrEmailAlreadyExists
+		}
+		return err
+	}
+	if !created {
+		return apperrors.ErrAdminAlreadyExists
+	}
+
+	slog.Info("Created initial admin account", "email", email)
+	return nil
+}
+
+// CreateAdminWithToken ADMIN_BOOTSTRAP_TOKENを知っている場合のみAPIから最初の管理者を作成できる
+func (s *BootstrapService) CreateAdminWithToken(token string, email string, password string) error {
+	if s.bootstrapToken == "" {
+		return apperrors.ErrBootstrapDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.bootstrapToken)) != 1 {
+		return apperrors.ErrInvalidBootstrapToken
+	}
+
+	if err := s.CreateAdmin(email, password); err != nil {
+		return err
+	}
+	slog.Info("Admin account created; ADMIN_BOOTSTRAP_TOKEN can now be removed from the configuration")
+	return nil
+}
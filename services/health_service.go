@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/migrate"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/revocation"
+	"gin-fleamarket/scheduler"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckTimeout 応答しない依存先があっても、ロードバランサーのタイムアウトより前に結果を返す
+const healthCheckTimeout = 2 * time.Second
+
+// IHealthService 依存先ごとの状態を確認する
+type IHealthService interface {
+	Ready(ctx context.Context) dto.HealthResponse
+	// Detail 管理者向けに、エラーの内容やコネクションプールの状態を含めて返す
+	Detail(ctx context.Context) (*dto.HealthDetailResponse, error)
+}
+
+type HealthService struct {
+	repository      repositories.IHealthRepository
+	revocationStore revocation.ITokenRevocationStore
+	migrator        migrate.IMigrator
+	scheduler       scheduler.IScheduler
+	jobsEnabled     bool
+	startedAt       time.Time
+	// migrated 適用済みを一度確認したら、以降はschema_migrationsを読まない
+	migrated atomic.Bool
+}
+
+func NewHealthService(repository repositories.IHealthRepository, revocationStore revocation.ITokenRevocationStore, migrator migrate.IMigrator, jobScheduler scheduler.IScheduler, jobsEnabled bool) IHealthService {
+	return &HealthService{
+		repository:      repository,
+		revocationStore: revocationStore,
+		migrator:        migrator,
+		scheduler:       jobScheduler,
+		jobsEnabled:     jobsEnabled,
+		startedAt:       time.Now(),
+	}
+}
+
+func (s *HealthService) Ready(ctx context.Context) dto.HealthResponse {
+	response := s.check(ctx)
+	// 公開エンドポイントのため、接続先などを含むエラーの内容は返さない
+	for name, check := range response.Checks {
+		check.Error = ""
+		response.Checks[name] = check
+	}
+	return response
+}
+
+func (s *HealthService) Detail(ctx context.Context) (*dto.HealthDetailResponse, error) {
+	stats, err := s.repository.PoolStats()
+	if err != nil {
+		return nil, err
+	}
+	migrations := dto.HealthMigrationDetail{Pending: []string{}}
+	statuses, err := s.migrator.Status()
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			migrations.Applied++
+		} else {
+			migrations.Pending = append(migrations.Pending, fmt.Sprintf("%d_%s", status.Version, status.Name))
+		}
+	}
+
+	jobs := dto.HealthJobsDetail{Enabled: s.jobsEnabled, Leader: s.scheduler.IsLeader()}
+	if heartbeat := s.scheduler.Heartbeat(); !heartbeat.IsZero() {
+		jobs.LastHeartbeatAt = heartbeat.Unix()
+	}
+
+	return &dto.HealthDetailResponse{
+		HealthResponse: s.check(ctx),
+		StartedAt:      s.startedAt.Unix(),
+		UptimeSeconds:  int64(time.Since(s.startedAt).Seconds()),
+		GoVersion:      runtime.Version(),
+		Goroutines:     runtime.NumGoroutine(),
+		DBPool: dto.DBPoolStats{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		},
+		Migrations: migrations,
+		Jobs:       jobs,
+	}, nil
+}
+
+// check 依存先を並行して確認する
+func (s *HealthService) check(ctx context.Context) dto.HealthResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) error{
+		"database": s.repository.Ping,
+		"token_store": func(ctx context.Context) error {
+			return s.revocationStore.Ping()
+		},
+		"migrations": s.checkMigrations,
+	}
+	response := dto.HealthResponse{Status: constants.HealthStatusOK, Checks: make(map[string]dto.HealthCheck)}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, fn := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runHealthCheck(ctx, fn)
+			mu.Lock()
+			response.Checks[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	response.Checks["jobs"] = s.checkJobs()
+
+	for _, check := range response.Checks {
+		if check.Status == constants.HealthStatusUnavailable {
+			response.Status = constants.HealthStatusUnavailable
+		}
+	}
+	return response
+}
+
+// runHealthCheck ctxに対応していない確認（トークンストアなど）もタイムアウトで打ち切る
+func runHealthCheck(ctx context.Context, fn func(ctx context.Context) error) dto.HealthCheck {
+	started := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	check := dto.HealthCheck{
+		Status:    constants.HealthStatusOK,
+		LatencyMs: float64(time.Since(started).Microseconds()) / 1000,
+	}
+	if err != nil {
+		check.Status = constants.HealthStatusUnavailable
+		check.Error = err.Error()
+	}
+	return check
+}
+
+func (s *HealthService) checkMigrations(ctx context.Context) error {
+	if s.migrated.Load() {
+		return nil
+	}
+	statuses, err := s.migrator.Status()
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations pending", pending)
+	}
+	s.migrated.Store(true)
+	return nil
+}
+
+// checkJobs スケジューラーが止まっていると、期限切れのデータの削除などが行われなくなる
+func (s *HealthService) checkJobs() dto.HealthCheck {
+	if !s.jobsEnabled {
+		return dto.HealthCheck{Status: constants.HealthStatusDisabled}
+	}
+	heartbeat := s.scheduler.Heartbeat()
+	if heartbeat.IsZero() {
+		return dto.HealthCheck{Status: constants.HealthStatusUnavailable, Error: "scheduler has not started"}
+	}
+	if age := time.Since(heartbeat); age > scheduler.HeartbeatTimeout {
+		return dto.HealthCheck{Status: constants.HealthStatusUnavailable, Error: fmt.Sprintf("last heartbeat %s ago", age.Round(time.Second))}
+	}
+	return dto.HealthCheck{Status: constants.HealthStatusOK}
+}
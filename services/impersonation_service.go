@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// なりすましトークンは調査に必要な短時間のみ有効とし、リフレッシュトークンは発行しない
+const impersonationTokenTTL = 15 * time.Minute
+
+const (
+	impersonationModeRead  = "read"
+	impersonationModeWrite = "write"
+)
+
+// Impersonation 管理者が他のユーザーとして操作するために発行したトークンの情報
+type Impersonation struct {
+	ActorID    uint
+	ActorEmail string
+	ReadOnly   bool
+	TokenID    string
+}
+
+type IImpersonationService interface {
+	Start(actor *models.User, targetID uint, input dto.ImpersonateInput, client ClientInfo) (*dto.ImpersonationResponse, error)
+}
+
+type ImpersonationService struct {
+	repository   repositories.IAuthRepository
+	keyRing      IKeyRing
+	auditService IAuditService
+}
+
+func NewImpersonationService(repository repositories.IAuthRepository, keyRing IKeyRing, auditService IAuditService) IImpersonationService {
+	return &ImpersonationService{
+		repository:   repository,
+		keyRing:      keyRing,
+		auditService: auditService,
+	}
+}
+
+// Start 対象ユーザーのなりすましアクセストークンを発行する
+// 発行は監査ログに記録できた場合のみ成功する
+func (s *ImpersonationService) Start(actor *models.User, targetID uint, input dto.ImpersonateInput, client ClientInfo) (*dto.ImpersonationResponse, error) {
+	target, err := s.repository.FindUserByID(targetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	// 管理者へのなりすましは権限の迂回になるため許可しない
+	if target.ID == actor.ID || target.Role == constants.RoleAdmin {
+		return nil, apperrors.ErrImpersonationNotAllowed
+	}
+	if err := checkUserStatus(target); err != nil {
+		return nil, apperrors.ErrImpersonationNotAllowed
+	}
+
+	jti, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	mode := impersonationModeRead
+	if input.AllowWrite {
+		mode = impersonationModeWrite
+	}
+	expiresAt := time.Now().Add(impersonationTokenTTL).Unix()
+
+	err = s.auditService.Record(AuditEntry{
+		Action:   constants.AuditImpersonationStart,
+		ActorID:  actor.ID,
+		TargetID: target.ID,
+		Client:   client,
+		Detail: map[string]interface{}{
+			"reason":     input.Reason,
+			"mode":       mode,
+			"jti":        jti,
+			"expires_at": expiresAt,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.keyRing.Sign(jwt.MapClaims{
+		"sub":   target.ID,
+		"email": target.Email,
+		"role":  target.Role,
+		"type":  "access",
+		"jti":   jti,
+		"exp":   expiresAt,
+		// RFC 8693のactクレームで実際に操作している管理者を示す
+		"act": map[string]interface{}{
+			"sub":   actor.ID,
+			"email": actor.Email,
+		},
+		"imp": mode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client.Logger().Warn("Admin started impersonating user", "actor_id", actor.ID, "user_id", target.ID, "mode", mode)
+
+	return &dto.ImpersonationResponse{
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt,
+		ReadOnly:    !input.AllowWrite,
+	}, nil
+}
+
+// impersonationFromClaims なりすましトークンでない場合はnilを返す
+func impersonationFromClaims(claims jwt.MapClaims) *Impersonation {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	actorID, _ := act["sub"].(float64)
+	actorEmail, _ := act["email"].(string)
+	tokenID, _ := claims["jti"].(string)
+	mode, _ := claims["imp"].(string)
+	return &Impersonation{
+		ActorID:    uint(actorID),
+		ActorEmail: actorEmail,
+		ReadOnly:   mode != impersonationModeWrite,
+		TokenID:    tokenID,
+	}
+}
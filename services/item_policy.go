@@ -0,0 +1,66 @@
+package services
+
+import (
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+)
+
+// IItemPolicy 商品ごとの閲覧・更新・削除の可否を、所有者・ロール・商品の状態から判定する
+// userがnilの場合は未ログインのユーザーとして扱う
+type IItemPolicy interface {
+	CanRead(user *models.User, item *models.Item) (bool, error)
+	CanUpdate(user *models.User, item *models.Item, input dto.UpdateItemInput) (bool, error)
+	CanDelete(user *models.User, item *models.Item) (bool, error)
+}
+
+type ItemPolicy struct {
+	roleService IRoleService
+}
+
+func NewItemPolicy(roleService IRoleService) IItemPolicy {
+	return &ItemPolicy{roleService: roleService}
+}
+
+// CanRead 出品中の商品は誰でも閲覧でき、売り切れの商品は出品者とモデレーション権限を持つユーザーのみ閲覧できる
+func (p *ItemPolicy) CanRead(user *models.User, item *models.Item) (bool, error) {
+	if isActiveListing(item) || isOwner(user, item) {
+		return true, nil
+	}
+	return p.canModerate(user)
+}
+
+// CanUpdate 出品者のみ更新できる。売り切れの商品は売り切れ状態の変更のみ許可する
+func (p *ItemPolicy) CanUpdate(user *models.User, item *models.Item, input dto.UpdateItemInput) (bool, error) {
+	if !isOwner(user, item) {
+		return false, nil
+	}
+	if item.SoldOut && (input.Name != nil || input.Price != nil || input.Description != nil) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// CanDelete 出品者は出品中の商品を削除でき、モデレーション権限を持つユーザーはすべての商品を取り下げられる
+// 売り切れの商品は購入者側の取引記録になるため出品者自身では削除できない
+func (p *ItemPolicy) CanDelete(user *models.User, item *models.Item) (bool, error) {
+	if isOwner(user, item) && isActiveListing(item) {
+		return true, nil
+	}
+	return p.canModerate(user)
+}
+
+func (p *ItemPolicy) canModerate(user *models.User) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	return p.roleService.HasPermissions(user.Role, constants.PermItemDeleteAny)
+}
+
+func isOwner(user *models.User, item *models.Item) bool {
+	return user != nil && user.ID == item.UserID
+}
+
+func isActiveListing(item *models.Item) bool {
+	return !item.SoldOut
+}
@@ -1,47 +1,90 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"gin-fleamarket/apperrors"
 	"gin-fleamarket/constants"
 	"gin-fleamarket/dto"
 	"gin-fleamarket/models"
 	"gin-fleamarket/repositories"
+	"gin-fleamarket/tracing"
 
 	"gorm.io/gorm"
 )
 
+// IItemService userがnilの場合は未ログインのユーザーとして扱う。ctxはリクエストのcontext（スパンの親になる）
 type IItemService interface {
-	FindAll() (*[]models.Item, error)
-	FindById(itemID uint, userID uint) (*models.Item, error)
-	Create(createItemInput dto.CreateItemInput, userID uint) (*models.Item, error)
-	Update(itemID uint, userID uint, updateItemInput dto.UpdateItemInput) (*models.Item, error)
-	Delete(itemID uint) error
+	FindAll(ctx context.Context, user *models.User) (*[]models.Item, error)
+	FindById(ctx context.Context, itemID uint, user *models.User) (*models.Item, error)
+	Create(ctx context.Context, createItemInput dto.CreateItemInput, userID uint) (*models.Item, error)
+	Update(ctx context.Context, itemID uint, user *models.User, updateItemInput dto.UpdateItemInput) (*models.Item, error)
+	Delete(ctx context.Context, itemID uint, user *models.User, client ClientInfo) error
 }
 
 type ItemService struct {
-	repository repositories.IItemRepository
+	repository   repositories.IItemRepository
+	policy       IItemPolicy
+	auditService IAuditService
 }
 
-func NewItemService(repository repositories.IItemRepository) IItemService {
-	return &ItemService{repository: repository}
+func NewItemService(repository repositories.IItemRepository, policy IItemPolicy, auditService IAuditService) IItemService {
+	return &ItemService{repository: repository, policy: policy, auditService: auditService}
 }
 
-func (s *ItemService) FindAll() (*[]models.Item, error) {
-	return s.repository.FindAll()
+func (s *ItemService) FindAll(ctx context.Context, user *models.User) (_ *[]models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemService.FindAll")
+	defer tracing.Finish(span, &err)
+
+	items, err := s.repository.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	readable := make([]models.Item, 0, len(*items))
+	for i := range *items {
+		canRead, err := s.policy.CanRead(user, &(*items)[i])
+		if err != nil {
+			return nil, err
+		}
+		if canRead {
+			readable = append(readable, (*items)[i])
+		}
+	}
+	return &readable, nil
 }
 
-func (s *ItemService) FindById(itemID uint, userID uint) (*models.Item, error) {
-	item, err := s.repository.FindById(itemID, userID)
+func (s *ItemService) FindById(ctx context.Context, itemID uint, user *models.User) (_ *models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemService.FindById")
+	defer tracing.Finish(span, &err)
+
+	return s.findReadable(ctx, itemID, user)
+}
+
+// findReadable 閲覧できない商品は存在しないものとして扱う
+func (s *ItemService) findReadable(ctx context.Context, itemID uint, user *models.User) (*models.Item, error) {
+	item, err := s.repository.FindById(ctx, itemID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New(constants.ErrItemNotFound)
+			return nil, apperrors.ErrItemNotFound
 		}
 		return nil, err
 	}
+
+	canRead, err := s.policy.CanRead(user, item)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead {
+		return nil, apperrors.ErrItemNotFound
+	}
 	return item, nil
 }
 
-func (s *ItemService) Create(createItemInput dto.CreateItemInput, userID uint) (*models.Item, error) {
+func (s *ItemService) Create(ctx context.Context, createItemInput dto.CreateItemInput, userID uint) (_ *models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemService.Create")
+	defer tracing.Finish(span, &err)
+
 	newItem := models.Item{
 		Name:        createItemInput.Name,
 		Price:       createItemInput.Price,
@@ -49,10 +92,13 @@ func (s *ItemService) Create(createItemInput dto.CreateItemInput, userID uint) (
 		SoldOut:     false,
 		UserID:      userID,
 	}
-	return s.repository.Create(newItem)
+	return s.repository.Create(ctx, newItem)
 }
 
-func (s *ItemService) Update(itemID uint, userID uint, updateItemInput dto.UpdateItemInput) (*models.Item, error) {
+func (s *ItemService) Update(ctx context.Context, itemID uint, user *models.User, updateItemInput dto.UpdateItemInput) (_ *models.Item, err error) {
+	ctx, span := tracing.Start(ctx, "ItemService.Update")
+	defer tracing.Finish(span, &err)
+
 	updates := make(map[string]interface{})
 
 	if updateItemInput.Name != nil {
@@ -69,13 +115,25 @@ func (s *ItemService) Update(itemID uint, userID uint, updateItemInput dto.Updat
 	}
 
 	if len(updates) == 0 {
-		return nil, errors.New("no fields to update")
+		return nil, apperrors.ErrNoFieldsToUpdate
 	}
 
-	updatedItem, err := s.repository.Update(itemID, userID, updates)
+	item, err := s.findReadable(ctx, itemID, user)
+	if err != nil {
+		return nil, err
+	}
+	canUpdate, err := s.policy.CanUpdate(user, item, updateItemInput)
+	if err != nil {
+		return nil, err
+	}
+	if !canUpdate {
+		return nil, apperrors.ErrForbidden
+	}
+
+	updatedItem, err := s.repository.Update(ctx, itemID, updates)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New(constants.ErrItemNotFound)
+			return nil, apperrors.ErrItemNotFound
 		}
 		return nil, err
 	}
@@ -83,13 +141,39 @@ func (s *ItemService) Update(itemID uint, userID uint, updateItemInput dto.Updat
 	return updatedItem, nil
 }
 
-func (s *ItemService) Delete(itemID uint) error {
-	err := s.repository.Delete(itemID)
+func (s *ItemService) Delete(ctx context.Context, itemID uint, user *models.User, client ClientInfo) (err error) {
+	ctx, span := tracing.Start(ctx, "ItemService.Delete")
+	defer tracing.Finish(span, &err)
+
+	item, err := s.findReadable(ctx, itemID, user)
+	if err != nil {
+		return err
+	}
+	canDelete, err := s.policy.CanDelete(user, item)
+	if err != nil {
+		return err
+	}
+	if !canDelete {
+		return apperrors.ErrForbidden
+	}
+
+	err = s.repository.Delete(ctx, itemID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New(constants.ErrItemNotFound)
+			return apperrors.ErrItemNotFound
 		}
 		return err
 	}
+
+	// 出品者以外による削除はモデレーションとして記録する
+	if !isOwner(user, item) {
+		recordAudit(s.auditService, AuditEntry{
+			Action:   constants.AuditItemModerationDelete,
+			ActorID:  user.ID,
+			TargetID: item.UserID,
+			Client:   client,
+			Detail:   map[string]interface{}{"item_id": item.ID, "name": item.Name},
+		})
+	}
 	return nil
 }
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/scheduler"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultJobRunsPerPage = 20
+
+// IJobService 管理者向けにジョブの状態と実行履歴を返す
+// 履歴はDBから読むため、リーダーではないインスタンスに届いたリクエストでも同じ結果になる
+type IJobService interface {
+	FindAll() (*[]dto.JobResponse, error)
+	FindRuns(name string, query dto.JobRunQuery) (*[]dto.JobRunResponse, *dto.PageMeta, error)
+	// Run ジョブをすぐに実行し、終了を待つ。ジョブが失敗した場合もエラーにはせず、実行履歴のstatusで返す
+	Run(ctx context.Context, name string) (*dto.JobRunResponse, error)
+}
+
+type JobService struct {
+	scheduler  scheduler.IScheduler
+	repository repositories.IJobRepository
+}
+
+func NewJobService(scheduler scheduler.IScheduler, repository repositories.IJobRepository) IJobService {
+	return &JobService{scheduler: scheduler, repository: repository}
+}
+
+func (s *JobService) FindAll() (*[]dto.JobResponse, error) {
+	jobs := s.scheduler.Jobs()
+	responses := make([]dto.JobResponse, 0, len(jobs))
+	now := time.Now()
+	for _, job := range jobs {
+		response := dto.JobResponse{Name: job.Name, Schedule: job.Schedule.String()}
+
+		var lastStartedAt *time.Time
+		last, err := s.repository.FindLastRun(job.Name)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if last != nil {
+			lastStartedAt = &last.StartedAt
+			response.LastRun = toJobRunResponse(last)
+		}
+		response.NextRunAt = scheduler.NextRun(job, lastStartedAt, now).Unix()
+
+		failure, err := s.repository.FindLastFailure(job.Name)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if failure != nil {
+			response.LastFailure = toJobRunResponse(failure)
+		}
+		responses = append(responses, response)
+	}
+	return &responses, nil
+}
+
+func (s *JobService) FindRuns(name string, query dto.JobRunQuery) (*[]dto.JobRunResponse, *dto.PageMeta, error) {
+	if !s.hasJob(name) {
+		return nil, nil, apperrors.ErrJobNotFound
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PerPage == 0 {
+		query.PerPage = defaultJobRunsPerPage
+	}
+
+	runs, total, err := s.repository.FindRuns(name, query.Page, query.PerPage)
+	if err != nil {
+		return nil, nil, err
+	}
+	responses := make([]dto.JobRunResponse, 0, len(*runs))
+	for i := range *runs {
+		responses = append(responses, *toJobRunResponse(&(*runs)[i]))
+	}
+	return &responses, &dto.PageMeta{Page: query.Page, PerPage: query.PerPage, Total: total}, nil
+}
+
+func (s *JobService) Run(ctx context.Context, name string) (*dto.JobRunResponse, error) {
+	run, err := s.scheduler.RunNow(ctx, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, scheduler.ErrJobNotFound):
+			return nil, apperrors.ErrJobNotFound
+		case errors.Is(err, scheduler.ErrJobBusy):
+			return nil, apperrors.ErrJobBusy
+		}
+		return nil, err
+	}
+	return toJobRunResponse(run), nil
+}
+
+func (s *JobService) hasJob(name string) bool {
+	for _, job := range s.scheduler.Jobs() {
+		if job.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func toJobRunResponse(run *models.JobRun) *dto.JobRunResponse {
+	response := &dto.JobRunResponse{
+		ID:         run.ID,
+		Holder:     run.Holder,
+		Status:     run.Status,
+		StartedAt:  run.StartedAt.Unix(),
+		DurationMs: run.DurationMs,
+		Error:      run.Error,
+	}
+	if run.FinishedAt != nil {
+		response.FinishedAt = run.FinishedAt.Unix()
+	}
+	return response
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"gin-fleamarket/jwks"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// 他インスタンスでのローテーションを取り込むための再読み込み間隔
+	keyRingReloadInterval = 5 * time.Minute
+	// 未知のkidを受け取った時の再読み込みの最小間隔
+	keyRingMinReloadInterval = 30 * time.Second
+)
+
+type IKeyRing interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	ValidMethods() []string
+	PublicKeys() (*jwks.JSONWebKeySet, error)
+	Rotate() error
+}
+
+type ringKey struct {
+	kid       string
+	algorithm string
+	signer    crypto.Signer
+}
+
+// KeyRing kidで識別される署名鍵の集合
+// 鍵はDBに保存されるため、複数インスタンス間で同じ鍵が共有される
+type KeyRing struct {
+	repository repositories.ISigningKeyRepository
+	algorithm  string
+	// 移行期間中（legacyUntilまで）、SECRET_KEYで署名された旧HS256トークンを受け付ける
+	legacySecret []byte
+	legacyUntil  time.Time
+
+	mu       sync.RWMutex
+	active   *ringKey
+	keys     map[string]*ringKey
+	loadedAt time.Time
+}
+
+// NewKeyRing legacySecretを渡した場合は、legacyUntilまで移行前にSECRET_KEYで署名された旧HS256トークンも検証する
+// 共有鍵を知っていれば任意のトークンを作れるため、期限のない受け付けはしない
+func NewKeyRing(repository repositories.ISigningKeyRepository, algorithm string, legacySecret string, legacyUntil time.Time) (IKeyRing, error) {
+	if algorithm == "" {
+		algorithm = jwt.SigningMethodRS256.Alg()
+	}
+	if algorithm != jwt.SigningMethodRS256.Alg() && algorithm != jwt.SigningMethodEdDSA.Alg() {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	ring := &KeyRing{
+		repository: repository,
+		algorithm:  algorithm,
+	}
+	if legacySecret != "" && time.Now().Before(legacyUntil) {
+		ring.legacySecret = []byte(legacySecret)
+		ring.legacyUntil = legacyUntil
+		slog.Info("Accepting legacy HS256 tokens", "until", legacyUntil)
+	}
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+	if err := ring.reload(); err != nil {
+		return nil, err
+	}
+	if ring.active == nil {
+		if err := ring.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	r.reloadIfStale()
+
+	r.mu.RLock()
+	active := r.active
+	r.mu.RUnlock()
+	if active == nil {
+		return "", fmt.Errorf("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(active.algorithm), claims)
+	token.Header["kid"] = active.kid
+	return token.SignedString(active.signer)
+}
+
+// Keyfunc jwt.Parseに渡す検証鍵の解決関数
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && r.acceptsLegacy() {
+			return r.legacySecret, nil
+		}
+		return nil, fmt.Errorf("token has no key id")
+	}
+
+	key, err := r.lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("unexpected method: %v", token.Header["alg"])
+	}
+	return key.signer.Public(), nil
+}
+
+func (r *KeyRing) lookup(kid string) (*ringKey, error) {
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	canReload := time.Since(r.loadedAt) > keyRingMinReloadInterval
+	r.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !canReload {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	if key, ok := r.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id: %s", kid)
+}
+
+func (r *KeyRing) ValidMethods() []string {
+	methods := []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	if r.acceptsLegacy() {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	return methods
+}
+
+func (r *KeyRing) acceptsLegacy() bool {
+	return r.legacySecret != nil && time.Now().Before(r.legacyUntil)
+}
+
+// PublicKeys /.well-known/jwks.json で公開する検証用の鍵セット
+func (r *KeyRing) PublicKeys() (*jwks.JSONWebKeySet, error) {
+	r.reloadIfStale()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := &jwks.JSONWebKeySet{Keys: []jwks.JSONWebKey{}}
+	for _, key := range r.keys {
+		jwk, err := jwks.FromPublicKey(key.kid, key.algorithm, key.signer.Public())
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// Rotate 新しい署名鍵を生成する。旧鍵は発行済みトークンが失効するまで検証に使われる
+func (r *KeyRing) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotate()
+}
+
+func (r *KeyRing) rotate() error {
+	signer, err := generateSigner(r.algorithm)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return err
+	}
+	kidBytes := make([]byte, 16)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	newKey := models.SigningKey{
+		Kid:        base64.RawURLEncoding.EncodeToString(kidBytes),
+		Algorithm:  r.algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}
+	if err := r.repository.Rotate(newKey, now.Unix()); err != nil {
+		return err
+	}
+	slog.Info("Rotated signing key", "kid", newKey.Kid, "alg", newKey.Algorithm)
+
+	// 検証にも使われなくなった鍵は削除する
+	if err := r.repository.DeleteRetiredBefore(now.Add(-RefreshTokenTTL).Unix()); err != nil {
+		slog.Error("Failed to delete expired signing keys", "error", err)
+	}
+	return r.reload()
+}
+
+func (r *KeyRing) reloadIfStale() {
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) > keyRingReloadInterval
+	r.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reload(); err != nil {
+		slog.Error("Failed to reload signing keys", "error", err)
+	}
+}
+
+// reload 呼び出し側でロックを取得していること
+func (r *KeyRing) reload() error {
+	stored, err := r.repository.FindUsable(time.Now().Add(-RefreshTokenTTL).Unix())
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*ringKey, len(*stored))
+	var active *ringKey
+	for _, storedKey := range *stored {
+		signer, err := parseSigner(storedKey.PrivateKey)
+		if err != nil {
+			slog.Warn("Skipping unreadable signing key", "kid", storedKey.Kid, "error", err)
+			continue
+		}
+		key := &ringKey{kid: storedKey.Kid, algorithm: storedKey.Algorithm, signer: signer}
+		keys[key.kid] = key
+		// 新しい順に並んでいるため最初に見つかった現役の鍵を使う
+		if storedKey.RetiredAt == 0 && active == nil {
+			active = key
+		}
+	}
+	r.keys = keys
+	r.active = active
+	r.loadedAt = time.Now()
+	return nil
+}
+
+func generateSigner(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+}
+
+func parseSigner(privateKeyPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+	return signer, nil
+}
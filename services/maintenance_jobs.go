@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/revocation"
+	"gin-fleamarket/scheduler"
+	"time"
+)
+
+// ジョブの実行履歴を残す期間
+const jobRunRetention = 30 * 24 * time.Hour
+
+// MaintenanceJobs 期限切れのデータを削除する定期ジョブ
+func MaintenanceJobs(revocationStore revocation.ITokenRevocationStore, sessionRepository repositories.ISessionRepository, oidcRepository repositories.IOIDCRepository, jobRepository repositories.IJobRepository) []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:     "revoked-tokens-cleanup",
+			Schedule: scheduler.Every(time.Hour),
+			Run: func(ctx context.Context) error {
+				return revocationStore.DeleteExpired()
+			},
+		},
+		{
+			Name:     "oidc-login-states-cleanup",
+			Schedule: scheduler.Every(15 * time.Minute),
+			Run: func(ctx context.Context) error {
+				return oidcRepository.DeleteExpiredLoginStates()
+			},
+		},
+		{
+			Name:     "refresh-sessions-cleanup",
+			Schedule: scheduler.MustCron("30 3 * * *"),
+			Run: func(ctx context.Context) error {
+				return sessionRepository.DeleteExpired()
+			},
+		},
+		{
+			Name:     "job-runs-cleanup",
+			Schedule: scheduler.MustCron("0 4 * * *"),
+			Run: func(ctx context.Context) error {
+				return jobRepository.DeleteRunsBefore(time.Now().Add(-jobRunRetention))
+			},
+		},
+	}
+}
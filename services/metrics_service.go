@@ -0,0 +1,43 @@
+package services
+
+import (
+	"database/sql"
+	"gin-fleamarket/metrics"
+	"gin-fleamarket/repositories"
+	"log/slog"
+)
+
+// RegisterDBMetrics DBのコネクションプールと、業務上の件数をmetrics.Defaultに登録する
+// 値は/metricsの出力のたびにDBから読み取る。注文のモデルはまだないため、注文のステータスごとの件数は出力しない
+func RegisterDBMetrics(repository repositories.IMetricsRepository) {
+	poolStat := func(value func(stats sql.DBStats) float64) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			stats, err := repository.PoolStats()
+			if err != nil {
+				return nil
+			}
+			return []metrics.Sample{{Value: value(stats)}}
+		}
+	}
+	metrics.RegisterGaugeFunc("db_pool_max_open_connections", "Maximum number of open connections to the database.", nil,
+		poolStat(func(stats sql.DBStats) float64 { return float64(stats.MaxOpenConnections) }))
+	metrics.RegisterGaugeFunc("db_pool_open_connections", "Number of established connections, both in use and idle.", nil,
+		poolStat(func(stats sql.DBStats) float64 { return float64(stats.OpenConnections) }))
+	metrics.RegisterGaugeFunc("db_pool_in_use_connections", "Number of connections currently in use.", nil,
+		poolStat(func(stats sql.DBStats) float64 { return float64(stats.InUse) }))
+	metrics.RegisterGaugeFunc("db_pool_idle_connections", "Number of idle connections.", nil,
+		poolStat(func(stats sql.DBStats) float64 { return float64(stats.Idle) }))
+	metrics.RegisterCounterFunc("db_pool_wait_count_total", "Total number of connections waited for.", nil,
+		poolStat(func(stats sql.DBStats) float64 { return float64(stats.WaitCount) }))
+	metrics.RegisterCounterFunc("db_pool_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", nil,
+		poolStat(func(stats sql.DBStats) float64 { return stats.WaitDuration.Seconds() }))
+
+	metrics.RegisterGaugeFunc("fleamarket_active_listings", "Number of items that are listed and not sold out.", nil, func() []metrics.Sample {
+		count, err := repository.CountActiveListings()
+		if err != nil {
+			slog.Error("Failed to count active listings", "error", err)
+			return nil
+		}
+		return []metrics.Sample{{Value: float64(count)}}
+	})
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/metrics"
+	"gin-fleamarket/models"
+	"gin-fleamarket/oidc"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/tracing"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCLoginStateTTL 認可リクエストからコールバックまでに許容する時間
+const OIDCLoginStateTTL = 10 * time.Minute
+
+type IOIDCService interface {
+	// AuthorizationURL 認可エンドポイントのURLと、コールバックで照合するstateを返す
+	AuthorizationURL(ctx context.Context, providerName string) (string, string, error)
+	HandleCallback(ctx context.Context, providerName string, code string, state string, client ClientInfo) (*TokenPair, error)
+}
+
+type OIDCService struct {
+	providers      map[string]*oidc.Provider
+	repository     repositories.IOIDCRepository
+	authRepository repositories.IAuthRepository
+	authService    IAuthService
+	auditService   IAuditService
+}
+
+func NewOIDCService(providers []*oidc.Provider, repository repositories.IOIDCRepository, authRepository repositories.IAuthRepository, authService IAuthService, auditService IAuditService) IOIDCService {
+	providerMap := make(map[string]*oidc.Provider, len(providers))
+	for _, provider := range providers {
+		providerMap[provider.Name()] = provider
+	}
+	return &OIDCService{
+		providers:      providerMap,
+		repository:     repository,
+		authRepository: authRepository,
+		authService:    authService,
+		auditService:   auditService,
+	}
+}
+
+func (s *OIDCService) AuthorizationURL(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", apperrors.ErrOIDCProviderNotFound
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", apperrors.ErrOIDCUnavailable.Wrap(err)
+	}
+
+	loginState := models.OIDCLoginState{
+		State:        state,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(OIDCLoginStateTTL).Unix(),
+	}
+	if err := s.repository.SaveLoginState(loginState); err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+func (s *OIDCService) HandleCallback(ctx context.Context, providerName string, code string, state string, client ClientInfo) (*TokenPair, error) {
+	// トークンの交換や鍵の取得（外部へのHTTPリクエスト）はこのスパンの子になる
+	ctx, span := tracing.Start(ctx, "OIDCService.HandleCallback", tracing.WithAttributes(tracing.String("oidc.provider", providerName)))
+	defer span.End()
+
+	tokenPair, err := s.handleCallback(ctx, providerName, code, state, client)
+	span.RecordError(err)
+	if err != nil {
+		metrics.Logins.Inc("oidc", "failure")
+		slog.WarnContext(ctx, "OIDC callback failed", "provider", providerName, "error", err)
+		return nil, err
+	}
+	metrics.Logins.Inc("oidc", "success")
+	return tokenPair, nil
+}
+
+func (s *OIDCService) handleCallback(ctx context.Context, providerName string, code string, state string, client ClientInfo) (*TokenPair, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, apperrors.ErrOIDCProviderNotFound
+	}
+
+	loginState, err := s.repository.ConsumeLoginState(state)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrOIDCInvalidState
+		}
+		return nil, err
+	}
+	if loginState.Provider != providerName {
+		return nil, apperrors.ErrOIDCInvalidState
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, apperrors.ErrOIDCFailed.Wrap(err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		return nil, apperrors.ErrOIDCFailed.Wrap(err)
+	}
+
+	user, err := s.resolveUser(providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+	tokenPair, err := s.authService.IssueTokenPair(user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditLoginSuccess,
+		ActorID:  user.ID,
+		TargetID: user.ID,
+		Client:   client,
+		Detail:   map[string]interface{}{"method": "oidc", "provider": providerName},
+	})
+	return tokenPair, nil
+}
+
+// resolveUser 外部IDに紐づくユーザーを返す
+// 未連携の場合は検証済みメールアドレスで既存ユーザーに連携し、いなければ新規作成する
+func (s *OIDCService) resolveUser(providerName string, claims *oidc.IDTokenClaims) (*models.User, error) {
+	identity, err := s.repository.FindIdentity(providerName, claims.Subject)
+	if err == nil {
+		return s.repository.FindUserByID(identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, apperrors.ErrOIDCEmailNotVerified
+	}
+
+	newIdentity := models.UserIdentity{
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	existingUser, err := s.authRepository.FindUser(claims.Email)
+	if err == nil {
+		newIdentity.UserID = existingUser.ID
+		if err := s.repository.LinkIdentity(newIdentity); err != nil {
+			return nil, err
+		}
+		slog.Info("Linked OIDC identity to existing user", "provider", providerName, "user_id", existingUser.ID)
+		return existingUser, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 外部ID経由のユーザーはパスワードを持たない（空のハッシュではパスワードログインできない）
+	user := models.User{
+		Email: claims.Email,
+		Role:  constants.RoleUser,
+	}
+	createdUser, err := s.repository.CreateUserWithIdentity(user, newIdentity)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Created user from OIDC identity", "provider", providerName, "user_id", createdUser.ID)
+	return createdUser, nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"gin-fleamarket/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MaxAvatarSize アバター画像の最大サイズ（2MB）
+const MaxAvatarSize = 2 << 20
+
+// アップロードを許可する画像形式と保存時の拡張子（Content-Typeはファイルの内容から判定する）
+var avatarExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+type IProfileService interface {
+	FindMine(userID uint) (*dto.ProfileResponse, error)
+	Update(userID uint, input dto.UpdateProfileInput) (*dto.ProfileResponse, error)
+	UpdateAvatar(userID uint, content io.Reader) (*dto.ProfileResponse, error)
+	FindPublic(userID uint) (*dto.PublicUserResponse, error)
+}
+
+type ProfileService struct {
+	repository repositories.IUserRepository
+	policy     IItemPolicy
+	storage    storage.IFileStorage
+}
+
+func NewProfileService(repository repositories.IUserRepository, policy IItemPolicy, storage storage.IFileStorage) IProfileService {
+	return &ProfileService{
+		repository: repository,
+		policy:     policy,
+		storage:    storage,
+	}
+}
+
+func (s *ProfileService) FindMine(userID uint) (*dto.ProfileResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return toProfileResponse(user), nil
+}
+
+func (s *ProfileService) Update(userID uint, input dto.UpdateProfileInput) (*dto.ProfileResponse, error) {
+	updates := make(map[string]interface{})
+	if input.DisplayName != nil {
+		updates["display_name"] = strings.TrimSpace(*input.DisplayName)
+	}
+	if input.Bio != nil {
+		updates["bio"] = *input.Bio
+	}
+	if input.Prefecture != nil {
+		if *input.Prefecture != "" && !isPrefecture(*input.Prefecture) {
+			return nil, apperrors.ErrInvalidPrefecture
+		}
+		updates["prefecture"] = *input.Prefecture
+	}
+
+	if len(updates) > 0 {
+		if err := s.repository.Update(userID, updates); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrUserNotFound
+			}
+			return nil, err
+		}
+	}
+	return s.FindMine(userID)
+}
+
+// UpdateAvatar 画像を保存してから差し替え、古い画像を削除する
+func (s *ProfileService) UpdateAvatar(userID uint, content io.Reader) (*dto.ProfileResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 上限を1バイト超えて読み、サイズ超過を検出する
+	data, err := io.ReadAll(io.LimitReader(content, MaxAvatarSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxAvatarSize {
+		return nil, apperrors.ErrAvatarTooLarge
+	}
+	extension, ok := avatarExtensions[http.DetectContentType(data)]
+	if !ok {
+		return nil, apperrors.ErrInvalidAvatar
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	avatarURL, err := s.storage.Save(hex.EncodeToString(b)+extension, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Update(userID, map[string]interface{}{"avatar_url": avatarURL}); err != nil {
+		s.deleteAvatar(avatarURL)
+		return nil, err
+	}
+	if user.AvatarURL != "" {
+		s.deleteAvatar(user.AvatarURL)
+	}
+	return s.FindMine(userID)
+}
+
+// FindPublic 停止・BANされたユーザーのページは存在しないものとして扱う
+func (s *ProfileService) FindPublic(userID uint) (*dto.PublicUserResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if checkUserStatus(user) != nil {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	items, err := s.repository.FindItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	// 出品者ページは誰でも見られるため、未ログインのユーザーが閲覧できる商品だけを返す
+	listings := make([]models.Item, 0, len(*items))
+	for i := range *items {
+		canRead, err := s.policy.CanRead(nil, &(*items)[i])
+		if err != nil {
+			return nil, err
+		}
+		if canRead {
+			listings = append(listings, (*items)[i])
+		}
+	}
+
+	return &dto.PublicUserResponse{
+		ID:          user.ID,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Bio:         user.Bio,
+		Prefecture:  user.Prefecture,
+		MemberSince: user.CreatedAt.Unix(),
+		Items:       listings,
+	}, nil
+}
+
+func (s *ProfileService) findUser(userID uint) (*models.User, error) {
+	user, err := s.repository.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *ProfileService) deleteAvatar(avatarURL string) {
+	if err := s.storage.Delete(avatarURL); err != nil {
+		slog.Warn("Failed to delete avatar", "avatar_url", avatarURL, "error", err)
+	}
+}
+
+func isPrefecture(name string) bool {
+	for _, prefecture := range constants.Prefectures {
+		if prefecture == name {
+			return true
+		}
+	}
+	return false
+}
+
+func toProfileResponse(user *models.User) *dto.ProfileResponse {
+	return &dto.ProfileResponse{
+		ID:          user.ID,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Bio:         user.Bio,
+		Prefecture:  user.Prefecture,
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 他インスタンスでの権限変更を取り込むまでの最大時間
+const rolePermissionCacheTTL = 30 * time.Second
+
+// permissionCatalog 組み込みの権限とその説明
+var permissionCatalog = []models.Permission{
+	{Name: constants.PermItemDeleteAny, Description: "他のユーザーの商品を削除（出品取り下げ）できる"},
+	{Name: constants.PermUserBan, Description: "ユーザーを停止・BANできる"},
+	{Name: constants.PermUserManage, Description: "ユーザーのロールを変更できる"},
+	{Name: constants.PermUserImpersonate, Description: "サポートのために他のユーザーとしてログインできる"},
+	{Name: constants.PermOrderRefund, Description: "注文を返金できる"},
+	{Name: constants.PermCategoryManage, Description: "カテゴリを管理できる"},
+	{Name: constants.PermAuditRead, Description: "監査ログを閲覧・検証できる"},
+	{Name: constants.PermRoleManage, Description: "ロールと権限の割り当てを編集できる"},
+	{Name: constants.PermSystemManage, Description: "署名鍵のローテーションなどシステム設定を操作できる"},
+}
+
+// builtinRolePermissions 組み込みロールの初期権限
+var builtinRolePermissions = map[string][]string{
+	constants.RoleModerator: {constants.PermItemDeleteAny},
+	constants.RoleUser:      {},
+}
+
+type IRoleService interface {
+	EnsureBuiltinRoles() error
+	HasPermissions(roleName string, permissions ...string) (bool, error)
+	FindAll() (*[]dto.RoleResponse, error)
+	FindAllPermissions() (*[]models.Permission, error)
+	Create(input dto.CreateRoleInput) (*dto.RoleResponse, error)
+	Update(name string, input dto.UpdateRoleInput) (*dto.RoleResponse, error)
+	Delete(name string) error
+}
+
+type RoleService struct {
+	repository repositories.IRoleRepository
+
+	mu       sync.RWMutex
+	cache    map[string]map[string]bool
+	loadedAt time.Time
+}
+
+func NewRoleService(repository repositories.IRoleRepository) IRoleService {
+	return &RoleService{repository: repository}
+}
+
+// EnsureBuiltinRoles 権限と組み込みロールをDBに登録する（起動時に毎回呼ばれても重複しない）
+func (s *RoleService) EnsureBuiltinRoles() error {
+	for _, permission := range permissionCatalog {
+		if err := s.repository.EnsurePermission(permission); err != nil {
+			return err
+		}
+	}
+
+	allPermissions, err := s.repository.FindAllPermissions()
+	if err != nil {
+		return err
+	}
+	admin := models.Role{
+		Name:        constants.RoleAdmin,
+		Description: "すべての権限を持つ管理者",
+		Builtin:     true,
+		Permissions: *allPermissions,
+	}
+	if err := s.repository.EnsureRole(admin); err != nil {
+		return err
+	}
+
+	for name, permissionNames := range builtinRolePermissions {
+		permissions, err := s.repository.FindPermissionsByName(permissionNames)
+		if err != nil {
+			return err
+		}
+		role := models.Role{Name: name, Builtin: true, Permissions: *permissions}
+		if err := s.repository.EnsureRole(role); err != nil {
+			return err
+		}
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// HasPermissions ロールが指定されたすべての権限を持つかを返す
+// 組み込みのadminロールは後から追加された権限も含めて常にすべての権限を持つ
+func (s *RoleService) HasPermissions(roleName string, permissions ...string) (bool, error) {
+	roleName = normalizeRoleName(roleName)
+	if roleName == constants.RoleAdmin {
+		return true, nil
+	}
+
+	cache, err := s.rolePermissions()
+	if err != nil {
+		return false, err
+	}
+	granted := cache[roleName]
+	for _, permission := range permissions {
+		if !granted[permission] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *RoleService) rolePermissions() (map[string]map[string]bool, error) {
+	s.mu.RLock()
+	if s.cache != nil && time.Since(s.loadedAt) < rolePermissionCacheTTL {
+		cache := s.cache
+		s.mu.RUnlock()
+		return cache, nil
+	}
+	s.mu.RUnlock()
+
+	roles, err := s.repository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	cache := make(map[string]map[string]bool, len(*roles))
+	for _, role := range *roles {
+		granted := make(map[string]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			granted[permission.Name] = true
+		}
+		cache[role.Name] = granted
+	}
+
+	s.mu.Lock()
+	s.cache = cache
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return cache, nil
+}
+
+func (s *RoleService) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+func (s *RoleService) FindAll() (*[]dto.RoleResponse, error) {
+	roles, err := s.repository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.RoleResponse, 0, len(*roles))
+	for i := range *roles {
+		responses = append(responses, toRoleResponse(&(*roles)[i]))
+	}
+	return &responses, nil
+}
+
+func (s *RoleService) FindAllPermissions() (*[]models.Permission, error) {
+	return s.repository.FindAllPermissions()
+}
+
+func (s *RoleService) Create(input dto.CreateRoleInput) (*dto.RoleResponse, error) {
+	name := normalizeRoleName(input.Name)
+	if _, err := s.repository.FindByName(name); err == nil {
+		return nil, apperrors.ErrRoleAlreadyExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	permissions, err := s.findPermissions(input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.repository.Create(models.Role{
+		Name:        name,
+		Description: input.Description,
+		Permissions: *permissions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate()
+
+	response := toRoleResponse(role)
+	return &response, nil
+}
+
+func (s *RoleService) Update(name string, input dto.UpdateRoleInput) (*dto.RoleResponse, error) {
+	role, err := s.findRole(name)
+	if err != nil {
+		return nil, err
+	}
+	// adminの権限を外すと誰も権限を管理できなくなるため編集を禁止する
+	if role.Name == constants.RoleAdmin {
+		return nil, apperrors.ErrRoleBuiltin
+	}
+
+	updates := make(map[string]interface{})
+	if input.Description != nil {
+		updates["description"] = *input.Description
+	}
+
+	var permissions *[]models.Permission
+	if input.Permissions != nil {
+		permissions, err = s.findPermissions(*input.Permissions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repository.Update(role, updates, permissions); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+
+	return s.findRoleResponse(role.Name)
+}
+
+func (s *RoleService) Delete(name string) error {
+	role, err := s.findRole(name)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return apperrors.ErrRoleBuiltin
+	}
+
+	count, err := s.repository.CountUsersWithRole(role.Name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return apperrors.ErrRoleInUse
+	}
+
+	if err := s.repository.Delete(role.Name); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *RoleService) findRole(name string) (*models.Role, error) {
+	role, err := s.repository.FindByName(normalizeRoleName(name))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+func (s *RoleService) findRoleResponse(name string) (*dto.RoleResponse, error) {
+	role, err := s.findRole(name)
+	if err != nil {
+		return nil, err
+	}
+	response := toRoleResponse(role)
+	return &response, nil
+}
+
+// findPermissions 権限名のリストを検証し、未登録の名前があればエラーにする
+func (s *RoleService) findPermissions(names []string) (*[]models.Permission, error) {
+	permissions, err := s.repository.FindPermissionsByName(names)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(*permissions))
+	for _, permission := range *permissions {
+		found[permission.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, apperrors.ErrPermissionNotFound
+		}
+	}
+	return permissions, nil
+}
+
+// normalizeRoleName RoleBasedAccessControlと同じく大文字小文字と前後の空白を無視する
+func normalizeRoleName(name string) string {
+	return strings.TrimSpace(strings.ToLower(name))
+}
+
+func toRoleResponse(role *models.Role) dto.RoleResponse {
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	return dto.RoleResponse{
+		Name:        role.Name,
+		Description: role.Description,
+		Builtin:     role.Builtin,
+		Permissions: permissions,
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+
+	"gorm.io/gorm"
+)
+
+type ISessionService interface {
+	FindActive(userID uint) (*[]dto.SessionResponse, error)
+	Revoke(sessionID uint, userID uint) error
+}
+
+type SessionService struct {
+	repository repositories.ISessionRepository
+}
+
+func NewSessionService(repository repositories.ISessionRepository) ISessionService {
+	return &SessionService{repository: repository}
+}
+
+func (s *SessionService) FindActive(userID uint) (*[]dto.SessionResponse, error) {
+	sessions, err := s.repository.FindActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.SessionResponse, 0, len(*sessions))
+	for i := range *sessions {
+		responses = append(responses, toSessionResponse(&(*sessions)[i]))
+	}
+	return &responses, nil
+}
+
+func (s *SessionService) Revoke(sessionID uint, userID uint) error {
+	err := s.repository.RevokeByID(sessionID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrSessionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func toSessionResponse(session *models.RefreshSession) dto.SessionResponse {
+	return dto.SessionResponse{
+		ID:         session.ID,
+		Device:     session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt.Unix(),
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
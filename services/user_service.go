@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"gin-fleamarket/apperrors"
+	"gin-fleamarket/constants"
+	"gin-fleamarket/dto"
+	"gin-fleamarket/models"
+	"gin-fleamarket/repositories"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUsersPerPage = 20
+)
+
+// IUserService 変更系の操作はactorIDの管理者による操作として監査ログに記録する
+// 管理者は自分自身や、自分が持たない権限を持つユーザーのロールや状態は変更できない
+type IUserService interface {
+	Search(query dto.UserSearchQuery) (*[]dto.UserResponse, *dto.PageMeta, error)
+	FindByID(userID uint) (*dto.UserResponse, error)
+	FindItems(userID uint) (*[]models.Item, error)
+	ChangeRole(actorID uint, userID uint, role string, client ClientInfo) (*dto.UserResponse, error)
+	Suspend(actorID uint, userID uint, input dto.UpdateUserStatusInput, client ClientInfo) (*dto.UserResponse, error)
+	Ban(actorID uint, userID uint, input dto.UpdateUserStatusInput, client ClientInfo) (*dto.UserResponse, error)
+	Reinstate(actorID uint, userID uint, client ClientInfo) (*dto.UserResponse, error)
+}
+
+type UserService struct {
+	repository        repositories.IUserRepository
+	roleRepository    repositories.IRoleRepository
+	roleService       IRoleService
+	sessionRepository repositories.ISessionRepository
+	auditService      IAuditService
+}
+
+func NewUserService(repository repositories.IUserRepository, roleRepository repositories.IRoleRepository, roleService IRoleService, sessionRepository repositories.ISessionRepository, auditService IAuditService) IUserService {
+	return &UserService{
+		repository:        repository,
+		roleRepository:    roleRepository,
+		roleService:       roleService,
+		sessionRepository: sessionRepository,
+		auditService:      auditService,
+	}
+}
+
+func (s *UserService) Search(query dto.UserSearchQuery) (*[]dto.UserResponse, *dto.PageMeta, error) {
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PerPage == 0 {
+		query.PerPage = defaultUsersPerPage
+	}
+
+	users, total, err := s.repository.Search(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responses := make([]dto.UserResponse, 0, len(*users))
+	for i := range *users {
+		responses = append(responses, toUserResponse(&(*users)[i]))
+	}
+	return &responses, &dto.PageMeta{Page: query.Page, PerPage: query.PerPage, Total: total}, nil
+}
+
+func (s *UserService) FindByID(userID uint) (*dto.UserResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	response := toUserResponse(user)
+	return &response, nil
+}
+
+// FindItems 管理者向けのため売り切れの商品も含めて返す
+func (s *UserService) FindItems(userID uint) (*[]models.Item, error) {
+	if _, err := s.findUser(userID); err != nil {
+		return nil, err
+	}
+	return s.repository.FindItems(userID)
+}
+
+func (s *UserService) ChangeRole(actorID uint, userID uint, role string, client ClientInfo) (*dto.UserResponse, error) {
+	role = normalizeRoleName(role)
+	if _, err := s.roleRepository.FindByName(role); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUnknownRole
+		}
+		return nil, err
+	}
+
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(actorID, user, role); err != nil {
+		return nil, err
+	}
+	response, err := s.update(userID, map[string]interface{}{"role": role})
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditUserRoleChange,
+		ActorID:  actorID,
+		TargetID: userID,
+		Client:   client,
+		Detail:   map[string]interface{}{"from": user.Role, "to": role},
+	})
+	return response, nil
+}
+
+// Suspend 一時停止する。発行済みのセッションはすべて失効させる
+func (s *UserService) Suspend(actorID uint, userID uint, input dto.UpdateUserStatusInput, client ClientInfo) (*dto.UserResponse, error) {
+	var suspendedUntil int64
+	if input.DurationDays != nil {
+		suspendedUntil = time.Now().Add(time.Duration(*input.DurationDays) * 24 * time.Hour).Unix()
+	}
+
+	return s.changeStatus(actorID, userID, map[string]interface{}{
+		"status":          constants.UserStatusSuspended,
+		"status_reason":   input.Reason,
+		"suspended_until": suspendedUntil,
+	}, client)
+}
+
+// Ban 無期限に利用を停止する。発行済みのセッションはすべて失効させる
+func (s *UserService) Ban(actorID uint, userID uint, input dto.UpdateUserStatusInput, client ClientInfo) (*dto.UserResponse, error) {
+	return s.changeStatus(actorID, userID, map[string]interface{}{
+		"status":          constants.UserStatusBanned,
+		"status_reason":   input.Reason,
+		"suspended_until": 0,
+	}, client)
+}
+
+// Reinstate 停止を解除する。失効させたセッションは復活しないため、ユーザーは再ログインが必要
+func (s *UserService) Reinstate(actorID uint, userID uint, client ClientInfo) (*dto.UserResponse, error) {
+	if err := s.authorizeByID(actorID, userID); err != nil {
+		return nil, err
+	}
+	response, err := s.update(userID, map[string]interface{}{
+		"status":          constants.UserStatusActive,
+		"status_reason":   "",
+		"suspended_until": 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordStatusChange(actorID, response, client)
+	return response, nil
+}
+
+func (s *UserService) changeStatus(actorID uint, userID uint, updates map[string]interface{}, client ClientInfo) (*dto.UserResponse, error) {
+	if err := s.authorizeByID(actorID, userID); err != nil {
+		return nil, err
+	}
+	response, err := s.update(userID, updates)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepository.RevokeAllByUserID(userID); err != nil {
+		return nil, err
+	}
+	client.Logger().Info("Changed user status and revoked all sessions", "user_id", userID, "status", response.Status)
+
+	s.recordStatusChange(actorID, response, client)
+	return response, nil
+}
+
+func (s *UserService) recordStatusChange(actorID uint, user *dto.UserResponse, client ClientInfo) {
+	recordAudit(s.auditService, AuditEntry{
+		Action:   constants.AuditUserStatusChange,
+		ActorID:  actorID,
+		TargetID: user.ID,
+		Client:   client,
+		Detail: map[string]interface{}{
+			"status":          user.Status,
+			"reason":          user.StatusReason,
+			"suspended_until": user.SuspendedUntil,
+		},
+	})
+}
+
+func (s *UserService) authorizeByID(actorID uint, userID uint) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	return s.authorize(actorID, user)
+}
+
+// authorize 自分自身の変更と、権限の昇格につながる変更を拒否する
+// 対象ユーザーの現在のロールと付与するロールが持つ権限を、操作者のロールがすべて持つ場合だけ許可する
+// adminロールは後から追加された権限も含めてすべての権限を持つため、adminを扱えるのはadminだけ
+func (s *UserService) authorize(actorID uint, user *models.User, grantedRoles ...string) error {
+	if actorID == user.ID {
+		return apperrors.ErrCannotManageSelf
+	}
+	actor, err := s.findUser(actorID)
+	if err != nil {
+		return err
+	}
+
+	for _, roleName := range append([]string{user.Role}, grantedRoles...) {
+		roleName = normalizeRoleName(roleName)
+		if roleName == constants.RoleAdmin {
+			if normalizeRoleName(actor.Role) != constants.RoleAdmin {
+				return apperrors.ErrInsufficientPrivilege
+			}
+			continue
+		}
+
+		role, err := s.roleRepository.FindByName(roleName)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.ErrUnknownRole
+			}
+			return err
+		}
+		permissions := make([]string, 0, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			permissions = append(permissions, permission.Name)
+		}
+		allowed, err := s.roleService.HasPermissions(actor.Role, permissions...)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return apperrors.ErrInsufficientPrivilege
+		}
+	}
+	return nil
+}
+
+func (s *UserService) update(userID uint, updates map[string]interface{}) (*dto.UserResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	// 退会済みのユーザーは匿名化されており、ロールの変更や復帰はできない
+	if user.Status == constants.UserStatusDeleted {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	updated, err := s.repository.UpdateKeepingAdmin(userID, updates)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	if !updated {
+		return nil, apperrors.ErrLastAdmin
+	}
+
+	return s.FindByID(userID)
+}
+
+func (s *UserService) findUser(userID uint) (*models.User, error) {
+	user, err := s.repository.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkUserStatus 停止中のユーザーにはトークンの発行や認証を許可しない
+// 期限付きの一時停止は期限を過ぎた時点で解除されたものとして扱う
+func checkUserStatus(user *models.User) error {
+	switch user.Status {
+	case constants.UserStatusDeleted:
+		return apperrors.ErrUserNotFound
+	case constants.UserStatusBanned:
+		return apperrors.ErrUserBanned
+	case constants.UserStatusSuspended:
+		if user.SuspendedUntil == 0 || time.Now().Unix() < user.SuspendedUntil {
+			return apperrors.ErrUserSuspended
+		}
+	}
+	return nil
+}
+
+func toUserResponse(user *models.User) dto.UserResponse {
+	return dto.UserResponse{
+		ID:             user.ID,
+		Email:          user.Email,
+		Role:           user.Role,
+		Status:         user.Status,
+		StatusReason:   user.StatusReason,
+		SuspendedUntil: user.SuspendedUntil,
+		CreatedAt:      user.CreatedAt.Unix(),
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IFileStorage アップロードされたファイルの保存先
+type IFileStorage interface {
+	// Save nameで保存し、クライアントから参照できるURLを返す
+	Save(name string, content io.Reader) (string, error)
+	Delete(url string) error
+}
+
+// LocalStorage ローカルディスクに保存し、baseURL配下で静的ファイルとして配信する
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStorage(dir string, baseURL string) IFileStorage {
+	return &LocalStorage{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Save ディレクトリは初回の保存時に作成する（読み取り専用の環境でも起動できるようにするため）
+func (s *LocalStorage) Save(name string, content io.Reader) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.dir, filepath.Base(name))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return s.baseURL + "/" + filepath.Base(name), nil
+}
+
+// Delete このストレージが返したURL以外は無視する
+func (s *LocalStorage) Delete(url string) error {
+	if !strings.HasPrefix(url, s.baseURL+"/") {
+		return nil
+	}
+	err := os.Remove(filepath.Join(s.dir, filepath.Base(strings.TrimPrefix(url, s.baseURL+"/"))))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter 終了したスパンをまとめて送る
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+const (
+	// batchSize この数が溜まるか、batchIntervalが経過したら送る
+	batchSize     = 512
+	batchInterval = 5 * time.Second
+	// maxQueueSize 送信が追いつかない場合は、これを超えた分を捨てる（リクエストの処理を遅らせない）
+	maxQueueSize = 4096
+)
+
+// batchProcessor スパンをリクエストの処理とは別のgoroutineでまとめて送る
+type batchProcessor struct {
+	exporter Exporter
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newBatchProcessor(exporter Exporter) *batchProcessor {
+	p := &batchProcessor{
+		exporter: exporter,
+		queue:    make(chan SpanData, maxQueueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *batchProcessor) onEnd(span SpanData) {
+	select {
+	case p.queue <- span:
+	default:
+		slog.Warn("Tracing queue is full; dropping span", "span", span.Name)
+	}
+}
+
+func (p *batchProcessor) run() {
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			slog.Error("Failed to export spans", "spans", len(batch), "error", err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-p.queue:
+				batch = append(batch, span)
+				if len(batch) == batchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) == batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-p.flush:
+			drain()
+			close(flushed)
+		case <-p.done:
+			drain()
+			return
+		}
+	}
+}
+
+// ForceFlush キューに溜まったスパンをすべて送るまで待つ
+func (p *batchProcessor) ForceFlush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stdoutExporter ローカル開発用に、スパンを1行1件のJSONで書き出す
+type stdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdoutExporter(w io.Writer) Exporter {
+	return &stdoutExporter{w: w}
+}
+
+func (e *stdoutExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		attributes := make(map[string]any, len(span.Attributes))
+		for _, attribute := range span.Attributes {
+			attributes[attribute.Key] = attribute.Value
+		}
+		entry := map[string]any{
+			"name":        span.Name,
+			"kind":        spanKindName(span.Kind),
+			"trace_id":    span.SpanContext.TraceID.String(),
+			"span_id":     span.SpanContext.SpanID.String(),
+			"start_time":  span.StartTime.Format(time.RFC3339Nano),
+			"duration_ms": float64(span.EndTime.Sub(span.StartTime).Microseconds()) / 1000,
+			"attributes":  attributes,
+		}
+		if span.ParentSpanID.IsValid() {
+			entry["parent_span_id"] = span.ParentSpanID.String()
+		}
+		if span.StatusCode == StatusError {
+			entry["error"] = span.StatusMessage
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func spanKindName(kind SpanKind) string {
+	switch kind {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// otlpExporter OTLP/HTTPのJSONエンコーディングでCollectorの/v1/tracesに送る
+type otlpExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter endpointはhttp://localhost:4318のようなCollectorのベースURL
+func NewOTLPExporter(endpoint string, headers map[string]string, serviceName string) Exporter {
+	return &otlpExporter{
+		endpoint:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+func otlpAttributes(attributes []Attribute) []otlpKeyValue {
+	result := make([]otlpKeyValue, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value map[string]any
+		switch v := attribute.Value.(type) {
+		case int64:
+			// OTLPのJSONでは64ビット整数を文字列で表す
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		result = append(result, otlpKeyValue{Key: attribute.Key, Value: value})
+	}
+	return result
+}
+
+func (e *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.StatusCode, Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			s.ParentSpanID = span.ParentSpanID.String()
+		}
+		otlpSpans = append(otlpSpans, s)
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes([]Attribute{String("service.name", e.serviceName)})},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "gin-fleamarket/tracing"},
+				"spans": otlpSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp collector returned %s", res.Status)
+	}
+	return nil
+}
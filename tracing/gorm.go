@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin クエリごとにスパンを作る。親のスパンがない場合（ジョブや起動時の処理など）は作らない
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize metrics.GormPluginと同様に、フックの時間も含めるため他のコールバックの前後に登録する
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("tracing:before_create", before("INSERT")),
+		callbacks.Create().After("*").Register("tracing:after_create", after),
+		callbacks.Query().Before("*").Register("tracing:before_query", before("SELECT")),
+		callbacks.Query().After("*").Register("tracing:after_query", after),
+		callbacks.Update().Before("*").Register("tracing:before_update", before("UPDATE")),
+		callbacks.Update().After("*").Register("tracing:after_update", after),
+		callbacks.Delete().Before("*").Register("tracing:before_delete", before("DELETE")),
+		callbacks.Delete().After("*").Register("tracing:after_delete", after),
+		callbacks.Row().Before("*").Register("tracing:before_row", before("SELECT")),
+		callbacks.Row().After("*").Register("tracing:after_row", after),
+		callbacks.Raw().Before("*").Register("tracing:before_raw", before("RAW")),
+		callbacks.Raw().After("*").Register("tracing:after_raw", after),
+	)
+}
+
+func before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		_, span := Start(ctx, "db "+operation,
+			WithSpanKind(SpanKindClient),
+			WithAttributes(
+				String("db.system", db.Dialector.Name()),
+				String("db.operation", operation),
+			),
+		)
+		if span != nil {
+			db.InstanceSet(gormSpanKey, span)
+		}
+	}
+}
+
+func after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(*Span)
+	if db.Statement.Table != "" {
+		span.SetAttributes(String("db.sql.table", db.Statement.Table))
+	}
+	// プレースホルダのままのSQLを記録し、パラメータ（個人情報を含みうる）は記録しない
+	if sql := db.Statement.SQL.String(); sql != "" {
+		span.SetAttributes(String("db.statement", sql))
+	}
+	span.SetAttributes(Int64("db.rows_affected", db.RowsAffected))
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+	}
+	span.End()
+}
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// Extract traceparentヘッダー（W3C Trace Context）があれば、以降のスパンの親としてctxに設定する
+// 形式が正しくないヘッダーは無視し、新しいトレースを開始する
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := parseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	sc.TraceState = header.Get(TracestateHeader)
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject ctxのスパンをtraceparentヘッダーとして設定する（外部へのHTTPリクエストなど）
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, FormatTraceparent(sc))
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	}
+}
+
+// FormatTraceparent version 00の形式（00-<trace-id>-<parent-id>-<flags>）
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+func parseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	// 将来のバージョンでは後ろにフィールドが追加されうるため、00以外は4つ以上を許容する
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 || parts[1] != strings.ToLower(parts[1]) || parts[2] != strings.ToLower(parts[2]) {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	return sc, true
+}
+
+// transport 外部へのHTTPリクエストにクライアントのスパンを作り、traceparentを付ける
+type transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport baseがnilの場合はhttp.DefaultTransportを使う
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// クエリ文字列には認可コードなどが含まれる場合があるため、属性には含めない
+	ctx, span := Start(req.Context(), "HTTP "+req.Method,
+		WithSpanKind(SpanKindClient),
+		WithAttributes(
+			String("http.request.method", req.Method),
+			String("server.address", req.URL.Host),
+			String("url.path", req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(StatusError, res.Status)
+	}
+	return res, nil
+}
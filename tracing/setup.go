@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"gin-fleamarket/config"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// NewTracer sampleRatioは親のないトレースをサンプリングする割合（0〜1）
+func NewTracer(exporter Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{sampleRatio: sampleRatio, processor: newBatchProcessor(exporter)}
+}
+
+// ForceFlush 終了したスパンをすべてExporterに送るまで待つ（テストやシャットダウン時に使う）
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	return t.processor.ForceFlush(ctx)
+}
+
+// Shutdown 残っているスパンを送ってから、バックグラウンドの処理を止める
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	err := t.processor.ForceFlush(ctx)
+	t.processor.stopOnce.Do(func() {
+		close(t.processor.done)
+	})
+	return err
+}
+
+// Setup OTEL_TRACES_EXPORTERに応じてTracerを設定する。noneの場合はトレースを無効にする
+// 返す関数はシャットダウン時に呼び、送信されていないスパンを送る
+func Setup(cfg config.TracingConfig) (func(ctx context.Context) error, error) {
+	var exporter Exporter
+	switch cfg.Exporter {
+	case "", "none":
+		SetTracer(nil)
+		return func(ctx context.Context) error { return nil }, nil
+	case "stdout":
+		exporter = NewStdoutExporter(os.Stdout)
+	case "otlp":
+		headers, err := parseHeaders(cfg.Headers)
+		if err != nil {
+			return nil, err
+		}
+		exporter = NewOTLPExporter(cfg.Endpoint, headers, cfg.ServiceName)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %q", cfg.Exporter)
+	}
+
+	tracer := NewTracer(exporter, cfg.SampleRatio)
+	SetTracer(tracer)
+	slog.Info("Setup tracing", "exporter", cfg.Exporter, "service", cfg.ServiceName, "sample_ratio", cfg.SampleRatio)
+	return func(ctx context.Context) error {
+		SetTracer(nil)
+		return tracer.Shutdown(ctx)
+	}, nil
+}
+
+// parseHeaders OTEL_EXPORTER_OTLP_HEADERSの形式（"key1=value1,key2=value2"）
+func parseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS must be key=value pairs separated by commas")
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return headers, nil
+}
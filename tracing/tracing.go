@@ -0,0 +1,302 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TraceID W3C Trace Contextのtrace-id（16バイト）
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID W3C Trace Contextのparent-id（8バイト）
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext 他のプロセスに引き継ぐトレースの情報
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// TraceState 受け取ったtracestateヘッダーを変更せずに引き継ぐ
+	TraceState string
+	// Remote 他のプロセスから受け取った（traceparentヘッダーから取り出した）場合にtrue
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind OTLPのSpan.SpanKindと同じ値
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode OTLPのStatus.StatusCodeと同じ値
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute スパンの属性。値はstring / int64 / float64 / boolのいずれか
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Float64(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData 終了したスパン。Exporterに渡す
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+// Span 処理1つ分の区間。nilの場合（トレースが無効な場合）はすべてのメソッドが何もしない
+type Span struct {
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+	tracer *Tracer
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil || !s.data.SpanContext.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.StatusCode = code
+	s.data.StatusMessage = message
+}
+
+// RecordError errがnilでなければスパンをエラーにする
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetAttributes(String("error.type", fmt.Sprintf("%T", err)))
+	s.SetStatus(StatusError, err.Error())
+}
+
+// Finish *errがnilでなければスパンをエラーにしてから終了する。名前付きの戻り値と組み合わせ、defer tracing.Finish(span, &err)で使う
+func Finish(span *Span, err *error) {
+	if err != nil {
+		span.RecordError(*err)
+	}
+	span.End()
+}
+
+// End 2回目以降の呼び出しは無視する
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.SpanContext.Sampled {
+		s.tracer.processor.onEnd(data)
+	}
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// ContextWithSpan 以降にStartするスパンの親にする
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext contextにスパンがなければnil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext traceparentヘッダーから取り出した親を設定する
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext 実行中のスパン、なければ他のプロセスから受け取った親
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Tracer スパンを作成し、終了したスパンをExporterに渡す
+type Tracer struct {
+	sampleRatio float64
+	processor   *batchProcessor
+}
+
+// StartOption Startでスパンの種類や属性を指定する
+type StartOption func(data *SpanData)
+
+func WithSpanKind(kind SpanKind) StartOption {
+	return func(data *SpanData) {
+		data.Kind = kind
+	}
+}
+
+func WithAttributes(attributes ...Attribute) StartOption {
+	return func(data *SpanData) {
+		data.Attributes = append(data.Attributes, attributes...)
+	}
+}
+
+// Start ctxのスパン（または他のプロセスから受け取った親）の子として新しいスパンを開始する
+// 親がなければ新しいトレースを開始し、サンプリングするかをsampleRatioで決める（親がある場合は親に従う）
+func (t *Tracer) Start(ctx context.Context, name string, options ...StartOption) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	data := SpanData{Name: name, Kind: SpanKindInternal, StartTime: time.Now()}
+	if parent.IsValid() {
+		data.SpanContext = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled, TraceState: parent.TraceState}
+		data.ParentSpanID = parent.SpanID
+	} else {
+		data.SpanContext = SpanContext{TraceID: newTraceID()}
+		data.SpanContext.Sampled = t.shouldSample(data.SpanContext.TraceID)
+	}
+	data.SpanContext.SpanID = newSpanID()
+	for _, option := range options {
+		option(&data)
+	}
+	if !data.SpanContext.Sampled {
+		data.Attributes = nil
+	}
+
+	span := &Span{data: data, tracer: t}
+	return ContextWithSpan(ctx, span), span
+}
+
+// shouldSample trace-idの上位8バイトで判定し、同じトレースはどのインスタンスでも同じ結果にする
+func (t *Tracer) shouldSample(traceID TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	return binary.BigEndian.Uint64(traceID[:8])>>1 < uint64(t.sampleRatio*(1<<63))
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// current Setupで設定したTracer。nilの場合はトレースが無効
+var (
+	currentMu sync.RWMutex
+	current   *Tracer
+)
+
+// SetTracer Setupやテストで使う。nilを渡すとトレースを無効にする
+func SetTracer(tracer *Tracer) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = tracer
+}
+
+// Start 設定されたTracerでスパンを開始する。トレースが無効な場合はctxをそのまま返し、スパンはnil
+func Start(ctx context.Context, name string, options ...StartOption) (context.Context, *Span) {
+	currentMu.RLock()
+	tracer := current
+	currentMu.RUnlock()
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.Start(ctx, name, options...)
+}